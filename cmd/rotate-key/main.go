@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/Kapeland/task-Astral/internal/storage"
+	"github.com/Kapeland/task-Astral/internal/storage/encryption"
+	"github.com/Kapeland/task-Astral/internal/storage/file-storage/file"
+	"github.com/Kapeland/task-Astral/internal/storage/file-storage/file_provider"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/files"
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+)
+
+// Rotate-key re-wraps data keys of all documents by the current master key.
+// Blobs aren't rewritten, so old master keys can be removed from the config afterwards.
+func main() {
+	if err := config.ReadConfigYAML(); err != nil {
+		log.Fatal("Failed init configuration")
+	}
+	cfg := config.GetConfig()
+	logger.CreateLogger(&cfg)
+
+	if !cfg.Encryption.Enabled {
+		log.Fatal(storage.ErrEncryptionDisabled)
+	}
+	kr, err := encryption.NewKeyringFromConfig(cfg.Encryption)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	dbStor, err := storage.NewPostgresStorage(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dbStor.Close()
+
+	fileStorage := storage.NewFileStorage(files.New(dbStor.DB), file.NewRepository(file_provider.NewFileProvider(), cfg.Storage.Root), kr)
+
+	rotated, err := fileStorage.RotateKeys(ctx)
+	if err != nil {
+		dbStor.Close()
+		log.Fatalf("rotated %d keys before failure: %v", rotated, err)
+	}
+	fmt.Printf("rotated %d keys to %s\n", rotated, kr.CurrentKeyID())
+}
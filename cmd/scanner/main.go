@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage"
+	"github.com/Kapeland/task-Astral/internal/storage/encryption"
+	"github.com/Kapeland/task-Astral/internal/storage/file-storage/file"
+	"github.com/Kapeland/task-Astral/internal/storage/file-storage/file_provider"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/files"
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+)
+
+// Scanner compares file-storage with the documents table and reports inconsistencies.
+// Exits with code 2 if any inconsistency has been found.
+func main() {
+	action := flag.String("action", storage.ScanActionReport, "What to do with orphaned files: report, quarantine or delete")
+	dryRun := flag.Bool("dry-run", false, "Only report what would be done")
+	verifyHash := flag.Bool("verify-hash", true, "Compare SHA-256 of stored files with the saved ones")
+	flag.Parse()
+
+	if err := config.ReadConfigYAML(); err != nil {
+		log.Fatal("Failed init configuration")
+	}
+	cfg := config.GetConfig()
+	logger.CreateLogger(&cfg)
+
+	ctx := context.Background()
+	dbStor, err := storage.NewPostgresStorage(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dbStor.Close()
+
+	var kr storage.Keyring
+	if cfg.Encryption.Enabled {
+		keyring, err := encryption.NewKeyringFromConfig(cfg.Encryption)
+		if err != nil {
+			log.Fatal(err)
+		}
+		kr = keyring
+	}
+
+	fileStorage := storage.NewFileStorage(files.New(dbStor.DB), file.NewRepository(file_provider.NewFileProvider(), cfg.Storage.Root), kr)
+
+	report, err := fileStorage.Scan(ctx, structs.ScanOptions{
+		Action:     *action,
+		DryRun:     *dryRun,
+		VerifyHash: *verifyHash,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, key := range report.OrphanFiles {
+		fmt.Printf("orphan file: %s\n", key)
+	}
+	for _, doc := range report.MissingFiles {
+		fmt.Printf("missing file: doc %s (%s)\n", doc.ID, doc.Name)
+	}
+	for _, doc := range report.HashMismatches {
+		fmt.Printf("hash mismatch: doc %s (%s)\n", doc.ID, doc.Name)
+	}
+	fmt.Printf("orphans: %d, missing: %d, mismatches: %d, %s: %d\n",
+		len(report.OrphanFiles), len(report.MissingFiles), len(report.HashMismatches), *action, report.Handled)
+
+	if len(report.OrphanFiles)+len(report.MissingFiles)+len(report.HashMismatches) > 0 {
+		dbStor.Close()
+		os.Exit(2)
+	}
+}
@@ -6,21 +6,53 @@ import (
 	"github.com/Kapeland/task-Astral/internal/models"
 	"github.com/Kapeland/task-Astral/internal/services"
 	"github.com/Kapeland/task-Astral/internal/storage"
+	"github.com/Kapeland/task-Astral/internal/storage/antivirus"
+	"github.com/Kapeland/task-Astral/internal/storage/antivirus/clamav"
+	"github.com/Kapeland/task-Astral/internal/storage/cachestore"
+	_ "github.com/Kapeland/task-Astral/internal/storage/db/migrations"
+	"github.com/Kapeland/task-Astral/internal/storage/encryption"
+	"github.com/Kapeland/task-Astral/internal/storage/feed"
 	"github.com/Kapeland/task-Astral/internal/storage/file-storage/file"
 	"github.com/Kapeland/task-Astral/internal/storage/file-storage/file_provider"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/audit"
 	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/auth"
 	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/files"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/folders"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/outbox"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/shares"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/uploads"
 	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/users"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/webhooks"
+	"github.com/Kapeland/task-Astral/internal/storage/webhook"
 	"github.com/Kapeland/task-Astral/internal/utils/config"
 	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/Kapeland/task-Astral/internal/utils/tracing"
 	"github.com/pressly/goose/v3"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 )
 
 func Start(cfg *config.Config, lgr *logger.Logger) error {
 	migration := flag.Bool("migration", true, "Defines the migration start option")
 	flag.Parse()
 
-	ctx := context.Background()
+	// SIGINT and SIGTERM stop the service gracefully: requests are drained, then workers stop and connections close
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Init(ctx, cfg.Tracing)
+	if err != nil {
+		lgr.Error(err.Error(), "App", "Start", "tracing.Init")
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.WithoutCancel(ctx)); err != nil {
+			lgr.Error(err.Error(), "App", "Start", "shutdownTracing")
+		}
+	}()
+
 	dbStor, err := storage.NewPostgresStorage(ctx)
 	if err != nil {
 		lgr.Error(err.Error(), "App", "Start", "NewPostgresStorage")
@@ -35,25 +67,130 @@ func Start(cfg *config.Config, lgr *logger.Logger) error {
 		}
 	}
 
+	// Background workers stop with ctx. They are waited for before connections are closed
+	var workers sync.WaitGroup
+	goWorker := func(run func()) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run()
+		}()
+	}
+
 	filesRepo := files.New(dbStor.DB)
 	usersRepo := users.New(dbStor.DB)
 	authRepo := auth.New(dbStor.DB)
+	auditRepo := audit.New(dbStor.DB)
+	uploadsRepo := uploads.New(dbStor.DB)
+	foldersRepo := folders.New(dbStor.DB)
+	sharesRepo := shares.New(dbStor.DB)
+	webhooksRepo := webhooks.New(dbStor.DB)
+	outboxRepo := outbox.New(dbStor.DB)
 
 	f := file_provider.NewFileProvider()
 
-	fr := file.NewRepository(f)
+	fr := file.NewRepository(f, cfg.Storage.Root)
 
-	fileStorage := storage.NewFileStorage(filesRepo, fr)
+	var kr storage.Keyring
+	if cfg.Encryption.Enabled {
+		keyring, err := encryption.NewKeyringFromConfig(cfg.Encryption)
+		if err != nil {
+			lgr.Error(err.Error(), "App", "Start", "NewKeyringFromConfig")
+			return err
+		}
+		kr = keyring
+	}
+
+	fileStorage := storage.NewFileStorage(filesRepo, fr, kr)
+	if err := fileStorage.Reconcile(ctx); err != nil {
+		lgr.Error("Reconciliation failed: "+err.Error(), "App", "Start", "Reconcile")
+
+		return err
+	}
+	if cfg.Scanner.Interval > 0 {
+		goWorker(func() { runScanner(ctx, &fileStorage, cfg.Scanner, lgr) })
+	}
+	if cfg.Trash.PurgeInterval > 0 {
+		goWorker(func() { runPurger(ctx, &fileStorage, cfg.Trash, lgr) })
+	}
 	authStorage := storage.NewAuthStorage(authRepo)
 	usersStorage := storage.NewUsersStorage(usersRepo)
+	auditStorage := storage.NewAuditStorage(auditRepo)
+	folderStorage := storage.NewFolderStorage(foldersRepo)
+	shareStorage := storage.NewShareStorage(sharesRepo)
+	webhookStorage := storage.NewWebhookStorage(webhooksRepo, webhookRetryPolicy(cfg.Webhooks))
+	uploadExpiry := cfg.Resumable.Expiry
+	if uploadExpiry <= 0 {
+		uploadExpiry = defaultUploadExpiry
+	}
+	uploadStorage := storage.NewUploadStorage(uploadsRepo, fr, uploadExpiry)
+	goWorker(func() { runUploadPurger(ctx, &uploadStorage, cfg.Resumable.PurgeInterval, lgr) })
 
-	fmdl := models.NewModelFiles(&fileStorage, &usersStorage, &authStorage)
-	amdl := models.NewModelAuth(&authStorage, &usersStorage)
+	fmdl := models.NewModelFiles(&fileStorage, &usersStorage, &authStorage, &folderStorage, &auditStorage)
+	amdl := models.NewModelAuth(&authStorage, &usersStorage, &auditStorage)
 	umdl := models.NewModelUsers(&usersStorage)
+	upmdl := models.NewModelUploads(&uploadStorage, &authStorage, &fmdl)
+	fomdl := models.NewModelFolders(&folderStorage, &fileStorage, &authStorage, &auditStorage)
+	shmdl := models.NewModelShares(&shareStorage, &fileStorage, &authStorage, &auditStorage)
+	aumdl := models.NewModelAudit(&auditStorage, &fileStorage, &authStorage)
+	wmdl := models.NewModelWebhooks(&webhookStorage, &authStorage,
+		webhook.New(webhookTimeout(cfg.Webhooks), cfg.Webhooks.AllowPrivate))
+	goWorker(func() { runWebhookDeliverer(ctx, &wmdl, cfg.Webhooks.Interval, lgr) })
+
+	// Events are written to the outbox with the changes of documents and relayed to the sinks from there
+	outboxStorage := storage.NewOutboxStorage(outboxRepo, outboxRetryPolicy(cfg.Outbox))
+	rdb := newRedisClient(cfg)
+	defer rdb.Close()
+	eventSinks, err := outboxSinks(cfg, &webhookStorage, rdb)
+	if err != nil {
+		lgr.Error(err.Error(), "App", "Start", "outboxSinks")
+
+		return err
+	}
+	omdl := models.NewModelOutbox(&outboxStorage, eventSinks)
+	goWorker(func() { runOutboxRelay(ctx, &omdl, cfg.Outbox, lgr) })
+
+	// Every instance listens to the feed channel and serves its own clients
+	hub := feed.NewHub(rdb, feedChannel(cfg.Feed))
+	if cfg.Feed.Enabled {
+		goWorker(func() { hub.Run(ctx) })
+	}
+	fdmdl := models.NewModelFeed(&authStorage, hub)
+
+	var vs models.VirusScanner = antivirus.NewNoop()
+	if cfg.Antivirus.Backend == antivirus.BackendClamAV {
+		vs = clamav.New(cfg.Antivirus.Network, cfg.Antivirus.Address, cfg.Antivirus.Timeout)
+	}
+	smdl := models.NewModelScan(&fileStorage, &auditStorage, vs)
+	goWorker(func() { runVirusScanner(ctx, &smdl, cfg.Antivirus.Interval, lgr) })
+
+	migrationsHealth := storage.NewMigrationsHealth(dbStor.DB, cfg.Database.Migrations)
+	redisHealth := storage.NewRedisHealth(rdb)
+	blobHealth := storage.NewBlobHealth(fr)
+	// Responses are cached in memory while Redis is unavailable, so it doesn't stop the API
+	cacheRdb := newCacheRedisClient(cfg)
+	defer cacheRdb.Close()
+	cacheStore := cachestore.New(cacheRdb, cfg.Cache)
+	if err := cacheRdb.Ping(ctx).Err(); err != nil {
+		cacheStore.Trip(err)
+	}
+
+	// Without Redis the service is degraded: the feed and the Redis sinks wait for it, the cache is local
+	hmdl := models.NewModelHealth(map[string]models.HealthChecker{
+		"postgres":   &dbStor,
+		"migrations": &migrationsHealth,
+		"storage":    &blobHealth,
+	}, map[string]models.HealthChecker{
+		"redis": &redisHealth,
+		"cache": cacheStore,
+	}, cfg.Health.Timeout)
 
-	serv := services.NewService(&fmdl, &amdl, &umdl)
+	serv := services.NewService(&fmdl, &amdl, &umdl, &upmdl, &fomdl, &shmdl, &aumdl, &wmdl, &fdmdl, &hmdl, cacheStore)
 
-	serv.Launch(cfg, lgr)
+	err = serv.Launch(ctx, cfg, lgr)
+	stop() // Workers stop even if the server has failed by itself
+	workers.Wait()
+	lgr.Info("background workers stopped", "App", "Start", "workers.Wait")
 
-	return nil
+	return err
 }
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/tracing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const defaultCacheTimeout = 200 * time.Millisecond
+
+// newCacheRedisClient connects to the Redis of cfg for the response cache. Calls fail fast,
+// so requests don't wait for a Redis which is down
+func newCacheRedisClient(cfg *config.Config) *redis.Client {
+	timeout := cfg.Cache.Timeout
+	if timeout <= 0 {
+		timeout = defaultCacheTimeout
+	}
+	rdb := redis.NewClient(&redis.Options{
+		Network:      "tcp",
+		Addr:         cfg.Redis.Host + ":" + strconv.Itoa(cfg.Redis.Port),
+		DB:           cfg.Redis.DB,
+		DialTimeout:  timeout,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+		PoolTimeout:  timeout,
+		MaxRetries:   -1, // The breaker of the cache decides when to try again
+	})
+	rdb.AddHook(tracing.RedisHook{})
+	return rdb
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/storage"
+	"github.com/Kapeland/task-Astral/internal/storage/sinks"
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/Kapeland/task-Astral/internal/utils/tracing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Names of event sinks in the config
+const (
+	sinkWebhook     = "webhook"
+	sinkRedisStream = "redis_stream"
+	sinkNATS        = "nats"
+	sinkFeed        = "feed" // Added when the feed is enabled
+)
+
+const (
+	defaultOutboxInterval   = time.Second
+	defaultOutboxBatchSize  = 100
+	defaultOutboxBackoff    = 5 * time.Second
+	defaultOutboxMaxBackoff = 10 * time.Minute
+	defaultOutboxRetention  = 24 * time.Hour
+	defaultOutboxLease      = time.Minute
+	defaultRedisStream      = "docs:events"
+	defaultNATSSubject      = "docs"
+	defaultNATSTimeout      = 5 * time.Second
+	defaultFeedChannel      = "docs:feed"
+)
+
+// outboxRetryPolicy fills missing parameters of cfg with defaults
+func outboxRetryPolicy(cfg config.Outbox) storage.RetryPolicy {
+	policy := storage.RetryPolicy{
+		Backoff:    cfg.Backoff,
+		MaxBackoff: cfg.MaxBackoff,
+		Lease:      defaultOutboxLease,
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = defaultOutboxBackoff
+	}
+	if policy.MaxBackoff < policy.Backoff {
+		policy.MaxBackoff = max(defaultOutboxMaxBackoff, policy.Backoff)
+	}
+	return policy
+}
+
+func feedChannel(cfg config.Feed) string {
+	if cfg.Channel == "" {
+		return defaultFeedChannel
+	}
+	return cfg.Channel
+}
+
+// newRedisClient connects to the Redis of cfg. Its commands are traced
+func newRedisClient(cfg *config.Config) *redis.Client {
+	rdb := redis.NewClient(&redis.Options{
+		Network: "tcp",
+		Addr:    cfg.Redis.Host + ":" + strconv.Itoa(cfg.Redis.Port),
+		DB:      cfg.Redis.DB,
+	})
+	rdb.AddHook(tracing.RedisHook{})
+	return rdb
+}
+
+// outboxSinks creates the sinks listed in cfg. Events go to webhooks only if none are listed
+func outboxSinks(cfg *config.Config, ws *storage.WebhookStorage, rdb *redis.Client) (map[string]models.EventSink, error) {
+	names := cfg.Outbox.Sinks
+	if len(names) == 0 {
+		names = []string{sinkWebhook}
+	}
+
+	eventSinks := make(map[string]models.EventSink, len(names))
+	for _, name := range names {
+		switch name {
+		case sinkWebhook:
+			eventSinks[name] = ws
+		case sinkRedisStream:
+			stream := cfg.Outbox.RedisStream.Stream
+			if stream == "" {
+				stream = defaultRedisStream
+			}
+			eventSinks[name] = sinks.NewRedisStream(rdb, stream, cfg.Outbox.RedisStream.MaxLen)
+		case sinkNATS:
+			if cfg.Outbox.NATS.Address == "" {
+				return nil, fmt.Errorf("outbox sink %s needs an address", name)
+			}
+			subject, timeout := cfg.Outbox.NATS.Subject, cfg.Outbox.NATS.Timeout
+			if subject == "" {
+				subject = defaultNATSSubject
+			}
+			if timeout <= 0 {
+				timeout = defaultNATSTimeout
+			}
+			eventSinks[name] = sinks.NewNATS(cfg.Outbox.NATS.Address, subject, timeout)
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q", name)
+		}
+	}
+	if cfg.Feed.Enabled {
+		eventSinks[sinkFeed] = sinks.NewRedisPubSub(rdb, feedChannel(cfg.Feed))
+	}
+	return eventSinks, nil
+}
+
+// runOutboxRelay periodically publishes events of the outbox and deletes old published ones until ctx is done
+func runOutboxRelay(ctx context.Context, omdl *models.ModelOutbox, cfg config.Outbox, lgr *logger.Logger) {
+	interval, batchSize, retention := cfg.Interval, cfg.BatchSize, cfg.Retention
+	if interval <= 0 {
+		interval = defaultOutboxInterval
+	}
+	if batchSize <= 0 {
+		batchSize = defaultOutboxBatchSize
+	}
+	if retention <= 0 {
+		retention = defaultOutboxRetention
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := omdl.RelayEvents(ctx, batchSize); err != nil {
+				lgr.Error(err.Error(), "App", "runOutboxRelay", "RelayEvents")
+			}
+			if _, err := omdl.PurgeEvents(ctx, time.Now().Add(-retention)); err != nil {
+				lgr.Error(err.Error(), "App", "runOutboxRelay", "PurgeEvents")
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/storage"
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+)
+
+// runPurger periodically removes documents which have been in the trash longer than the retention period
+func runPurger(ctx context.Context, fs *storage.FileStorage, cfg config.Trash, lgr *logger.Logger) {
+	ticker := time.NewTicker(cfg.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := fs.PurgeTrash(ctx, time.Now().Add(-cfg.Retention))
+			if err != nil {
+				lgr.Error(err.Error(), "App", "runPurger", "PurgeTrash")
+			}
+			if purged > 0 {
+				lgr.Info(fmt.Sprintf("purged %d documents from the trash", purged), "App", "runPurger", "PurgeTrash")
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage"
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+)
+
+// runScanner periodically scans file-storage for inconsistencies until ctx is done
+func runScanner(ctx context.Context, fs *storage.FileStorage, cfg config.Scanner, lgr *logger.Logger) {
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := fs.Scan(ctx, structs.ScanOptions{
+				Action:     cfg.Action,
+				VerifyHash: cfg.VerifyHash,
+			})
+			if err != nil {
+				lgr.Error(err.Error(), "App", "runScanner", "Scan")
+				continue
+			}
+			msg := fmt.Sprintf("storage scan: orphans: %d, missing: %d, mismatches: %d, %s: %d",
+				len(report.OrphanFiles), len(report.MissingFiles), len(report.HashMismatches), cfg.Action, report.Handled)
+			if len(report.OrphanFiles)+len(report.MissingFiles)+len(report.HashMismatches) > 0 {
+				lgr.Warn(msg, "App", "runScanner", "Scan")
+			} else {
+				lgr.Info(msg, "App", "runScanner", "Scan")
+			}
+		}
+	}
+}
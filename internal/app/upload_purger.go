@@ -0,0 +1,39 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/storage"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+)
+
+const (
+	defaultUploadExpiry        = 24 * time.Hour
+	defaultUploadPurgeInterval = time.Hour
+)
+
+// runUploadPurger periodically removes abandoned resumable uploads until ctx is done
+func runUploadPurger(ctx context.Context, us *storage.UploadStorage, interval time.Duration, lgr *logger.Logger) {
+	if interval <= 0 { // Abandoned uploads would fill the disk, so the purge can't be disabled
+		interval = defaultUploadPurgeInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := us.PurgeExpiredUploads(ctx, time.Now())
+			if err != nil {
+				lgr.Error(err.Error(), "App", "runUploadPurger", "PurgeExpiredUploads")
+			}
+			if purged > 0 {
+				lgr.Info(fmt.Sprintf("purged %d abandoned uploads", purged), "App", "runUploadPurger", "PurgeExpiredUploads")
+			}
+		}
+	}
+}
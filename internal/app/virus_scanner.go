@@ -0,0 +1,31 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+)
+
+const defaultVirusScanInterval = 5 * time.Second
+
+// runVirusScanner periodically scans uploaded documents for malware until ctx is done
+func runVirusScanner(ctx context.Context, smdl *models.ModelScan, interval time.Duration, lgr *logger.Logger) {
+	if interval <= 0 { // Uploaded documents can't be downloaded until scanned, so the scan can't be disabled
+		interval = defaultVirusScanInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := smdl.ScanPendingDocs(ctx); err != nil {
+				lgr.Error(err.Error(), "App", "runVirusScanner", "ScanPendingDocs")
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/storage"
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+)
+
+const (
+	defaultWebhookInterval    = 5 * time.Second
+	defaultWebhookTimeout     = 10 * time.Second
+	defaultWebhookMaxAttempts = 8
+	defaultWebhookBackoff     = 30 * time.Second
+	defaultWebhookMaxBackoff  = 6 * time.Hour
+)
+
+// webhookRetryPolicy fills missing parameters of cfg with defaults
+func webhookRetryPolicy(cfg config.Webhooks) storage.RetryPolicy {
+	policy := storage.RetryPolicy{
+		MaxAttempts: cfg.MaxAttempts,
+		Backoff:     cfg.Backoff,
+		MaxBackoff:  cfg.MaxBackoff,
+	}
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaultWebhookMaxAttempts
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = defaultWebhookBackoff
+	}
+	if policy.MaxBackoff < policy.Backoff {
+		policy.MaxBackoff = max(defaultWebhookMaxBackoff, policy.Backoff)
+	}
+	// Deliveries are claimed one by one, so the lease has to outlast a single attempt only
+	policy.Lease = 2 * webhookTimeout(cfg)
+	return policy
+}
+
+func webhookTimeout(cfg config.Webhooks) time.Duration {
+	if cfg.Timeout <= 0 {
+		return defaultWebhookTimeout
+	}
+	return cfg.Timeout
+}
+
+// runWebhookDeliverer periodically sends due webhook deliveries until ctx is done
+func runWebhookDeliverer(ctx context.Context, wmdl *models.ModelWebhooks, interval time.Duration, lgr *logger.Logger) {
+	if interval <= 0 {
+		interval = defaultWebhookInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := wmdl.DeliverWebhooks(ctx); err != nil {
+				lgr.Error(err.Error(), "App", "runWebhookDeliverer", "DeliverWebhooks")
+			}
+		}
+	}
+}
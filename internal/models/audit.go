@@ -0,0 +1,113 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+)
+
+type AuditStorager interface {
+	AddEvent(ctx context.Context, event structs.AuditEvent) error
+	GetEvents(ctx context.Context, filter structs.AuditFilter) ([]structs.AuditEvent, error)
+}
+
+// Actors of events without a logged in user
+const (
+	adminActor     = "admin"
+	anonymousActor = "anonymous"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+type clientKey struct{}
+
+// WithClient stores the client of the request in ctx, so audit events record where they came from
+func WithClient(ctx context.Context, client structs.Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+func clientFrom(ctx context.Context) structs.Client {
+	client, _ := ctx.Value(clientKey{}).(structs.Client)
+	return client
+}
+
+// auditResult tells denied requests from failed ones
+func auditResult(err error) string {
+	switch {
+	case err == nil:
+		return structs.AuditSuccess
+	case errors.Is(err, ErrForbidden), errors.Is(err, ErrBadCredentials), errors.Is(err, ErrBadSharePassword),
+		errors.Is(err, ErrShareExpired), errors.Is(err, ErrInfected):
+		return structs.AuditDenied
+	default:
+		return structs.AuditFailure
+	}
+}
+
+// addAudit appends the outcome of an action to the audit log. The action itself doesn't fail if it can't be recorded.
+func addAudit(ctx context.Context, au AuditStorager, actor string, action string, target string, err error, details string) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	if err != nil {
+		if details != "" {
+			details += ": "
+		}
+		details += err.Error()
+	}
+	client := clientFrom(ctx)
+	// Recorded even if the client has gone already
+	auditErr := au.AddEvent(context.WithoutCancel(ctx), structs.AuditEvent{
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Result:    auditResult(err),
+		Details:   details,
+	})
+	if auditErr != nil {
+		lgr.Error(auditErr.Error(), "models", "addAudit", "AddEvent")
+	}
+}
+
+// clampAuditLimit applies the default and the max page size
+func clampAuditLimit(limit int) int {
+	if limit <= 0 {
+		return defaultAuditLimit
+	}
+	return min(limit, maxAuditLimit)
+}
+
+// GetEvents returns events of the audit log for admins
+func (m *ModelAudit) GetEvents(ctx context.Context, filter structs.AuditFilter) ([]structs.AuditEvent, error) {
+	filter.Limit = clampAuditLimit(filter.Limit)
+
+	return m.au.GetEvents(ctx, filter)
+}
+
+// GetDocActivity returns events of the document of the token owner.
+// Returns ErrNotFound or err
+func (m *ModelAudit) GetDocActivity(ctx context.Context, token string, docID string, filter structs.AuditFilter) ([]structs.AuditEvent, error) {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := m.fs.GetDoc(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Owner != login {
+		return nil, ErrNotFound
+	}
+
+	filter.Target = docID
+	filter.Limit = clampAuditLimit(filter.Limit)
+
+	return m.au.GetEvents(ctx, filter)
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/Kapeland/task-Astral/internal/models/structs"
 	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/Kapeland/task-Astral/internal/utils/tracing"
 )
 
 type AuthStorager interface {
@@ -24,7 +25,17 @@ type AuthStorager interface {
 const validHoursNum = 24
 
 func (m *ModelAuth) RegisterUser(ctx context.Context, info structs.RegisterUserInfo) error {
-	lgr := logger.GetLogger()
+	ctx, span := tracing.Start(ctx, "ModelAuth.RegisterUser")
+	defer span.End()
+
+	err := m.registerUser(ctx, info)
+	addAudit(ctx, m.au, adminActor, "auth.register", info.Login, err, "")
+
+	return err
+}
+
+func (m *ModelAuth) registerUser(ctx context.Context, info structs.RegisterUserInfo) error {
+	lgr := logger.GetLogger().WithContext(ctx)
 
 	_, err := m.us.CreateUser(ctx, info)
 	if err != nil {
@@ -42,7 +53,10 @@ func (m *ModelAuth) RegisterUser(ctx context.Context, info structs.RegisterUserI
 // ValidateToken checks whether token bad, expired or not authenticated.
 // Returns ErrInvalidToken or ErrTokenExpired or err
 func (m *ModelAuth) ValidateToken(ctx context.Context, token string) (bool, error) {
-	lgr := logger.GetLogger()
+	ctx, span := tracing.Start(ctx, "ModelAuth.ValidateToken")
+	defer span.End()
+
+	lgr := logger.GetLogger().WithContext(ctx)
 
 	userSecret, err := m.as.GetUserSecretBySecret(ctx, token)
 
@@ -57,12 +71,25 @@ func (m *ModelAuth) ValidateToken(ctx context.Context, token string) (bool, erro
 	if userSecret.ValidUntil.Before(time.Now()) { // То есть он был, но просрочен.
 		return false, ErrTokenExpired
 	}
+	logger.SetLogin(ctx, userSecret.Login)
 
 	return true, nil
 }
 
 func (m *ModelAuth) LoginUser(ctx context.Context, info structs.AuthUserInfo) (string, error) {
-	lgr := logger.GetLogger()
+	ctx, span := tracing.Start(ctx, "ModelAuth.LoginUser")
+	defer span.End()
+
+	token, err := m.loginUser(ctx, info)
+	addAudit(ctx, m.au, info.Login, "auth.login", info.Login, err, "")
+
+	return token, err
+}
+
+func (m *ModelAuth) loginUser(ctx context.Context, info structs.AuthUserInfo) (string, error) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	logger.SetLogin(ctx, info.Login)
 
 	isPassCorrect, err := m.us.CheckPassword(ctx, info)
 	if err != nil {
@@ -120,9 +147,22 @@ func (m *ModelAuth) LoginUser(ctx context.Context, info structs.AuthUserInfo) (s
 }
 
 func (m *ModelAuth) LogoutUser(ctx context.Context, token string) error {
-	lgr := logger.GetLogger()
+	ctx, span := tracing.Start(ctx, "ModelAuth.LogoutUser")
+	defer span.End()
+
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil && !errors.Is(err, ErrNotFound) { // Missing token is reported by DeleteUserSecret
+		lgr.Error(err.Error(), "ModelAuth", "LogoutUser", "GetUserLoginBySecret")
 
-	err := m.as.DeleteUserSecret(ctx, token)
+		return err
+	}
+
+	err = m.as.DeleteUserSecret(ctx, token)
+	if login != "" {
+		addAudit(ctx, m.au, login, "auth.logout", login, err, "")
+	}
 	if err != nil {
 		if errors.Is(err, ErrNotFound) {
 			lgr.Info(fmt.Sprintf("token not found: %s", token), "ModelAuth", "LogoutUser", "DeleteUserSecret")
@@ -148,7 +188,7 @@ func genKey(length int) (string, error) {
 		}
 		n := num.Int64()
 		if (n >= 48 && n <= 57) || (n >= 65 && n <= 90) || (n >= 97 && n <= 122) {
-			result += string(n)
+			result += string(rune(n))
 		}
 	}
 }
@@ -17,3 +17,28 @@ var ErrInvalidToken = errors.New("invalid token")
 var ErrTokenExpired = errors.New("token expired")
 
 var ErrForbidden = errors.New("forbidden")
+
+var ErrScanPending = errors.New("document is not scanned yet")
+
+var ErrInfected = errors.New("document is infected")
+
+// ErrScanRejected is returned by a VirusScanner refusing the document itself, e.g. as it's too large. Retries won't help
+var ErrScanRejected = errors.New("scanner has rejected the document")
+
+var ErrScanFailed = errors.New("document can't be scanned")
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+var ErrOffsetMismatch = errors.New("upload offset mismatch")
+
+var ErrTooLarge = errors.New("upload is larger than declared")
+
+var ErrUploadIncomplete = errors.New("upload is not complete")
+
+var ErrUploadFinishing = errors.New("upload is being finished by another request")
+
+var ErrFolderNotFound = errors.New("folder not found")
+
+var ErrShareExpired = errors.New("share link is expired or has no downloads left")
+
+var ErrBadSharePassword = errors.New("bad share link password")
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"context"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+)
+
+type FeedHub interface {
+	Subscribe(login string) (<-chan structs.DocEvent, func())
+}
+
+// Subscribe returns events of documents of the token owner and of documents shared with it.
+// The channel is closed when the feed can't keep up, then the client should list documents again.
+// unsubscribe must be called when done
+func (m *ModelFeed) Subscribe(ctx context.Context, token string) (<-chan structs.DocEvent, func(), error) {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events, unsubscribe := m.hub.Subscribe(login)
+	return events, unsubscribe, nil
+}
@@ -3,37 +3,97 @@ package models
 import (
 	"context"
 	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/tracing"
 	"github.com/pkg/errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 type FileStorager interface {
-	AddDoc(ctx context.Context, doc structs.File, owner string, logins []string) error
+	AddDoc(ctx context.Context, doc structs.File, owner string, logins []string) (string, error)
 	DeleteDoc(ctx context.Context, docID string, userLogin string) (structs.RmDoc, error)
 	GetDocsByOwner(ctx context.Context, listInfo structs.ListInfo, ownerLogin string, own bool) ([]structs.DocEntry, error)
 	GetDoc(ctx context.Context, docID string) (structs.GetDoc, error)
+	RestoreDoc(ctx context.Context, docID string, userLogin string) (structs.RmDoc, error)
+	GetTrash(ctx context.Context, ownerLogin string) ([]structs.DocEntry, error)
+	OpenDoc(ctx context.Context, docID string) (io.ReadSeekCloser, error)
+	GetPendingDocs(ctx context.Context, limit int) ([]structs.PendingDoc, error)
+	SetScanStatus(ctx context.Context, docID string, status string) error
+	QuarantineDoc(ctx context.Context, docID string) error
+	MoveDoc(ctx context.Context, docID string, owner string, folderID string) error
+	GetDocGrants(ctx context.Context, docID string) ([]string, error)
+	UpdateDoc(ctx context.Context, docID string, owner string, upd structs.DocUpdate) error
+	SetDocTags(ctx context.Context, docID string, owner string, tags []string) error
+	SetDocMetadata(ctx context.Context, docID string, owner string, metadata map[string]string) error
+	GetTags(ctx context.Context, owner string, prefix string, limit int) ([]structs.TagCount, error)
 }
 
-func (m *ModelFiles) AddNewDoc(ctx context.Context, doc structs.File) error {
+// AddNewDoc stores the document on behalf of the token owner and returns its id
+func (m *ModelFiles) AddNewDoc(ctx context.Context, doc structs.File) (string, error) {
+	ctx, span := tracing.Start(ctx, "ModelFiles.AddNewDoc")
+	defer span.End()
+
 	login, err := m.as.GetUserLoginBySecret(ctx, doc.Meta.Token)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if doc.Meta.Folder != "" {
+		folder, err := m.fo.GetFolder(ctx, doc.Meta.Folder)
+		if errors.Is(err, ErrNotFound) || (err == nil && folder.Owner != login) {
+			return "", ErrFolderNotFound
+		}
+		if err != nil {
+			return "", err
+		}
 	}
 
-	err = m.fs.AddDoc(ctx, doc, login, doc.Meta.Grant)
+	docID, err := m.fs.AddDoc(ctx, doc, login, doc.Meta.Grant)
+	details := "name: " + doc.Meta.Name
+	if len(doc.Meta.Grant) != 0 {
+		details += ", grant: " + strings.Join(doc.Meta.Grant, ",")
+	}
+	addAudit(ctx, m.au, login, "doc.upload", docID, err, details)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return nil
+	return docID, nil
 }
 
 func (m *ModelFiles) DeleteDoc(ctx context.Context, token string, docID string) (structs.RmDoc, error) {
+	ctx, span := tracing.Start(ctx, "ModelFiles.DeleteDoc")
+	defer span.End()
+
 	login, err := m.as.GetUserLoginBySecret(ctx, token)
 	if err != nil {
 		return structs.RmDoc{}, err
 	}
 
 	doc, err := m.fs.DeleteDoc(ctx, docID, login)
+	addAudit(ctx, m.au, login, "doc.delete", docID, err, "")
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return structs.RmDoc{}, ErrNotFound
+		}
+		return structs.RmDoc{}, err
+	}
+
+	return doc, nil
+}
+
+func (m *ModelFiles) RestoreDoc(ctx context.Context, token string, docID string) (structs.RmDoc, error) {
+	ctx, span := tracing.Start(ctx, "ModelFiles.RestoreDoc")
+	defer span.End()
+
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return structs.RmDoc{}, err
+	}
+
+	doc, err := m.fs.RestoreDoc(ctx, docID, login)
+	addAudit(ctx, m.au, login, "doc.restore", docID, err, "")
 	if err != nil {
 		if errors.Is(err, ErrNotFound) {
 			return structs.RmDoc{}, ErrNotFound
@@ -44,11 +104,33 @@ func (m *ModelFiles) DeleteDoc(ctx context.Context, token string, docID string)
 	return doc, nil
 }
 
+// GetTrash returns documents deleted by the token owner and not purged yet
+func (m *ModelFiles) GetTrash(ctx context.Context, token string) ([]structs.DocEntry, error) {
+	ctx, span := tracing.Start(ctx, "ModelFiles.GetTrash")
+	defer span.End()
+
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) { // Logged out after the token was validated
+			return []structs.DocEntry{}, ErrInvalidToken
+		}
+		return []structs.DocEntry{}, err
+	}
+
+	return m.fs.GetTrash(ctx, login)
+}
+
 func (m *ModelFiles) GetDocs(ctx context.Context, listInfo structs.ListInfo) ([]structs.DocEntry, error) {
+	ctx, span := tracing.Start(ctx, "ModelFiles.GetDocs")
+	defer span.End()
+
 	login, err := m.as.GetUserLoginBySecret(ctx, listInfo.Token)
 	if err != nil {
 		return []structs.DocEntry{}, err
 	}
+	if listInfo.Folder != "" && listInfo.Folder != structs.RootFolder {
+		return m.getFolderDocs(ctx, listInfo, login)
+	}
 	if listInfo.Login == "" || listInfo.Login == login { // Это значит, что нужно вернуть только наши документы
 		docs, err := m.fs.GetDocsByOwner(ctx, listInfo, login, true)
 		if err != nil {
@@ -59,7 +141,7 @@ func (m *ModelFiles) GetDocs(ctx context.Context, listInfo structs.ListInfo) ([]
 	}
 
 	// Значит ищем документы другого человека
-	docs, err := m.fs.GetDocsByOwner(ctx, listInfo, login, false)
+	docs, err := m.fs.GetDocsByOwner(ctx, listInfo, listInfo.Login, false)
 	if err != nil {
 		return []structs.DocEntry{}, err
 	}
@@ -68,23 +150,268 @@ func (m *ModelFiles) GetDocs(ctx context.Context, listInfo structs.ListInfo) ([]
 
 }
 
+// GetPublicDocs lists public documents of listInfo.Login for anonymous users. Grants aren't shown to them.
+// Returns ErrInvalidInput if no login is given
+func (m *ModelFiles) GetPublicDocs(ctx context.Context, listInfo structs.ListInfo) ([]structs.DocEntry, error) {
+	ctx, span := tracing.Start(ctx, "ModelFiles.GetPublicDocs")
+	defer span.End()
+
+	if listInfo.Login == "" {
+		return []structs.DocEntry{}, ErrInvalidInput
+	}
+
+	docs, err := m.fs.GetDocsByOwner(ctx, listInfo, listInfo.Login, false)
+	if err != nil {
+		return []structs.DocEntry{}, err
+	}
+	for i := range docs {
+		docs[i].Granted = nil
+	}
+
+	return docs, nil
+}
+
+// getFolderDocs lists documents of the folder. Everything inside a folder login may read is readable.
+// Grants are shown to the owner only, as in events of the feed.
+func (m *ModelFiles) getFolderDocs(ctx context.Context, listInfo structs.ListInfo, login string) ([]structs.DocEntry, error) {
+	folder, err := m.fo.GetFolder(ctx, listInfo.Folder)
+	if err != nil {
+		return []structs.DocEntry{}, err
+	}
+	readable, err := canReadFolder(ctx, m.fo, login, folder)
+	if err != nil {
+		return []structs.DocEntry{}, err
+	}
+	if !readable {
+		return []structs.DocEntry{}, ErrNotFound
+	}
+
+	docs, err := m.fs.GetDocsByOwner(ctx, listInfo, folder.Owner, true)
+	if err != nil {
+		return []structs.DocEntry{}, err
+	}
+	if login != folder.Owner {
+		for i := range docs {
+			docs[i].Granted = nil
+		}
+	}
+
+	return docs, nil
+}
+
+// GetDoc returns the document with its opened content. The caller must close doc.Data.
 func (m *ModelFiles) GetDoc(ctx context.Context, token string, docID string) (structs.GetDoc, error) {
+	ctx, span := tracing.Start(ctx, "ModelFiles.GetDoc")
+	defer span.End()
+
 	login, err := m.as.GetUserLoginBySecret(ctx, token)
 	if err != nil {
 		return structs.GetDoc{}, err
 	}
 
+	doc, err := m.getDoc(ctx, login, docID)
+	addAudit(ctx, m.au, login, "doc.download", docID, err, "")
+
+	return doc, err
+}
+
+// GetPublicDoc returns the public document with its opened content for anonymous users.
+// Private documents look missing. The caller must close doc.Data.
+func (m *ModelFiles) GetPublicDoc(ctx context.Context, docID string) (structs.GetDoc, error) {
+	ctx, span := tracing.Start(ctx, "ModelFiles.GetPublicDoc")
+	defer span.End()
+
+	doc, err := m.getPublicDoc(ctx, docID)
+	addAudit(ctx, m.au, anonymousActor, "doc.download", docID, err, "")
+
+	return doc, err
+}
+
+func (m *ModelFiles) getPublicDoc(ctx context.Context, docID string) (structs.GetDoc, error) {
+	doc, err := m.fs.GetDoc(ctx, docID)
+	if err != nil {
+		return structs.GetDoc{}, err
+	}
+	if !doc.Public {
+		return structs.GetDoc{}, ErrNotFound
+	}
+	if err := checkScan(doc.Scan); err != nil {
+		return structs.GetDoc{}, err
+	}
+
+	doc.Data, err = m.fs.OpenDoc(ctx, doc.ID)
+	if err != nil {
+		return structs.GetDoc{}, err
+	}
+
+	return doc, nil
+}
+
+// getDoc opens the document if login may read it
+func (m *ModelFiles) getDoc(ctx context.Context, login string, docID string) (structs.GetDoc, error) {
 	doc, err := m.fs.GetDoc(ctx, docID)
 	if err != nil {
 		return structs.GetDoc{}, err
 	}
-	if doc.Owner == login { // Это наш документ
-		return doc, nil
-	} else {
-		if !doc.Public { // Значит не наш документ и закрытый
+	if doc.Owner != login && !doc.Public { // Значит не наш документ и закрытый
+		grants, err := m.fs.GetDocGrants(ctx, docID)
+		if err != nil {
+			return structs.GetDoc{}, err
+		}
+		if !slices.Contains(grants, login) { // Access may be granted to the doc itself or to one of its folders
 			return structs.GetDoc{}, ErrForbidden
 		}
 	}
+	if err := checkScan(doc.Scan); err != nil {
+		return structs.GetDoc{}, err
+	}
+
+	doc.Data, err = m.fs.OpenDoc(ctx, doc.ID)
+	if err != nil {
+		return structs.GetDoc{}, err
+	}
 
 	return doc, nil
 }
+
+// checkScan allows reading only documents found clean.
+// Returns ErrInfected or ErrScanFailed or ErrScanPending
+func checkScan(status string) error {
+	switch status {
+	case structs.ScanClean:
+		return nil
+	case structs.ScanInfected:
+		return ErrInfected
+	case structs.ScanFailed:
+		return ErrScanFailed
+	default:
+		return ErrScanPending
+	}
+}
+
+// DeleteDocs moves several documents to the trash. A failure of one document doesn't stop the others.
+func (m *ModelFiles) DeleteDocs(ctx context.Context, token string, docIDs []string) ([]structs.BulkResult, error) {
+	ctx, span := tracing.Start(ctx, "ModelFiles.DeleteDocs")
+	defer span.End()
+
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]structs.BulkResult, len(docIDs))
+	for i, docID := range docIDs {
+		_, err := m.fs.DeleteDoc(ctx, docID, login)
+		addAudit(ctx, m.au, login, "doc.delete", docID, err, "bulk")
+		results[i] = structs.BulkResult{ID: docID, Err: err}
+	}
+
+	return results, nil
+}
+
+// OpenDocs opens the documents the token owner may read. Unreadable documents are skipped.
+// The caller must close Data of every returned doc.
+func (m *ModelFiles) OpenDocs(ctx context.Context, token string, docIDs []string) ([]structs.GetDoc, error) {
+	ctx, span := tracing.Start(ctx, "ModelFiles.OpenDocs")
+	defer span.End()
+
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]structs.GetDoc, 0, len(docIDs))
+	for _, docID := range docIDs {
+		doc, err := m.getDoc(ctx, login, docID)
+		addAudit(ctx, m.au, login, "doc.download", docID, err, "archive")
+		if err != nil {
+			if errors.Is(err, ErrNotFound) || errors.Is(err, ErrForbidden) ||
+				errors.Is(err, ErrScanPending) || errors.Is(err, ErrScanFailed) || errors.Is(err, ErrInfected) {
+				continue
+			}
+			for _, opened := range docs {
+				opened.Data.Close()
+			}
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+// UpdateDoc renames the document of the token owner, changes its visibility or mime
+func (m *ModelFiles) UpdateDoc(ctx context.Context, token string, docID string, upd structs.DocUpdate) error {
+	ctx, span := tracing.Start(ctx, "ModelFiles.UpdateDoc")
+	defer span.End()
+
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	err = m.fs.UpdateDoc(ctx, docID, login, upd)
+	addAudit(ctx, m.au, login, "doc.update", docID, err, describeDocUpdate(upd))
+
+	return err
+}
+
+// describeDocUpdate lists changed fields for the audit log
+func describeDocUpdate(upd structs.DocUpdate) string {
+	var changes []string
+	if upd.Name != nil {
+		changes = append(changes, "name: "+*upd.Name)
+	}
+	if upd.Public != nil {
+		changes = append(changes, "public: "+strconv.FormatBool(*upd.Public))
+	}
+	if upd.Mime != nil {
+		changes = append(changes, "mime: "+*upd.Mime)
+	}
+	return strings.Join(changes, ", ")
+}
+
+// SetDocTags replaces tags of the document of the token owner
+func (m *ModelFiles) SetDocTags(ctx context.Context, token string, docID string, tags []string) error {
+	ctx, span := tracing.Start(ctx, "ModelFiles.SetDocTags")
+	defer span.End()
+
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	err = m.fs.SetDocTags(ctx, docID, login, tags)
+	addAudit(ctx, m.au, login, "doc.tags", docID, err, "")
+
+	return err
+}
+
+// SetDocMetadata replaces metadata of the document of the token owner
+func (m *ModelFiles) SetDocMetadata(ctx context.Context, token string, docID string, metadata map[string]string) error {
+	ctx, span := tracing.Start(ctx, "ModelFiles.SetDocMetadata")
+	defer span.End()
+
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	err = m.fs.SetDocMetadata(ctx, docID, login, metadata)
+	addAudit(ctx, m.au, login, "doc.metadata", docID, err, "")
+
+	return err
+}
+
+// GetTags returns tags of the token owner starting with prefix, for autocomplete
+func (m *ModelFiles) GetTags(ctx context.Context, token string, prefix string, limit int) ([]structs.TagCount, error) {
+	ctx, span := tracing.Start(ctx, "ModelFiles.GetTags")
+	defer span.End()
+
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.fs.GetTags(ctx, login, prefix, limit)
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+)
+
+// fakeListFiles lists documents shared with carol. Methods not used by tests panic.
+type fakeListFiles struct {
+	FileStorager
+}
+
+func (fakeListFiles) GetDocsByOwner(ctx context.Context, listInfo structs.ListInfo, ownerLogin string, own bool) ([]structs.DocEntry, error) {
+	return []structs.DocEntry{{ID: "d1", Granted: []string{"carol"}}, {ID: "d2", Granted: []string{"carol"}}}, nil
+}
+
+// fakeTokens tells the login by the token, which is the login itself. Methods not used by tests panic.
+type fakeTokens struct {
+	AuthStorager
+}
+
+func (fakeTokens) GetUserLoginBySecret(ctx context.Context, secret string) (string, error) {
+	return secret, nil
+}
+
+func TestGetFolderDocs(t *testing.T) {
+	m := NewModelFiles(fakeListFiles{}, nil, fakeTokens{}, fakeFolders{}, nil)
+	tests := []struct {
+		login       string
+		wantErr     error
+		wantGranted bool
+	}{
+		{"alice", nil, true},
+		{"bob", nil, false},
+		{"mallory", ErrNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.login, func(t *testing.T) {
+			docs, err := m.GetDocs(context.Background(), structs.ListInfo{Token: tt.login, Folder: "f1"})
+			if err != tt.wantErr {
+				t.Fatalf("GetDocs error = %v; want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(docs) != 2 {
+				t.Fatalf("docs = %+v; want both documents of the folder", docs)
+			}
+			for _, doc := range docs {
+				if granted := doc.Granted != nil; granted != tt.wantGranted {
+					t.Errorf("grants of %s = %v; want shown %v", doc.ID, doc.Granted, tt.wantGranted)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"context"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/pkg/errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type FolderStorager interface {
+	CreateFolder(ctx context.Context, owner string, parentID string, name string) (structs.Folder, error)
+	GetFolder(ctx context.Context, folderID string) (structs.Folder, error)
+	GetSubfolders(ctx context.Context, parentID string, owner string) ([]structs.Folder, error)
+	GetBreadcrumbs(ctx context.Context, folderID string) ([]structs.Folder, error)
+	GetFolderGrants(ctx context.Context, folderID string) ([]string, error)
+	UpdateFolder(ctx context.Context, folderID string, owner string, name *string, parentID *string) error
+	DeleteFolder(ctx context.Context, folderID string, owner string) (int, error)
+	SetFolderGrants(ctx context.Context, folderID string, owner string, logins []string) error
+}
+
+// canReadFolder reports whether login owns the folder or is granted access to it or to a folder it's inside of
+func canReadFolder(ctx context.Context, fo FolderStorager, login string, folder structs.Folder) (bool, error) {
+	if folder.Owner == login {
+		return true, nil
+	}
+	grants, err := fo.GetFolderGrants(ctx, folder.ID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(grants, login), nil
+}
+
+func (m *ModelFolders) CreateFolder(ctx context.Context, token string, parentID string, name string) (structs.Folder, error) {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return structs.Folder{}, err
+	}
+
+	return m.fo.CreateFolder(ctx, login, parentID, name)
+}
+
+// GetRootFolders returns folders of the token owner which aren't inside other folders
+func (m *ModelFolders) GetRootFolders(ctx context.Context, token string) ([]structs.Folder, error) {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.fo.GetSubfolders(ctx, "", login)
+}
+
+// GetFolder returns the folder with its breadcrumbs and subfolders if the token owner may read it.
+// Returns ErrNotFound or err
+func (m *ModelFolders) GetFolder(ctx context.Context, token string, folderID string) (structs.FolderView, error) {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return structs.FolderView{}, err
+	}
+
+	folder, err := m.fo.GetFolder(ctx, folderID)
+	if err != nil {
+		return structs.FolderView{}, err
+	}
+	readable, err := canReadFolder(ctx, m.fo, login, folder)
+	if err != nil {
+		return structs.FolderView{}, err
+	}
+	if !readable { // Existence of other people's folders isn't disclosed
+		return structs.FolderView{}, ErrNotFound
+	}
+
+	view := structs.FolderView{Folder: folder}
+	view.Breadcrumbs, err = m.fo.GetBreadcrumbs(ctx, folderID)
+	if err != nil {
+		return structs.FolderView{}, err
+	}
+	view.Subfolders, err = m.fo.GetSubfolders(ctx, folderID, folder.Owner)
+	if err != nil {
+		return structs.FolderView{}, err
+	}
+
+	return view, nil
+}
+
+// UpdateFolder renames the folder if name isn't nil and moves it if parentID isn't nil
+func (m *ModelFolders) UpdateFolder(ctx context.Context, token string, folderID string, name *string, parentID *string) error {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return m.fo.UpdateFolder(ctx, folderID, login, name, parentID)
+}
+
+// DeleteFolder deletes the folder recursively. Returns the number of documents moved to the trash.
+func (m *ModelFolders) DeleteFolder(ctx context.Context, token string, folderID string) (int, error) {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+
+	trashed, err := m.fo.DeleteFolder(ctx, folderID, login)
+	addAudit(ctx, m.au, login, "folder.delete", folderID, err, "trashed documents: "+strconv.Itoa(trashed))
+	return trashed, err
+}
+
+// SetFolderGrants replaces logins granted access to the folder and everything inside it
+func (m *ModelFolders) SetFolderGrants(ctx context.Context, token string, folderID string, logins []string) error {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	err = m.fo.SetFolderGrants(ctx, folderID, login, logins)
+	addAudit(ctx, m.au, login, "folder.share", folderID, err, "logins: "+strings.Join(logins, ", "))
+	return err
+}
+
+// MoveDoc puts the document into the folder. Empty folderID means the root.
+func (m *ModelFolders) MoveDoc(ctx context.Context, token string, docID string, folderID string) error {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	err = m.fs.MoveDoc(ctx, docID, login, folderID)
+	details := "folder: " + folderID
+	if folderID == "" {
+		details = "folder: root"
+	}
+	addAudit(ctx, m.au, login, "doc.move", docID, err, details)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+)
+
+// fakeAuth knows the single token "token" of alice. Methods not used by tests panic.
+type fakeAuth struct {
+	AuthStorager
+}
+
+func (fakeAuth) GetUserLoginBySecret(ctx context.Context, secret string) (string, error) {
+	if secret != "token" {
+		return "", ErrNotFound
+	}
+	return "alice", nil
+}
+
+// fakeAudit keeps events in memory. Methods not used by tests panic.
+type fakeAudit struct {
+	AuditStorager
+	events []structs.AuditEvent
+}
+
+func (f *fakeAudit) AddEvent(ctx context.Context, event structs.AuditEvent) error {
+	f.events = append(f.events, event)
+	return nil
+}
+
+// fakeFolders has a folder f1 of alice with 2 documents, granted to bob. Methods not used by tests panic.
+type fakeFolders struct {
+	FolderStorager
+}
+
+func (fakeFolders) GetFolder(ctx context.Context, folderID string) (structs.Folder, error) {
+	if folderID != "f1" {
+		return structs.Folder{}, ErrNotFound
+	}
+	return structs.Folder{ID: "f1", Owner: "alice"}, nil
+}
+
+func (fakeFolders) GetFolderGrants(ctx context.Context, folderID string) ([]string, error) {
+	return []string{"bob"}, nil
+}
+
+func (fakeFolders) DeleteFolder(ctx context.Context, folderID string, owner string) (int, error) {
+	return 2, nil
+}
+
+func (fakeFolders) SetFolderGrants(ctx context.Context, folderID string, owner string, logins []string) error {
+	return nil
+}
+
+// fakeMoveFiles knows no documents. Methods not used by tests panic.
+type fakeMoveFiles struct {
+	FileStorager
+}
+
+func (fakeMoveFiles) MoveDoc(ctx context.Context, docID string, owner string, folderID string) error {
+	return ErrNotFound
+}
+
+func TestFolderAudit(t *testing.T) {
+	au := &fakeAudit{}
+	m := NewModelFolders(fakeFolders{}, fakeMoveFiles{}, fakeAuth{}, au)
+	ctx := context.Background()
+
+	if _, err := m.DeleteFolder(ctx, "token", "f1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetFolderGrants(ctx, "token", "f1", []string{"bob", "carol"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.MoveDoc(ctx, "token", "d1", ""); err != ErrNotFound {
+		t.Fatalf("MoveDoc error = %v; want ErrNotFound", err)
+	}
+
+	want := []structs.AuditEvent{
+		{Actor: "alice", Action: "folder.delete", Target: "f1", Result: structs.AuditSuccess, Details: "trashed documents: 2"},
+		{Actor: "alice", Action: "folder.share", Target: "f1", Result: structs.AuditSuccess, Details: "logins: bob, carol"},
+		{Actor: "alice", Action: "doc.move", Target: "d1", Result: structs.AuditFailure, Details: "folder: root: " + ErrNotFound.Error()},
+	}
+	if len(au.events) != len(want) {
+		t.Fatalf("events = %+v; want %+v", au.events, want)
+	}
+	for i := range want {
+		if au.events[i] != want[i] {
+			t.Errorf("event %d = %+v; want %+v", i, au.events[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+)
+
+// HealthChecker checks that a dependency of the service is usable
+type HealthChecker interface {
+	Check(ctx context.Context) error
+}
+
+const defaultHealthTimeout = 2 * time.Second
+
+// Ready checks all dependencies at once, each within the timeout. The service is down if a critical one is,
+// and degraded, but still ready, if only optional ones are
+func (m *ModelHealth) Ready(ctx context.Context) structs.Readiness {
+	timeout := m.timeout
+	if timeout <= 0 {
+		timeout = defaultHealthTimeout
+	}
+
+	readiness := structs.Readiness{Status: structs.HealthOK, Checks: make(map[string]structs.DependencyStatus, len(m.critical)+len(m.optional))}
+	var criticalDown, optionalDown bool
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	run := func(checks map[string]HealthChecker, down *bool) {
+		for name, checker := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				status := check(ctx, checker, timeout)
+
+				mu.Lock()
+				defer mu.Unlock()
+				readiness.Checks[name] = status
+				if status.Status != structs.HealthOK {
+					*down = true
+				}
+			}()
+		}
+	}
+	run(m.critical, &criticalDown)
+	run(m.optional, &optionalDown)
+	wg.Wait()
+
+	switch {
+	case criticalDown:
+		readiness.Status = structs.HealthDown
+	case optionalDown:
+		readiness.Status = structs.HealthDegraded
+	}
+	return readiness
+}
+
+func check(ctx context.Context, checker HealthChecker, timeout time.Duration) structs.DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := checker.Check(ctx)
+	status := structs.DependencyStatus{Status: structs.HealthOK, LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		status.Status, status.Error = structs.HealthDown, err.Error()
+	}
+	return status
+}
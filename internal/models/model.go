@@ -1,9 +1,13 @@
 package models
 
+import "time"
+
 type ModelFiles struct {
 	fs FileStorager
 	us UsersStorager
 	as AuthStorager
+	fo FolderStorager
+	au AuditStorager
 }
 
 type ModelUsers struct {
@@ -13,14 +17,96 @@ type ModelUsers struct {
 type ModelAuth struct {
 	as AuthStorager
 	us UsersStorager
+	au AuditStorager
+}
+
+type ModelScan struct {
+	fs FileStorager
+	au AuditStorager
+	vs VirusScanner
+}
+
+type ModelUploads struct {
+	us UploadStorager
+	as AuthStorager
+	da DocAdder
+}
+
+type ModelFolders struct {
+	fo FolderStorager
+	fs FileStorager
+	as AuthStorager
+	au AuditStorager
+}
+
+type ModelShares struct {
+	sh ShareStorager
+	fs FileStorager
+	as AuthStorager
+	au AuditStorager
+}
+
+type ModelWebhooks struct {
+	ws     WebhookStorager
+	as     AuthStorager
+	sender WebhookSender
+}
+
+type ModelOutbox struct {
+	ob    OutboxStorager
+	sinks map[string]EventSink
+}
+
+type ModelFeed struct {
+	as  AuthStorager
+	hub FeedHub
+}
+
+type ModelHealth struct {
+	critical map[string]HealthChecker
+	optional map[string]HealthChecker // The service works without them, degraded
+	timeout  time.Duration
+}
+
+type ModelAudit struct {
+	au AuditStorager
+	fs FileStorager
+	as AuthStorager
 }
 
-func NewModelFiles(fs FileStorager, us UsersStorager, as AuthStorager) ModelFiles {
-	return ModelFiles{fs, us, as}
+func NewModelFiles(fs FileStorager, us UsersStorager, as AuthStorager, fo FolderStorager, au AuditStorager) ModelFiles {
+	return ModelFiles{fs, us, as, fo, au}
 }
 func NewModelUsers(us UsersStorager) ModelUsers {
 	return ModelUsers{us}
 }
-func NewModelAuth(as AuthStorager, us UsersStorager) ModelAuth {
-	return ModelAuth{as, us}
+func NewModelAuth(as AuthStorager, us UsersStorager, au AuditStorager) ModelAuth {
+	return ModelAuth{as, us, au}
+}
+func NewModelScan(fs FileStorager, au AuditStorager, vs VirusScanner) ModelScan {
+	return ModelScan{fs, au, vs}
+}
+func NewModelUploads(us UploadStorager, as AuthStorager, da DocAdder) ModelUploads {
+	return ModelUploads{us, as, da}
+}
+func NewModelFolders(fo FolderStorager, fs FileStorager, as AuthStorager, au AuditStorager) ModelFolders {
+	return ModelFolders{fo, fs, as, au}
+}
+func NewModelShares(sh ShareStorager, fs FileStorager, as AuthStorager, au AuditStorager) ModelShares {
+	return ModelShares{sh, fs, as, au}
+}
+func NewModelOutbox(ob OutboxStorager, sinks map[string]EventSink) ModelOutbox {
+	return ModelOutbox{ob, sinks}
+}
+func NewModelFeed(as AuthStorager, hub FeedHub) ModelFeed {
+	return ModelFeed{as, hub}
+}
+func NewModelAudit(au AuditStorager, fs FileStorager, as AuthStorager) ModelAudit {
+	return ModelAudit{au, fs, as}
+}
+func NewModelWebhooks(ws WebhookStorager, as AuthStorager, sender WebhookSender) ModelWebhooks {
+	return ModelWebhooks{ws, as, sender}
+}
+func NewModelHealth(critical map[string]HealthChecker, optional map[string]HealthChecker, timeout time.Duration) ModelHealth {
+	return ModelHealth{critical, optional, timeout}
 }
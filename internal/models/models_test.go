@@ -0,0 +1,14 @@
+package models
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.CreateLogger(&config.Config{Logger: config.Logger{Lvl: "error", LogRate: 1}})
+	os.Exit(m.Run())
+}
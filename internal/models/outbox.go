@@ -0,0 +1,73 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+)
+
+type OutboxStorager interface {
+	ClaimEvents(ctx context.Context, limit int) ([]structs.OutboxEntry, error)
+	MarkPublished(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, entry structs.OutboxEntry, relayErr error) error
+	PurgePublished(ctx context.Context, before time.Time) (int, error)
+}
+
+// EventSink takes document events from the outbox. The same event may come more than once,
+// sinks and their consumers tell them by the event id
+type EventSink interface {
+	Publish(ctx context.Context, event structs.DocEvent) error
+}
+
+// RelayEvents publishes due events of the outbox to every sink. An event is published again to all sinks
+// until all of them accept it, so the delivery is at least once.
+// Returns the number of published events.
+func (m *ModelOutbox) RelayEvents(ctx context.Context, limit int) (int, error) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	entries, err := m.ob.ClaimEvents(ctx, limit)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelOutbox", "RelayEvents", "ClaimEvents")
+
+		return 0, err
+	}
+
+	published := 0
+	for _, entry := range entries {
+		if err := m.publish(ctx, entry.Event); err != nil {
+			lgr.Info("event relay failed: "+err.Error(), "ModelOutbox", "RelayEvents", "publish")
+
+			if err := m.ob.MarkFailed(ctx, entry, err); err != nil {
+				lgr.Error(err.Error(), "ModelOutbox", "RelayEvents", "MarkFailed")
+			}
+			continue
+		}
+		if err := m.ob.MarkPublished(ctx, entry.ID); err != nil {
+			lgr.Error(err.Error(), "ModelOutbox", "RelayEvents", "MarkPublished")
+			continue
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+// publish hands the event to every sink, even if some of them fail
+func (m *ModelOutbox) publish(ctx context.Context, event structs.DocEvent) error {
+	var failed error
+	for name, sink := range m.sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			failed = errors.Join(failed, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return failed
+}
+
+// PurgeEvents deletes events published before the time. Returns the number of deleted ones
+func (m *ModelOutbox) PurgeEvents(ctx context.Context, before time.Time) (int, error) {
+	return m.ob.PurgePublished(ctx, before)
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+)
+
+// fakeOutbox gives pending entries and takes failed ones back, like the outbox storage does once they're due.
+// Methods not used by tests panic.
+type fakeOutbox struct {
+	OutboxStorager
+	pending   []structs.OutboxEntry
+	published []int64
+	failed    map[int64]error
+}
+
+func (f *fakeOutbox) ClaimEvents(ctx context.Context, limit int) ([]structs.OutboxEntry, error) {
+	claimed := f.pending
+	f.pending = nil
+	return claimed, nil
+}
+
+func (f *fakeOutbox) MarkPublished(ctx context.Context, id int64) error {
+	f.published = append(f.published, id)
+	return nil
+}
+
+func (f *fakeOutbox) MarkFailed(ctx context.Context, entry structs.OutboxEntry, relayErr error) error {
+	f.failed[entry.ID] = relayErr
+	entry.Attempts++
+	f.pending = append(f.pending, entry)
+	return nil
+}
+
+// fakeSink keeps ids of published events. It fails events in failing, each one once
+type fakeSink struct {
+	failing []string
+	events  []string
+}
+
+func (s *fakeSink) Publish(ctx context.Context, event structs.DocEvent) error {
+	if i := slices.Index(s.failing, event.ID); i >= 0 {
+		s.failing = slices.Delete(s.failing, i, i+1)
+		return errors.New("sink is down")
+	}
+	s.events = append(s.events, event.ID)
+	return nil
+}
+
+func TestRelayEvents(t *testing.T) {
+	ob := &fakeOutbox{
+		pending: []structs.OutboxEntry{{ID: 1, Event: structs.DocEvent{ID: "e1"}}, {ID: 2, Event: structs.DocEvent{ID: "e2"}}},
+		failed:  make(map[int64]error),
+	}
+	healthy, flaky := &fakeSink{}, &fakeSink{failing: []string{"e2"}}
+	m := NewModelOutbox(ob, map[string]EventSink{"healthy": healthy, "flaky": flaky})
+
+	published, err := m.RelayEvents(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published != 1 || !slices.Equal(ob.published, []int64{1}) {
+		t.Fatalf("published %d, %v; want only event 1", published, ob.published)
+	}
+	if err := ob.failed[2]; err == nil || !strings.Contains(err.Error(), "flaky: sink is down") {
+		t.Fatalf("failure of event 2 = %v; want the flaky sink named", err)
+	}
+	if !slices.Equal(healthy.events, []string{"e1", "e2"}) || !slices.Equal(flaky.events, []string{"e1"}) {
+		t.Fatalf("sinks got %v and %v; want the healthy one to get both", healthy.events, flaky.events)
+	}
+
+	// The failed event is published again to every sink, so the healthy one gets it twice
+	published, err = m.RelayEvents(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published != 1 || !slices.Equal(ob.published, []int64{1, 2}) {
+		t.Fatalf("published %d, %v; want event 2 too", published, ob.published)
+	}
+	if !slices.Equal(healthy.events, []string{"e1", "e2", "e2"}) || !slices.Equal(flaky.events, []string{"e1", "e2"}) {
+		t.Errorf("sinks got %v and %v; want event 2 republished to both", healthy.events, flaky.events)
+	}
+}
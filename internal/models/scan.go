@@ -0,0 +1,95 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+)
+
+type VirusScanner interface {
+	Scan(ctx context.Context, data io.Reader) (structs.ScanVerdict, error)
+}
+
+// Actor of events caused by the service itself
+const systemActor = "system"
+
+const scanBatchSize = 100
+
+// ScanPendingDocs scans documents waiting for a malware scan.
+// Clean documents become downloadable, infected ones are quarantined, ones the scanner rejects are marked failed,
+// so they don't hold the queue. A document which can't be scanned for other reasons, like the scanner being down,
+// stays pending and is retried on the next run.
+// Returns the number of scanned documents.
+func (m *ModelScan) ScanPendingDocs(ctx context.Context) (int, error) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	docs, err := m.fs.GetPendingDocs(ctx, scanBatchSize)
+	if err != nil {
+		lgr.Error(err.Error(), "ModelScan", "ScanPendingDocs", "GetPendingDocs")
+
+		return 0, err
+	}
+
+	scanned := 0
+	for _, doc := range docs {
+		if err := m.scanDoc(ctx, doc); err != nil {
+			lgr.Error(err.Error(), "ModelScan", "ScanPendingDocs", "scanDoc")
+			continue
+		}
+		scanned++
+	}
+
+	return scanned, nil
+}
+
+func (m *ModelScan) scanDoc(ctx context.Context, doc structs.PendingDoc) error {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	data, err := m.fs.OpenDoc(ctx, doc.ID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) { // Nothing to scan. The doc isn't served, the storage scanner reports it
+			lgr.Warn("blob of document "+doc.ID+" is missing, it can't be scanned", "ModelScan", "scanDoc", "OpenDoc")
+
+			return m.fs.SetScanStatus(ctx, doc.ID, structs.ScanFailed)
+		}
+		return err
+	}
+	defer data.Close()
+
+	verdict, err := m.vs.Scan(ctx, data)
+	if errors.Is(err, ErrScanRejected) {
+		lgr.Warn(fmt.Sprintf("document %s of %s can't be scanned: %s", doc.ID, doc.Owner, err), "ModelScan", "scanDoc", "Scan")
+
+		return m.fs.SetScanStatus(ctx, doc.ID, structs.ScanFailed)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !verdict.Infected {
+		return m.fs.SetScanStatus(ctx, doc.ID, structs.ScanClean)
+	}
+
+	lgr.Warn(fmt.Sprintf("document %s of %s is infected: %s", doc.ID, doc.Owner, verdict.Signature), "ModelScan", "scanDoc", "Scan")
+
+	if err := m.fs.QuarantineDoc(ctx, doc.ID); err != nil {
+		return err
+	}
+
+	err = m.au.AddEvent(ctx, structs.AuditEvent{
+		Actor:   systemActor,
+		Action:  "doc.quarantine",
+		Target:  doc.ID,
+		Result:  structs.ScanInfected,
+		Details: fmt.Sprintf("owner: %s, signature: %s", doc.Owner, verdict.Signature),
+	})
+	if err != nil {
+		lgr.Error(err.Error(), "ModelScan", "scanDoc", "AddEvent")
+	}
+
+	return nil
+}
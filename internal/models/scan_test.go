@@ -0,0 +1,94 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+)
+
+// fakeScanFiles keeps scan statuses of pending docs in memory. Methods not used by tests panic.
+type fakeScanFiles struct {
+	FileStorager
+	pending  []structs.PendingDoc
+	statuses map[string]string
+}
+
+func (f *fakeScanFiles) GetPendingDocs(ctx context.Context, limit int) ([]structs.PendingDoc, error) {
+	return f.pending, nil
+}
+
+func (f *fakeScanFiles) OpenDoc(ctx context.Context, docID string) (io.ReadSeekCloser, error) {
+	return nopReadSeekCloser{strings.NewReader(docID)}, nil
+}
+
+func (f *fakeScanFiles) SetScanStatus(ctx context.Context, docID string, status string) error {
+	f.statuses[docID] = status
+	return nil
+}
+
+type nopReadSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopReadSeekCloser) Close() error {
+	return nil
+}
+
+// fakeScanner answers with the error of the content, which is the doc id. Other content is clean
+type fakeScanner map[string]error
+
+func (s fakeScanner) Scan(ctx context.Context, data io.Reader) (structs.ScanVerdict, error) {
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return structs.ScanVerdict{}, err
+	}
+	return structs.ScanVerdict{}, s[string(content)]
+}
+
+func TestScanPendingDocs(t *testing.T) {
+	fs := &fakeScanFiles{
+		pending:  []structs.PendingDoc{{ID: "clean"}, {ID: "rejected"}, {ID: "unreachable"}},
+		statuses: make(map[string]string),
+	}
+	vs := fakeScanner{
+		"rejected":    fmt.Errorf("clamd: size limit exceeded: %w", ErrScanRejected),
+		"unreachable": errors.New("connection refused"),
+	}
+	m := NewModelScan(fs, nil, vs)
+
+	scanned, err := m.ScanPendingDocs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if scanned != 2 {
+		t.Errorf("scanned = %d; want 2", scanned)
+	}
+	want := map[string]string{"clean": structs.ScanClean, "rejected": structs.ScanFailed}
+	if len(fs.statuses) != len(want) {
+		t.Errorf("statuses = %v; want %v, the unreachable doc left pending", fs.statuses, want)
+	}
+	for id, status := range want {
+		if fs.statuses[id] != status {
+			t.Errorf("status of %s = %q; want %q", id, fs.statuses[id], status)
+		}
+	}
+}
+
+func TestCheckScan(t *testing.T) {
+	for status, want := range map[string]error{
+		structs.ScanClean:    nil,
+		structs.ScanPending:  ErrScanPending,
+		structs.ScanFailed:   ErrScanFailed,
+		structs.ScanInfected: ErrInfected,
+	} {
+		if err := checkScan(status); !errors.Is(err, want) {
+			t.Errorf("checkScan(%q) = %v; want %v", status, err, want)
+		}
+	}
+}
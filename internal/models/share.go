@@ -0,0 +1,117 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+)
+
+type ShareStorager interface {
+	CreateShareLink(ctx context.Context, link structs.ShareLink) (structs.ShareLink, error)
+	GetShareLinks(ctx context.Context, docID string, owner string) ([]structs.ShareLink, error)
+	GetShareLink(ctx context.Context, slug string, password string) (structs.ShareLink, bool, error)
+	CountShareDownload(ctx context.Context, slug string) error
+	DeleteShareLink(ctx context.Context, slug string, docID string, owner string) error
+}
+
+// CreateShareLink makes a link to the document of the token owner.
+// Returns ErrNotFound or err
+func (m *ModelShares) CreateShareLink(ctx context.Context, token string, link structs.ShareLink) (structs.ShareLink, error) {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return structs.ShareLink{}, err
+	}
+	link.Owner = login
+
+	created, err := m.sh.CreateShareLink(ctx, link)
+	addAudit(ctx, m.au, login, "share.create", link.DocID, err, "slug: "+created.Slug)
+
+	return created, err
+}
+
+// GetShareLinks returns links to the document of the token owner.
+// Returns ErrNotFound or err
+func (m *ModelShares) GetShareLinks(ctx context.Context, token string, docID string) ([]structs.ShareLink, error) {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := m.fs.GetDoc(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Owner != login { // Links of other users' documents aren't visible at all
+		return nil, ErrNotFound
+	}
+
+	return m.sh.GetShareLinks(ctx, docID, login)
+}
+
+// RevokeShareLink deletes the link to the document of the token owner.
+// Returns ErrNotFound or err
+func (m *ModelShares) RevokeShareLink(ctx context.Context, token string, docID string, slug string) error {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	err = m.sh.DeleteShareLink(ctx, slug, docID, login)
+	addAudit(ctx, m.au, login, "share.revoke", docID, err, "slug: "+slug)
+
+	return err
+}
+
+// OpenShareLink opens the document of the link and counts the download. No account is needed.
+// Returns ErrNotFound or ErrShareExpired or ErrBadSharePassword or ErrInfected or ErrScanFailed or ErrScanPending or err
+func (m *ModelShares) OpenShareLink(ctx context.Context, slug string, password string) (structs.GetDoc, error) {
+	link, passwordOK, err := m.sh.GetShareLink(ctx, slug, password)
+	if err != nil {
+		return structs.GetDoc{}, err
+	}
+
+	doc, err := m.openShareLink(ctx, link, passwordOK)
+	addAudit(ctx, m.au, anonymousActor, "share.download", link.DocID, err, "slug: "+slug)
+
+	return doc, err
+}
+
+func (m *ModelShares) openShareLink(ctx context.Context, link structs.ShareLink, passwordOK bool) (structs.GetDoc, error) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	if !link.IsUsable(time.Now()) {
+		return structs.GetDoc{}, ErrShareExpired
+	}
+	if !passwordOK {
+		return structs.GetDoc{}, ErrBadSharePassword
+	}
+
+	doc, err := m.fs.GetDoc(ctx, link.DocID)
+	if err != nil {
+		return structs.GetDoc{}, err
+	}
+	if err := checkScan(doc.Scan); err != nil {
+		return structs.GetDoc{}, err
+	}
+
+	doc.Data, err = m.fs.OpenDoc(ctx, doc.ID)
+	if err != nil {
+		return structs.GetDoc{}, err
+	}
+
+	// Counted last, so failed downloads don't use up the link
+	if err := m.sh.CountShareDownload(ctx, link.Slug); err != nil {
+		if err := doc.Data.Close(); err != nil {
+			lgr.Error(err.Error(), "ModelShares", "OpenShareLink", "Close")
+		}
+		if errors.Is(err, ErrNotFound) { // The last download was taken concurrently
+			return structs.GetDoc{}, ErrShareExpired
+		}
+		return structs.GetDoc{}, err
+	}
+
+	return doc, nil
+}
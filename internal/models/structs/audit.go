@@ -0,0 +1,40 @@
+package structs
+
+import "time"
+
+type AuditEvent struct {
+	ID        int64     `db:"id" json:"id"`
+	CreatedAt time.Time `db:"created_at" json:"created"`
+	Actor     string    `db:"actor" json:"actor"`
+	Action    string    `db:"action" json:"action"`
+	Target    string    `db:"target" json:"target"`
+	IP        string    `db:"ip" json:"ip"`
+	UserAgent string    `db:"user_agent" json:"user_agent"`
+	Result    string    `db:"result" json:"result"`
+	Details   string    `db:"details" json:"details"`
+}
+
+// Results of audit events
+const (
+	AuditSuccess = "success"
+	AuditDenied  = "denied" // Bad credentials or no access
+	AuditFailure = "failure"
+)
+
+// AuditFilter selects audit events, the newest first. Empty fields match everything
+type AuditFilter struct {
+	Actor    string
+	Action   string // Exact action or a group like "doc." ending with a dot
+	Target   string
+	Result   string
+	Since    time.Time
+	Until    time.Time
+	BeforeID int64 // Paging: only events older than this one
+	Limit    int
+}
+
+// Client describes where a request came from
+type Client struct {
+	IP        string
+	UserAgent string
+}
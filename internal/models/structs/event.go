@@ -0,0 +1,32 @@
+package structs
+
+import "time"
+
+// Types of document events
+const (
+	EventDocCreated = "doc.created"
+	EventDocUpdated = "doc.updated"
+	EventDocDeleted = "doc.deleted"
+	EventDocShared  = "doc.shared"
+)
+
+// EventTypes lists all types of document events
+var EventTypes = []string{EventDocCreated, EventDocUpdated, EventDocDeleted, EventDocShared}
+
+// DocEvent is a change of a document sent to integrations of its owner
+type DocEvent struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	DocID   string    `json:"doc_id"`
+	Owner   string    `json:"owner"`
+	Name    string    `json:"name,omitempty"`
+	Logins  []string  `json:"logins,omitempty"` // Users the document is shared with
+	Created time.Time `json:"created"`
+}
+
+// OutboxEntry is an event waiting in the outbox to be relayed
+type OutboxEntry struct {
+	ID       int64
+	Attempts int
+	Event    DocEvent
+}
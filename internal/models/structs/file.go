@@ -1,21 +1,44 @@
 package structs
 
 import (
+	"database/sql"
 	jsoniter "github.com/json-iterator/go"
+	"io"
 	"time"
 )
 
+// Scan statuses of documents
+const (
+	ScanPending  = "pending"
+	ScanClean    = "clean"
+	ScanInfected = "infected"
+	ScanFailed   = "failed" // The blob is missing or the scanner has rejected it
+)
+
 type GetDoc struct {
-	Mime   string `db:"mime" json:"mime"`
-	Name   string `db:"title" json:"title"`
-	Public bool   `db:"is_public" json:"public"`
-	Owner  string `db:"owner" json:"owner"`
-	IsFile bool   `db:"file" json:"is_file"`
+	ID     string            `db:"id" json:"id"`
+	Mime   string            `db:"mime" json:"mime"`
+	Name   string            `db:"title" json:"title"`
+	Public bool              `db:"is_public" json:"public"`
+	Owner  string            `db:"owner" json:"owner"`
+	IsFile bool              `db:"file" json:"is_file"`
+	Scan   string            `db:"scan_status" json:"scan_status"`
+	SHA256 string            `db:"sha256" json:"sha256"`
+	MD5    string            `db:"md5" json:"md5"`
+	Data   io.ReadSeekCloser `db:"-" json:"-"`
 }
 
 type File struct {
-	Meta DocMeta
-	Json jsoniter.RawMessage
+	ID      string
+	Meta    DocMeta
+	Json    jsoniter.RawMessage
+	Data    io.Reader
+	SHA256  string
+	MD5     string
+	WithMD5 bool // Compute MD5 in addition to SHA-256
+
+	KeyID      string // Master key which wrapped the data key. Empty if the doc isn't encrypted
+	WrappedKey []byte
 }
 type DocMeta struct {
 	Name   string   `json:"name"`
@@ -24,6 +47,19 @@ type DocMeta struct {
 	Token  string   `json:"token"`
 	Mime   string   `json:"mime"`
 	Grant  []string `json:"grant"`
+	SHA256 string   `json:"sha256"` // Optional checksums supplied by the client. Upload fails on mismatch
+	MD5    string   `json:"md5"`
+	Folder string   `json:"folder_id"` // Empty for the root
+
+	Tags     []string          `json:"tags"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// DocUpdate holds new values of the document fields. Nil fields are kept
+type DocUpdate struct {
+	Name   *string
+	Public *bool
+	Mime   *string
 }
 
 type RmDoc struct {
@@ -31,20 +67,89 @@ type RmDoc struct {
 	Name string `db:"title"`
 }
 
+// TrashedDoc is a doc waiting in the trash to be purged
+type TrashedDoc struct {
+	ID    string `db:"id"`
+	Owner string `db:"owner"`
+}
+
 type DocEntry struct {
-	ID      string    `json:"id" db:"id"`
-	Name    string    `json:"name" db:"title"`
-	Mime    string    `json:"mime" db:"mime"`
-	IsFile  bool      `json:"file"`
-	Public  bool      `json:"public" db:"is_public"`
-	Created time.Time `json:"created" db:"created_at"`
-	Granted []string  `json:"grant"`
+	ID      string     `json:"id" db:"id"`
+	Name    string     `json:"name" db:"title"`
+	Mime    string     `json:"mime" db:"mime"`
+	IsFile  bool       `json:"file"`
+	Public  bool       `json:"public" db:"is_public"`
+	Created time.Time  `json:"created" db:"created_at"`
+	Granted []string   `json:"grant"`
+	Deleted *time.Time `json:"deleted,omitempty" db:"deleted_at"`
+	SHA256  string     `json:"sha256,omitempty" db:"sha256"`
+	MD5     string     `json:"md5,omitempty" db:"md5"`
+	Folder  string     `json:"folder_id,omitempty" db:"folder_id"`
+
+	Tags     Tags     `json:"tags,omitempty" db:"tags"`
+	Metadata Metadata `json:"metadata,omitempty" db:"metadata"`
 }
 
 type ListInfo struct {
-	Token string `json:"token"`
-	Login string `json:"login"`
-	Key   string `json:"key"`
-	Value string `json:"value"`
-	Limit int    `json:"limit"`
+	Token  string `json:"token"`
+	Login  string `json:"login"`
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Limit  int    `json:"limit"`
+	Folder string `json:"folder"` // Folder id or "root". Empty means all folders
+}
+
+// BulkResult is the outcome of a bulk operation for one document
+type BulkResult struct {
+	ID  string
+	Err error
+}
+
+// StagedFile is a blob left in the staging area by an unfinished put or delete.
+type StagedFile struct {
+	Name string
+	Op   string
+	Key  string
+}
+
+// DocBlob is a document row as seen by the storage scanner
+type DocBlob struct {
+	ID     string         `db:"id"`
+	Name   string         `db:"title"`
+	SHA256 sql.NullString `db:"sha256"`
+	Scan   string         `db:"scan_status"`
+}
+
+// DocKey is the wrapped data key of an encrypted doc
+type DocKey struct {
+	ID         string         `db:"id"`
+	KeyID      sql.NullString `db:"key_id"`
+	WrappedKey []byte         `db:"wrapped_key"`
+}
+
+// PendingDoc is a doc waiting to be scanned for malware
+type PendingDoc struct {
+	ID    string `db:"id"`
+	Owner string `db:"owner"`
+}
+
+// ScanVerdict is the result of a malware scan
+type ScanVerdict struct {
+	Infected  bool
+	Signature string
+}
+
+// ScanOptions defines what the storage scanner does with orphaned blobs
+type ScanOptions struct {
+	Action     string // report, quarantine or delete
+	DryRun     bool
+	VerifyHash bool
+}
+
+// ScanReport is the result of a storage scan
+type ScanReport struct {
+	OrphanFiles    []string  // Blobs without rows
+	MissingFiles   []DocBlob // Rows without blobs
+	HashMismatches []DocBlob
+	Handled        int // Orphans quarantined or deleted
 }
@@ -0,0 +1,21 @@
+package structs
+
+import "time"
+
+// RootFolder selects documents which aren't in any folder
+const RootFolder = "root"
+
+type Folder struct {
+	ID        string    `db:"id"`
+	Owner     string    `db:"owner"`
+	ParentID  string    `db:"parent_id"` // Empty for root folders
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// FolderView is a folder with its path from the root and its subfolders
+type FolderView struct {
+	Folder      Folder
+	Breadcrumbs []Folder // From the root folder down to Folder itself
+	Subfolders  []Folder
+}
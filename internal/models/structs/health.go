@@ -0,0 +1,20 @@
+package structs
+
+// Statuses of readiness and of its dependencies
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded" // Optional dependencies are down, the service works without them
+	HealthDown     = "down"
+	HealthStopping = "stopping" // The service is shutting down, dependencies aren't checked
+)
+
+type DependencyStatus struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+type Readiness struct {
+	Status string                      `json:"status"`
+	Checks map[string]DependencyStatus `json:"checks"`
+}
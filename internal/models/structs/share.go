@@ -0,0 +1,24 @@
+package structs
+
+import "time"
+
+// ShareLink gives access to a document by its slug without an account
+type ShareLink struct {
+	Slug         string     `db:"slug"`
+	DocID        string     `db:"document_id"`
+	Owner        string     `db:"owner"`
+	Password     string     `db:"-"` // Set only for a new link. Only the hash is stored
+	HasPassword  bool       `db:"has_password"`
+	ExpiresAt    *time.Time `db:"expires_at"`    // nil means the link never expires
+	MaxDownloads *int       `db:"max_downloads"` // nil means no limit
+	Downloads    int        `db:"downloads"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+// IsUsable checks whether the link isn't expired and has downloads left
+func (l ShareLink) IsUsable(now time.Time) bool {
+	if l.ExpiresAt != nil && !l.ExpiresAt.After(now) {
+		return false
+	}
+	return l.MaxDownloads == nil || l.Downloads < *l.MaxDownloads
+}
@@ -0,0 +1,43 @@
+package structs
+
+import (
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// MetadataKeyPrefix selects documents by a metadata key when listing, like "meta.project"
+const MetadataKeyPrefix = "meta."
+
+// Tags are user defined labels of a document. They are read from the database as a json array.
+type Tags []string
+
+func (t *Tags) Scan(src any) error {
+	return scanJSON(src, t)
+}
+
+// Metadata is user defined key/value pairs of a document. They are read from the database as a json object.
+type Metadata map[string]string
+
+func (m *Metadata) Scan(src any) error {
+	return scanJSON(src, m)
+}
+
+func scanJSON(src any, dest any) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case string:
+		return jsoniter.UnmarshalFromString(v, dest)
+	case []byte:
+		return jsoniter.Unmarshal(v, dest)
+	default:
+		return fmt.Errorf("can't scan %T as json", src)
+	}
+}
+
+// TagCount is a tag with the number of documents it's set on
+type TagCount struct {
+	Tag   string `db:"tag" json:"tag"`
+	Count int    `db:"count" json:"count"`
+}
@@ -0,0 +1,23 @@
+package structs
+
+import (
+	jsoniter "github.com/json-iterator/go"
+	"time"
+)
+
+// Upload is a resumable upload in progress. It becomes a document once all Length bytes are received.
+type Upload struct {
+	ID        string              `db:"id"`
+	Owner     string              `db:"owner"`
+	Meta      DocMeta             `db:"-"`
+	Json      jsoniter.RawMessage `db:"content"`
+	Length    int64               `db:"upload_length"`
+	Offset    int64               `db:"upload_offset"`
+	CreatedAt time.Time           `db:"created_at"`
+	ExpiresAt time.Time           `db:"expires_at"`
+}
+
+// IsComplete reports whether all bytes of the upload have been received
+func (u Upload) IsComplete() bool {
+	return u.Offset == u.Length
+}
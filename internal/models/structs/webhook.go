@@ -0,0 +1,48 @@
+package structs
+
+import "time"
+
+// Statuses of webhook deliveries
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead" // Out of attempts, may be retried by the owner
+)
+
+type Webhook struct {
+	ID        string    `db:"id"`
+	Owner     string    `db:"owner"`
+	URL       string    `db:"url"`
+	Secret    string    `db:"secret"`
+	Events    Tags      `db:"events"` // Empty means all events
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID            int64      `db:"id" json:"id"`
+	WebhookID     string     `db:"webhook_id" json:"webhook_id"`
+	EventID       string     `db:"event_id" json:"event_id"`
+	Event         string     `db:"event" json:"event"`
+	Payload       string     `db:"payload" json:"-"`
+	Status        string     `db:"status" json:"status"`
+	Attempts      int        `db:"attempts" json:"attempts"`
+	NextAttemptAt time.Time  `db:"next_attempt_at" json:"next_attempt"`
+	ResponseCode  int        `db:"response_code" json:"response_code"`
+	LastError     string     `db:"last_error" json:"last_error,omitempty"`
+	CreatedAt     time.Time  `db:"created_at" json:"created"`
+	DeliveredAt   *time.Time `db:"delivered_at" json:"delivered,omitempty"`
+}
+
+// DueDelivery is a delivery claimed for sending with its endpoint
+type DueDelivery struct {
+	WebhookDelivery
+	URL    string `db:"url"`
+	Secret string `db:"secret"`
+}
+
+// DeliveryFilter selects deliveries of a webhook, the newest first
+type DeliveryFilter struct {
+	Status   string // Empty matches all
+	BeforeID int64
+	Limit    int
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"io"
+)
+
+type UploadStorager interface {
+	CreateUpload(ctx context.Context, up structs.Upload) (structs.Upload, error)
+	GetUpload(ctx context.Context, uploadID string, owner string) (structs.Upload, error)
+	WriteChunk(ctx context.Context, uploadID string, owner string, offset int64, src io.Reader) (structs.Upload, error)
+	OpenUpload(ctx context.Context, uploadID string, owner string) (structs.Upload, io.ReadSeekCloser, error)
+	StartFinishing(ctx context.Context, uploadID string, owner string) error
+	StopFinishing(ctx context.Context, uploadID string, owner string) error
+	DeleteUpload(ctx context.Context, uploadID string, owner string) error
+}
+
+// DocAdder turns a finished upload into a document
+type DocAdder interface {
+	AddNewDoc(ctx context.Context, doc structs.File) (string, error)
+}
+
+func (m *ModelUploads) CreateUpload(ctx context.Context, token string, up structs.Upload) (structs.Upload, error) {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return structs.Upload{}, err
+	}
+
+	up.Owner = login
+	up.Meta.Token = "" // Never stored, the token of the finishing request is used
+	return m.us.CreateUpload(ctx, up)
+}
+
+func (m *ModelUploads) GetUpload(ctx context.Context, token string, uploadID string) (structs.Upload, error) {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return structs.Upload{}, err
+	}
+
+	return m.us.GetUpload(ctx, uploadID, login)
+}
+
+func (m *ModelUploads) WriteChunk(ctx context.Context, token string, uploadID string, offset int64, src io.Reader) (structs.Upload, error) {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return structs.Upload{}, err
+	}
+
+	return m.us.WriteChunk(ctx, uploadID, login, offset, src)
+}
+
+// OpenUpload returns a complete upload with its received bytes. The caller must close them.
+func (m *ModelUploads) OpenUpload(ctx context.Context, token string, uploadID string) (structs.Upload, io.ReadSeekCloser, error) {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return structs.Upload{}, nil, err
+	}
+
+	return m.us.OpenUpload(ctx, uploadID, login)
+}
+
+// FinishUpload adds doc built from the upload the same way as a regular upload and removes the upload.
+// The upload is kept if the doc can't be added, so finishing can be retried.
+// Returns ErrUploadFinishing if another request is finishing it, or errors of AddNewDoc
+func (m *ModelUploads) FinishUpload(ctx context.Context, token string, uploadID string, doc structs.File) error {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if err := m.us.StartFinishing(ctx, uploadID, login); err != nil {
+		return err
+	}
+	doc.Meta.Token = token
+	if _, err := m.da.AddNewDoc(ctx, doc); err != nil {
+		if err := m.us.StopFinishing(context.WithoutCancel(ctx), uploadID, login); err != nil {
+			lgr.Error(err.Error(), "ModelUploads", "FinishUpload", "StopFinishing")
+		}
+		return err
+	}
+
+	// The doc is added already, so an upload purged meanwhile is just as good as removed
+	if err := m.us.DeleteUpload(ctx, uploadID, login); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (m *ModelUploads) DeleteUpload(ctx context.Context, token string, uploadID string) error {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return m.us.DeleteUpload(ctx, uploadID, login)
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+)
+
+// fakeUploadStore finishes any upload. DeleteUpload fails with deleteErr. Methods not used by tests panic.
+type fakeUploadStore struct {
+	UploadStorager
+	deleteErr error
+}
+
+func (fakeUploadStore) StartFinishing(ctx context.Context, uploadID string, owner string) error {
+	return nil
+}
+
+func (f fakeUploadStore) DeleteUpload(ctx context.Context, uploadID string, owner string) error {
+	return f.deleteErr
+}
+
+type fakeDocAdder struct{}
+
+func (fakeDocAdder) AddNewDoc(ctx context.Context, doc structs.File) (string, error) {
+	return "d1", nil
+}
+
+func TestFinishUploadDelete(t *testing.T) {
+	failure := errors.New("connection refused")
+	tests := []struct {
+		name      string
+		deleteErr error
+		want      error
+	}{
+		{"deleted", nil, nil},
+		{"purged meanwhile", ErrNotFound, nil},
+		{"delete failed", failure, failure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewModelUploads(fakeUploadStore{deleteErr: tt.deleteErr}, fakeTokens{}, fakeDocAdder{})
+
+			if err := m.FinishUpload(context.Background(), "alice", "u1", structs.File{}); err != tt.want {
+				t.Errorf("FinishUpload error = %v; want %v", err, tt.want)
+			}
+		})
+	}
+}
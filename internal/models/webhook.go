@@ -0,0 +1,123 @@
+package models
+
+import (
+	"context"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+)
+
+type WebhookStorager interface {
+	CreateWebhook(ctx context.Context, owner string, url string, events []string) (structs.Webhook, error)
+	GetWebhooks(ctx context.Context, owner string) ([]structs.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID string, owner string) error
+	ClaimDueDeliveries(ctx context.Context, limit int) ([]structs.DueDelivery, error)
+	MarkDelivered(ctx context.Context, deliveryID int64, code int) error
+	MarkFailed(ctx context.Context, delivery structs.DueDelivery, code int, sendErr error) error
+	GetDeliveries(ctx context.Context, webhookID string, owner string, filter structs.DeliveryFilter) ([]structs.WebhookDelivery, error)
+	RetryDelivery(ctx context.Context, deliveryID int64, webhookID string, owner string) error
+}
+
+type WebhookSender interface {
+	Send(ctx context.Context, delivery structs.DueDelivery) (int, error)
+}
+
+// deliveryBatchSize is the max number of deliveries sent in a run
+const deliveryBatchSize = 50
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+// CreateWebhook registers the endpoint of the token owner. Empty events subscribe to all of them
+func (m *ModelWebhooks) CreateWebhook(ctx context.Context, token string, url string, events []string) (structs.Webhook, error) {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return structs.Webhook{}, err
+	}
+
+	return m.ws.CreateWebhook(ctx, login, url, events)
+}
+
+func (m *ModelWebhooks) GetWebhooks(ctx context.Context, token string) ([]structs.Webhook, error) {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.ws.GetWebhooks(ctx, login)
+}
+
+// Returns ErrNotFound or err
+func (m *ModelWebhooks) DeleteWebhook(ctx context.Context, token string, webhookID string) error {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return m.ws.DeleteWebhook(ctx, webhookID, login)
+}
+
+// GetDeliveries returns the delivery history of the webhook. Dead deliveries are selected by DeliveryDead status
+func (m *ModelWebhooks) GetDeliveries(ctx context.Context, token string, webhookID string, filter structs.DeliveryFilter) ([]structs.WebhookDelivery, error) {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultDeliveriesLimit
+	}
+	filter.Limit = min(filter.Limit, maxDeliveriesLimit)
+
+	return m.ws.GetDeliveries(ctx, webhookID, login, filter)
+}
+
+// RetryDelivery sends the dead delivery again with fresh attempts.
+// Returns ErrNotFound or err
+func (m *ModelWebhooks) RetryDelivery(ctx context.Context, token string, webhookID string, deliveryID int64) error {
+	login, err := m.as.GetUserLoginBySecret(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return m.ws.RetryDelivery(ctx, deliveryID, webhookID, login)
+}
+
+// DeliverWebhooks sends due deliveries. Failed ones are retried later with backoff.
+// Deliveries are claimed one at a time, so a slow endpoint doesn't hold others' deliveries.
+// Returns the number of delivered ones.
+func (m *ModelWebhooks) DeliverWebhooks(ctx context.Context) (int, error) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	delivered := 0
+	for range deliveryBatchSize {
+		claimed, err := m.ws.ClaimDueDeliveries(ctx, 1)
+		if err != nil {
+			lgr.Error(err.Error(), "ModelWebhooks", "DeliverWebhooks", "ClaimDueDeliveries")
+
+			return delivered, err
+		}
+		if len(claimed) == 0 {
+			break
+		}
+		delivery := claimed[0]
+
+		code, err := m.sender.Send(ctx, delivery)
+		if err != nil {
+			lgr.Info("webhook delivery failed: "+err.Error(), "ModelWebhooks", "DeliverWebhooks", "Send")
+
+			if err := m.ws.MarkFailed(ctx, delivery, code, err); err != nil {
+				lgr.Error(err.Error(), "ModelWebhooks", "DeliverWebhooks", "MarkFailed")
+			}
+			continue
+		}
+		if err := m.ws.MarkDelivered(ctx, delivery.ID, code); err != nil {
+			lgr.Error(err.Error(), "ModelWebhooks", "DeliverWebhooks", "MarkDelivered")
+			continue
+		}
+		delivered++
+	}
+
+	return delivered, nil
+}
@@ -3,15 +3,42 @@ package middleware
 import (
 	"context"
 	"github.com/Kapeland/task-Astral/internal/utils/logger"
-	"github.com/chenyahui/gin-cache/persist"
 	"github.com/gin-gonic/gin"
+	"net/http"
 )
 
-func CachePurge(ctx context.Context, store *persist.RedisStore, lgr *logger.Logger) gin.HandlerFunc {
+// CachePurger removes cached responses. Patterns are the ones of Redis, matched against request URIs
+type CachePurger interface {
+	PurgeAll(ctx context.Context) error
+	PurgeMatching(ctx context.Context, patterns []string) error
+}
+
+// CachePurge removes all cached responses. A failed purge doesn't fail the request
+func CachePurge(ctx context.Context, store CachePurger, lgr *logger.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		err := store.RedisClient.FlushAll(ctx).Err()
-		if err != nil {
-			lgr.Error(err.Error(), "cache_purge", "CachePurge", "FlushAll")
+		lgr := lgr.WithContext(c.Request.Context())
+
+		if err := store.PurgeAll(ctx); err != nil {
+			lgr.Error(err.Error(), "cache_purge", "CachePurge", "PurgeAll")
+		}
+	}
+}
+
+// CachePurgeDoc removes cached document lists once the request changing a document has succeeded.
+// Documents themselves aren't cached, other cached responses are kept.
+func CachePurgeDoc(ctx context.Context, store CachePurger, lgr *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lgr := lgr.WithContext(c.Request.Context())
+
+		c.Next()
+		if c.Writer.Status() >= http.StatusBadRequest {
+			return
+		}
+
+		// Keys are request URIs, see cache.CacheByRequestURI
+		patterns := []string{"/api/docs?*", "/api/folders*"}
+		if err := store.PurgeMatching(ctx, patterns); err != nil {
+			lgr.Error(err.Error(), "cache_purge", "CachePurgeDoc", "PurgeMatching")
 		}
 	}
 }
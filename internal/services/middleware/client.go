@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/gin-gonic/gin"
+)
+
+// Client puts the IP and the user agent of the client into the request context for the audit log
+func Client() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := models.WithClient(c.Request.Context(), structs.Client{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -7,4 +7,5 @@ const (
 	NotAuthToken     = "There is no authorized person with this token"
 	TokenExpired     = "Token expired"
 	ServErr          = "Internal server error"
+	TooManyRequests  = "Too many requests, try again later"
 )
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	myErrs "github.com/Kapeland/task-Astral/internal/services/middleware/errors"
+	"github.com/Kapeland/task-Astral/internal/services/middleware/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/metrics"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute labels requests to unknown paths, so they don't make a series per path
+const unmatchedRoute = "unmatched"
+
+func routeOf(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return unmatchedRoute
+}
+
+// Metrics measures latency and status of requests by route pattern
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(),
+			c.Request.Method, routeOf(c), strconv.Itoa(c.Writer.Status()))
+	}
+}
+
+// CacheHit and CacheMiss count lookups of the response cache. They are callbacks of cache.CacheByRequestURI
+func CacheHit(c *gin.Context) {
+	metrics.CacheRequests.Inc(routeOf(c), metrics.CacheHit)
+}
+
+func CacheMiss(c *gin.Context) {
+	metrics.CacheRequests.Inc(routeOf(c), metrics.CacheMiss)
+}
+
+// BearerToken lets through requests with the token in the Authorization header. An empty token lets all through
+func BearerToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+token)) != 1 {
+			abortWithErr(c, http.StatusUnauthorized, structs.ErrResponse{Err: structs.ErrBody{
+				Code: 401,
+				Text: myErrs.NotAuthToken,
+			}})
+			return
+		}
+		c.Next()
+	}
+}
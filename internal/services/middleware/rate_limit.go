@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+// idleBucketTTL is how long a bucket is kept after its last request
+const idleBucketTTL = 10 * time.Minute
+
+// maxBuckets bounds the memory of a limiter when many clients come at once. The least recently seen bucket
+// is dropped for a new one, so its client gets a full burst again.
+const maxBuckets = 100_000
+
+// RateLimiter is a token bucket per key, like a client IP. It's kept in memory of a single instance.
+type RateLimiter struct {
+	rate       float64
+	burst      float64
+	maxBuckets int
+	mu         sync.Mutex
+	buckets    map[string]*list.Element
+	recent     *list.List // Buckets from the most recently seen to the least
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter allows rate requests per second with bursts of up to burst requests for every key
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:       rate,
+		burst:      math.Max(float64(burst), 1),
+		maxBuckets: maxBuckets,
+		buckets:    make(map[string]*list.Element),
+		recent:     list.New(),
+	}
+}
+
+// Allow takes a token from the bucket of key if there is one
+func (l *RateLimiter) Allow(key string) bool {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	var b *bucket
+	if e, ok := l.buckets[key]; ok {
+		l.recent.MoveToFront(e)
+		b = e.Value.(*bucket)
+	} else {
+		if l.recent.Len() >= l.maxBuckets {
+			l.remove(l.recent.Back())
+		}
+		b = &bucket{key: key, tokens: l.burst, last: now}
+		l.buckets[key] = l.recent.PushFront(b)
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep removes buckets of keys which haven't been seen for a while. They are at the back of the list.
+func (l *RateLimiter) sweep(now time.Time) {
+	for e := l.recent.Back(); e != nil && now.Sub(e.Value.(*bucket).last) > idleBucketTTL; e = l.recent.Back() {
+		l.remove(e)
+	}
+}
+
+func (l *RateLimiter) remove(e *list.Element) {
+	l.recent.Remove(e)
+	delete(l.buckets, e.Value.(*bucket).key)
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	m.Run()
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	l := NewRateLimiter(0.001, 3)
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow("a") {
+			t.Fatalf("request %d of the burst is denied", i+1)
+		}
+	}
+	if l.Allow("a") {
+		t.Errorf("request after the burst is allowed")
+	}
+	if !l.Allow("b") {
+		t.Errorf("another key is denied")
+	}
+}
+
+func TestRateLimiterEvictsLeastRecent(t *testing.T) {
+	l := NewRateLimiter(0.001, 1)
+	l.maxBuckets = 2
+
+	l.Allow("a")
+	l.Allow("b")
+	l.Allow("a") // b is the least recent now
+	l.Allow("c")
+
+	if len(l.buckets) != 2 || l.recent.Len() != 2 {
+		t.Fatalf("%d buckets, %d in the list; want 2", len(l.buckets), l.recent.Len())
+	}
+	if _, ok := l.buckets["b"]; ok {
+		t.Errorf("b is kept; want it evicted")
+	}
+	if l.Allow("a") {
+		t.Errorf("a got a new burst; want its bucket kept")
+	}
+}
+
+func TestRateLimiterSweepsIdle(t *testing.T) {
+	l := NewRateLimiter(0.001, 1)
+	l.Allow("idle")
+	l.Allow("busy")
+	l.buckets["idle"].Value.(*bucket).last = time.Now().Add(-2 * idleBucketTTL)
+
+	l.Allow("busy")
+
+	if _, ok := l.buckets["idle"]; ok {
+		t.Errorf("idle bucket is kept")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Errorf("busy bucket is swept")
+	}
+}
+
+func TestLimitByIPTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		want    int // Status of the second request from another forwarded IP
+	}{
+		{"no trusted proxies", nil, http.StatusTooManyRequests},
+		{"trusted proxy", []string{"192.0.2.1"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			if err := router.SetTrustedProxies(tt.proxies); err != nil {
+				t.Fatal(err)
+			}
+			router.GET("/", LimitByIP(NewRateLimiter(0.001, 1)), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			codes := make([]int, 0, 2)
+			for _, ip := range []string{"203.0.113.1", "203.0.113.2"} {
+				rec := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, "/?token=anything", nil)
+				req.RemoteAddr = "192.0.2.1:1234"
+				req.Header.Set("X-Forwarded-For", ip)
+				router.ServeHTTP(rec, req)
+				codes = append(codes, rec.Code)
+			}
+
+			if codes[0] != http.StatusOK || codes[1] != tt.want {
+				t.Errorf("statuses = %v; want [200 %d]", codes, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitAnonymousSkipsTokens(t *testing.T) {
+	router := gin.New()
+	router.GET("/", LimitAnonymous(NewRateLimiter(0.001, 1)), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for i, target := range []string{"/", "/?token=t", "/?token=t", "/"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+		want := http.StatusOK
+		if i == 3 {
+			want = http.StatusTooManyRequests
+		}
+		if rec.Code != want {
+			t.Errorf("request %d to %s: status = %d; want %d", i+1, target, rec.Code, want)
+		}
+	}
+}
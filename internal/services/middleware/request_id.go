@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"github.com/Kapeland/task-Astral/internal/services/middleware/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the id of the request both ways
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLen = 128
+
+// isRequestIDValid accepts ids of printable ASCII, so they are safe to echo and to log
+func isRequestIDValid(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID keeps the X-Request-ID of the client or makes a new one. The id is echoed in the response header
+// and attached to log lines and errors of the request
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !isRequestIDValid(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		c.Request = c.Request.WithContext(logger.WithRequest(c.Request.Context(), requestID, routeOf(c)))
+		c.Next()
+	}
+}
+
+// abortWithErr stops the request with the error, which carries the id of the request
+func abortWithErr(c *gin.Context, status int, resp structs.ErrResponse) {
+	resp.Err.RequestID = logger.RequestID(c.Request.Context())
+	c.AbortWithStatusJSON(status, resp)
+}
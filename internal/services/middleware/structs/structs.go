@@ -5,8 +5,9 @@ type ErrResponse struct {
 }
 
 type ErrBody struct {
-	Code int    `json:"code"`
-	Text string `json:"text"`
+	Code      int    `json:"code"`
+	Text      string `json:"text"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 type DocMeta struct {
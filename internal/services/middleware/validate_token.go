@@ -14,25 +14,29 @@ import (
 
 func ValidateTokenInMultipartFrom(a servers.AuthModelManager, lgr *logger.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		lgr := lgr.WithContext(c.Request.Context())
+
 		form, err := c.MultipartForm()
 
 		if err != nil {
 			lgr.Error(err.Error(), "validate_token", "ValidateTokenInMultipartFrom", "MultipartForm")
-			c.AbortWithStatusJSON(http.StatusBadRequest, structs.ErrResponse{Err: structs.ErrBody{
+			abortWithErr(c, http.StatusBadRequest, structs.ErrResponse{Err: structs.ErrBody{
 				Code: 400,
 				Text: myErrs.BadMultipartForm,
 			}})
+			return
 		}
 
 		meta, ok := form.Value["meta"]
 
-		if !ok {
+		if !ok || len(meta) == 0 {
 			lgr.Error(myErrs.NoMeta, "validate_token", "ValidateTokenInMultipartFrom", "MultipartForm")
 
-			c.AbortWithStatusJSON(http.StatusBadRequest, structs.ErrResponse{Err: structs.ErrBody{
+			abortWithErr(c, http.StatusBadRequest, structs.ErrResponse{Err: structs.ErrBody{
 				Code: 400,
 				Text: myErrs.NoMeta,
 			}})
+			return
 		}
 
 		varMeta := structs.DocMeta{}
@@ -41,10 +45,11 @@ func ValidateTokenInMultipartFrom(a servers.AuthModelManager, lgr *logger.Logger
 		if err != nil {
 			lgr.Error(err.Error(), "validate_token", "ValidateTokenInMultipartFrom", "jsoniter.Unmarshal")
 
-			c.AbortWithStatusJSON(http.StatusBadRequest, structs.ErrResponse{Err: structs.ErrBody{
+			abortWithErr(c, http.StatusBadRequest, structs.ErrResponse{Err: structs.ErrBody{
 				Code: 400,
 				Text: myErrs.BadMeta,
 			}})
+			return
 		}
 
 		valid, err := a.ValidateToken(c.Request.Context(), varMeta.Token)
@@ -52,22 +57,25 @@ func ValidateTokenInMultipartFrom(a servers.AuthModelManager, lgr *logger.Logger
 		if !valid {
 			switch {
 			case errors.Is(err, models.ErrInvalidToken):
-				c.AbortWithStatusJSON(http.StatusUnauthorized, structs.ErrResponse{Err: structs.ErrBody{
+				abortWithErr(c, http.StatusUnauthorized, structs.ErrResponse{Err: structs.ErrBody{
 					Code: 401,
 					Text: myErrs.NotAuthToken,
 				}})
+				return
 			case errors.Is(err, models.ErrTokenExpired):
-				c.AbortWithStatusJSON(http.StatusUnauthorized, structs.ErrResponse{Err: structs.ErrBody{
+				abortWithErr(c, http.StatusUnauthorized, structs.ErrResponse{Err: structs.ErrBody{
 					Code: 401,
 					Text: myErrs.TokenExpired,
 				}})
+				return
 			default:
 				lgr.Error(err.Error(), "validate_token", "ValidateTokenInMultipartFrom", "ValidateToken")
 
-				c.AbortWithStatusJSON(http.StatusInternalServerError, structs.ErrResponse{Err: structs.ErrBody{
+				abortWithErr(c, http.StatusInternalServerError, structs.ErrResponse{Err: structs.ErrBody{
 					Code: 500,
 					Text: myErrs.ServErr,
 				}})
+				return
 			}
 		}
 		c.Next()
@@ -76,6 +84,8 @@ func ValidateTokenInMultipartFrom(a servers.AuthModelManager, lgr *logger.Logger
 
 func ValidateTokenInQuery(a servers.AuthModelManager, lgr *logger.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		lgr := lgr.WithContext(c.Request.Context())
+
 		token := c.Query("token")
 
 		valid, err := a.ValidateToken(c.Request.Context(), token)
@@ -83,13 +93,13 @@ func ValidateTokenInQuery(a servers.AuthModelManager, lgr *logger.Logger) gin.Ha
 		if !valid {
 			switch {
 			case errors.Is(err, models.ErrInvalidToken):
-				c.AbortWithStatusJSON(http.StatusUnauthorized, structs.ErrResponse{Err: structs.ErrBody{
+				abortWithErr(c, http.StatusUnauthorized, structs.ErrResponse{Err: structs.ErrBody{
 					Code: 401,
 					Text: myErrs.NotAuthToken,
 				}})
 				return
 			case errors.Is(err, models.ErrTokenExpired):
-				c.AbortWithStatusJSON(http.StatusUnauthorized, structs.ErrResponse{Err: structs.ErrBody{
+				abortWithErr(c, http.StatusUnauthorized, structs.ErrResponse{Err: structs.ErrBody{
 					Code: 401,
 					Text: myErrs.TokenExpired,
 				}})
@@ -97,7 +107,7 @@ func ValidateTokenInQuery(a servers.AuthModelManager, lgr *logger.Logger) gin.Ha
 			default:
 				lgr.Error(err.Error(), "validate_token", "ValidateTokenInQuery", "ValidateToken")
 
-				c.AbortWithStatusJSON(http.StatusInternalServerError, structs.ErrResponse{Err: structs.ErrBody{
+				abortWithErr(c, http.StatusInternalServerError, structs.ErrResponse{Err: structs.ErrBody{
 					Code: 500,
 					Text: myErrs.ServErr,
 				}})
@@ -107,3 +117,43 @@ func ValidateTokenInQuery(a servers.AuthModelManager, lgr *logger.Logger) gin.Ha
 		c.Next()
 	}
 }
+
+// ValidateTokenOrAnonymous lets requests without a token through, handlers serve only public documents to them.
+// A given token is validated as usual.
+func ValidateTokenOrAnonymous(a servers.AuthModelManager, lgr *logger.Logger) gin.HandlerFunc {
+	validate := ValidateTokenInQuery(a, lgr)
+	return func(c *gin.Context) {
+		if c.Query("token") == "" {
+			c.Next()
+			return
+		}
+		validate(c)
+	}
+}
+
+// LimitAnonymous limits requests without a token by client IP. Requests with a token aren't limited.
+// nil limiter limits nothing.
+func LimitAnonymous(l *RateLimiter) gin.HandlerFunc {
+	limit := LimitByIP(l)
+	return func(c *gin.Context) {
+		if c.Query("token") != "" {
+			c.Next()
+			return
+		}
+		limit(c)
+	}
+}
+
+// LimitByIP limits all requests by client IP, with a token or without. nil limiter limits nothing.
+func LimitByIP(l *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l != nil && !l.Allow(c.ClientIP()) {
+			abortWithErr(c, http.StatusTooManyRequests, structs.ErrResponse{Err: structs.ErrBody{
+				Code: 429,
+				Text: myErrs.TooManyRequests,
+			}})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,146 @@
+package servers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	svStruct "github.com/Kapeland/task-Astral/internal/services/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type AuditModelManager interface {
+	GetEvents(ctx context.Context, filter structs.AuditFilter) ([]structs.AuditEvent, error)
+	GetDocActivity(ctx context.Context, token string, docID string, filter structs.AuditFilter) ([]structs.AuditEvent, error)
+}
+
+type AuditServer struct {
+	Au         AuditModelManager
+	AdminToken string // The log is given only for it, empty token gives it to nobody
+}
+
+// parseAuditFilter reads filter and paging params of the query. since and until are RFC 3339 times
+func parseAuditFilter(c *gin.Context) (structs.AuditFilter, bool) {
+	filter := structs.AuditFilter{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+		Target: c.Query("target"),
+		Result: c.Query("result"),
+	}
+
+	var err error
+	if v := c.Query("since"); v != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return structs.AuditFilter{}, false
+		}
+	}
+	if v := c.Query("until"); v != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return structs.AuditFilter{}, false
+		}
+	}
+	if v := c.Query("before"); v != "" {
+		if filter.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil || filter.BeforeID <= 0 {
+			return structs.AuditFilter{}, false
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 0 {
+			return structs.AuditFilter{}, false
+		}
+	}
+
+	return filter, true
+}
+
+// toAuditResp adds the cursor of the next page. An empty page is the last one
+func toAuditResp(events []structs.AuditEvent) svStruct.AuditResp {
+	if len(events) == 0 {
+		return svStruct.AuditResp{Data: svStruct.AuditBody{Events: []structs.AuditEvent{}}}
+	}
+	return svStruct.AuditResp{Data: svStruct.AuditBody{
+		Events: events,
+		Next:   strconv.FormatInt(events[len(events)-1].ID, 10),
+	}}
+}
+
+// GetEvents returns the audit log to the admin. Events are paged from the newest by 'before' and 'limit'
+func (s *AuditServer) GetEvents(c *gin.Context) {
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
+
+	if s.AdminToken == "" || subtle.ConstantTimeCompare([]byte(s.AdminToken), []byte(c.Query("token"))) != 1 { // It's not admin
+		lgr.Info("Not admin", "auditServer", "GetEvents", "")
+
+		errJSON(c, http.StatusForbidden, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 403,
+			Text: "Not admin",
+		}})
+		return
+	}
+
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad filter",
+		}})
+		return
+	}
+
+	events, err := s.Au.GetEvents(c.Request.Context(), filter)
+	if err != nil {
+		lgr.Error(err.Error(), "auditServer", "GetEvents", "GetEvents")
+
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Internal server error",
+		}})
+		return
+	}
+
+	c.JSON(http.StatusOK, toAuditResp(events))
+}
+
+// GetDocActivity returns events of the document to its owner
+func (s *AuditServer) GetDocActivity(c *gin.Context) {
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
+
+	docID, found := paramID(c, "id")
+	if !found {
+		return
+	}
+
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad filter",
+		}})
+		return
+	}
+
+	events, err := s.Au.GetDocActivity(c.Request.Context(), c.Query("token"), docID, filter)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			errJSON(c, http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 404,
+				Text: "Looks like there is no such document",
+			}})
+			return
+		}
+		lgr.Error(err.Error(), "auditServer", "GetDocActivity", "GetDocActivity")
+
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Internal server error",
+		}})
+		return
+	}
+
+	c.JSON(http.StatusOK, toAuditResp(events))
+}
@@ -0,0 +1,47 @@
+package servers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/gin-gonic/gin"
+)
+
+// fakeAuditModel returns no events. Methods not used by tests panic.
+type fakeAuditModel struct {
+	AuditModelManager
+}
+
+func (fakeAuditModel) GetEvents(ctx context.Context, filter structs.AuditFilter) ([]structs.AuditEvent, error) {
+	return nil, nil
+}
+
+func TestGetEventsAdminToken(t *testing.T) {
+	tests := []struct {
+		name, adminToken, token string
+		want                    int
+	}{
+		{"admin", "adm1n", "adm1n", http.StatusOK},
+		{"wrong token", "adm1n", "adm1m", http.StatusForbidden},
+		{"prefix of the token", "adm1n", "adm1", http.StatusForbidden},
+		{"no token", "adm1n", "", http.StatusForbidden},
+		{"no admin configured", "", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &AuditServer{Au: fakeAuditModel{}, AdminToken: tt.adminToken}
+			router := gin.New()
+			router.GET("/api/admin/audit", s.GetEvents)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/admin/audit?token="+tt.token, nil))
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d; want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
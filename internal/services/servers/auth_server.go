@@ -81,12 +81,12 @@ func (s *AuthServer) Register(c *gin.Context) {
 	pswd := c.Query("pswd")
 	cfg := config.GetConfig()
 
-	lgr := logger.GetLogger()
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
 
 	if cfg.Admin.Token != token { // It's not admin
 		lgr.Info("Not admin", "authServer", "Register", "")
 
-		c.JSON(http.StatusForbidden, structs2.ErrResponse{Err: structs2.ErrBody{
+		errJSON(c, http.StatusForbidden, structs2.ErrResponse{Err: structs2.ErrBody{
 			Code: 403,
 			Text: "Not admin",
 		}})
@@ -95,7 +95,7 @@ func (s *AuthServer) Register(c *gin.Context) {
 	if !IsLoginPswdValid(login, pswd) { // bad password or login
 		lgr.Info("Bad pass or login", "authServer", "Register", "IsLoginPswdValid")
 
-		c.JSON(http.StatusBadRequest, structs2.ErrResponse{Err: structs2.ErrBody{
+		errJSON(c, http.StatusBadRequest, structs2.ErrResponse{Err: structs2.ErrBody{
 			Code: 400,
 			Text: "Bad pass or login",
 		}})
@@ -111,7 +111,7 @@ func (s *AuthServer) Register(c *gin.Context) {
 	if status == http.StatusBadRequest {
 		lgr.Info("item already exists", "authServer", "Register", "register")
 
-		c.JSON(status, structs2.ErrResponse{Err: structs2.ErrBody{
+		errJSON(c, status, structs2.ErrResponse{Err: structs2.ErrBody{
 			Code: status,
 			Text: "User already exists",
 		}})
@@ -119,18 +119,18 @@ func (s *AuthServer) Register(c *gin.Context) {
 	}
 	if status == http.StatusInternalServerError {
 		lgr.Error("internal server error", "authServer", "Register", "register")
-		c.JSON(status, structs2.ErrResponse{Err: structs2.ErrBody{
+		errJSON(c, status, structs2.ErrResponse{Err: structs2.ErrBody{
 			Code: status,
 			Text: "Internal server error",
 		}})
 		return
 	}
 
-	c.JSON(http.StatusOK, structs2.RegisterResp{structs2.RegisterRespBody{login}})
+	c.JSON(http.StatusOK, structs2.RegisterResp{Resp: structs2.RegisterRespBody{Login: login}})
 }
 
 func (s *AuthServer) register(ctx context.Context, info structs.RegisterUserInfo) int {
-	lgr := logger.GetLogger()
+	lgr := logger.GetLogger().WithContext(ctx)
 
 	err := s.A.RegisterUser(ctx, info)
 	if err != nil {
@@ -147,7 +147,7 @@ func (s *AuthServer) register(ctx context.Context, info structs.RegisterUserInfo
 }
 
 func (s *AuthServer) Auth(c *gin.Context) {
-	lgr := logger.GetLogger()
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
 
 	login := c.PostForm("login")
 	pswd := c.PostForm("pswd")
@@ -155,7 +155,7 @@ func (s *AuthServer) Auth(c *gin.Context) {
 	if !IsLoginPswdValid(login, pswd) { // bad password or login
 		lgr.Info("Bad pass or login", "authServer", "Auth", "")
 
-		c.JSON(http.StatusBadRequest, structs2.ErrResponse{Err: structs2.ErrBody{
+		errJSON(c, http.StatusBadRequest, structs2.ErrResponse{Err: structs2.ErrBody{
 			Code: 400,
 			Text: "Bad pass or login",
 		}})
@@ -169,15 +169,15 @@ func (s *AuthServer) Auth(c *gin.Context) {
 	token, status, errResp := s.auth(c.Request.Context(), userInfo)
 
 	if status != http.StatusOK {
-		c.JSON(status, errResp)
+		errJSON(c, status, errResp)
 		return
 	}
 
-	c.JSON(http.StatusOK, structs2.AuthResp{structs2.AuthRespBody{token}})
+	c.JSON(http.StatusOK, structs2.AuthResp{Resp: structs2.AuthRespBody{Token: token}})
 }
 
 func (s *AuthServer) auth(ctx context.Context, info structs.AuthUserInfo) (string, int, structs2.ErrResponse) {
-	lgr := logger.GetLogger()
+	lgr := logger.GetLogger().WithContext(ctx)
 
 	token, err := s.A.LoginUser(ctx, info)
 	if err != nil {
@@ -205,22 +205,22 @@ func (s *AuthServer) auth(ctx context.Context, info structs.AuthUserInfo) (strin
 }
 
 func (s *AuthServer) Logout(c *gin.Context) {
-	lgr := logger.GetLogger()
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
 
 	tokenP := c.Param("token")
 
 	token, status, errResp := s.logout(c.Request.Context(), tokenP)
 
 	if status != http.StatusOK {
-		c.JSON(status, errResp)
+		errJSON(c, status, errResp)
 		return
 	}
 
-	newData, err := jsoniter.Marshal(structs2.LogoutResp{jsoniter.RawMessage(fmt.Sprintf("{\"%s\":true}", token))})
+	newData, err := jsoniter.Marshal(structs2.LogoutResp{Resp: jsoniter.RawMessage(fmt.Sprintf("{\"%s\":true}", token))})
 	if err != nil {
 		lgr.Error(err.Error(), "authServer", "Logout", "soniter.Marshal")
 
-		c.JSON(http.StatusInternalServerError, structs2.ErrResponse{Err: structs2.ErrBody{
+		errJSON(c, http.StatusInternalServerError, structs2.ErrResponse{Err: structs2.ErrBody{
 			Code: 500,
 			Text: "Can't marshal JSON",
 		}})
@@ -232,7 +232,7 @@ func (s *AuthServer) Logout(c *gin.Context) {
 	if err != nil {
 		lgr.Error(err.Error(), "authServer", "Logout", "soniter.Unmarshal")
 
-		c.JSON(http.StatusInternalServerError, structs2.ErrResponse{Err: structs2.ErrBody{
+		errJSON(c, http.StatusInternalServerError, structs2.ErrResponse{Err: structs2.ErrBody{
 			Code: 500,
 			Text: "Can't unmarshal JSON",
 		}})
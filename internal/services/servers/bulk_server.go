@@ -0,0 +1,371 @@
+package servers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	svStruct "github.com/Kapeland/task-Astral/internal/services/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// maxBulkItems limits the number of documents handled by one bulk request
+const maxBulkItems = 100
+
+const (
+	archiveZip   = "zip"
+	archiveTarGz = "tar.gz"
+)
+
+// bulkErrText turns an error of a single document into a client facing text
+func bulkErrText(err error) string {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return "Looks like there is no such document"
+	case errors.Is(err, models.ErrConflict):
+		return "Duplicated doc"
+	case errors.Is(err, models.ErrInvalidInput):
+		return "Bad grants"
+	case errors.Is(err, models.ErrChecksumMismatch):
+		return "Checksum mismatch"
+	case errors.Is(err, models.ErrFolderNotFound):
+		return "Looks like there is no such folder"
+	default:
+		return "Internal server error"
+	}
+}
+
+// bulkErrCode returns the status code of an error of a single document
+func bulkErrCode(err error) int {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrConflict), errors.Is(err, models.ErrInvalidInput),
+		errors.Is(err, models.ErrChecksumMismatch), errors.Is(err, models.ErrFolderNotFound):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// parseDocIDs splits comma separated ids, dropping empty ones and duplicates
+func parseDocIDs(s string) []string {
+	seen := make(map[string]bool)
+	ids := make([]string, 0)
+	for _, id := range strings.Split(s, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// firstBadID returns the first of ids which isn't a UUID, ok is false if there is one
+func firstBadID(ids []string) (string, bool) {
+	for _, id := range ids {
+		if !isIDValid(id) {
+			return id, false
+		}
+	}
+	return "", true
+}
+
+// BulkDeleteDocs moves several documents to the trash and reports the result for each of them
+func (s *FileServer) BulkDeleteDocs(c *gin.Context) {
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
+
+	token := c.Query("token")
+
+	req := svStruct.BulkDeleteReq{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad request body",
+		}})
+		return
+	}
+	ids := parseDocIDs(strings.Join(req.IDs, ","))
+	if len(ids) == 0 || len(ids) > maxBulkItems {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: fmt.Sprintf("From 1 to %d ids are expected", maxBulkItems),
+		}})
+		return
+	}
+	if id, ok := firstBadID(ids); !ok {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: fmt.Sprintf("%q isn't a document id", id),
+		}})
+		return
+	}
+
+	results, err := s.F.DeleteDocs(c.Request.Context(), token, ids)
+	if err != nil {
+		lgr.Error(err.Error(), "fileServer", "BulkDeleteDocs", "DeleteDocs")
+
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Internal server error",
+		}})
+		return
+	}
+
+	resp := svStruct.BulkResp{Data: svStruct.BulkBody{Results: make([]svStruct.BulkItem, len(results))}}
+	for i, res := range results {
+		item := svStruct.BulkItem{ID: res.ID, OK: res.Err == nil}
+		if res.Err != nil {
+			item.Code, item.Error = bulkErrCode(res.Err), bulkErrText(res.Err)
+			if item.Code == http.StatusInternalServerError {
+				lgr.Error(res.Err.Error(), "fileServer", "BulkDeleteDocs", "DeleteDocs")
+			}
+		}
+		resp.Data.Results[i] = item
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// UploadDocs stores every 'file' part of the form as a separate document named after the part.
+// The meta is shared by all documents, except for the name and the checksums.
+func (s *FileServer) UploadDocs(c *gin.Context) {
+	form, _ := c.MultipartForm() // mw has checked it
+
+	files := form.File["file"]
+	if len(files) == 0 || len(files) > maxBulkItems {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: fmt.Sprintf("From 1 to %d 'file' parts are expected", maxBulkItems),
+		}})
+		return
+	}
+
+	meta := form.Value["meta"]
+	if len(meta) == 0 {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "No meta",
+		}})
+		return
+	}
+	varMeta := svStruct.DocMeta{}
+	jsoniter.Unmarshal([]byte(meta[0]), &varMeta) // mw will check error
+	if varMeta.SHA256 != "" || varMeta.MD5 != "" {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Checksums can't be shared by several files",
+		}})
+		return
+	}
+	var ok bool
+	if varMeta.Tags, ok = normalizeTags(varMeta.Tags); !ok || !isMetadataValid(varMeta.Metadata) {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad tags or metadata",
+		}})
+		return
+	}
+	if !isFolderIDValid(varMeta.Folder) {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Looks like there is no such folder",
+		}})
+		return
+	}
+	var jsn jsoniter.RawMessage
+	if values := form.Value["json"]; len(values) > 0 {
+		jsn = jsoniter.RawMessage(values[0])
+	}
+
+	resp := svStruct.BulkResp{Data: svStruct.BulkBody{Results: make([]svStruct.BulkItem, len(files))}}
+	for i, fh := range files {
+		item := svStruct.BulkItem{Name: fh.Filename}
+		if !isDocNameValid(fh.Filename) {
+			item.Code, item.Error = http.StatusBadRequest, "Bad document name"
+			resp.Data.Results[i] = item
+			continue
+		}
+
+		docID, errResp := s.uploadPart(c.Request.Context(), fh, varMeta, jsn)
+		item.ID, item.OK, item.Code, item.Error = docID, docID != "", errResp.Err.Code, errResp.Err.Text
+		resp.Data.Results[i] = item
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// uploadPart stores one part of a multi-file upload and returns the id of the new doc
+func (s *FileServer) uploadPart(ctx context.Context, fh *multipart.FileHeader, meta svStruct.DocMeta, jsn jsoniter.RawMessage) (string, svStruct.ErrResponse) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	name := fh.Filename
+	src, err := fh.Open()
+	if err != nil {
+		lgr.Error(err.Error(), "fileServer", "uploadPart", "Open")
+
+		return "", svStruct.ErrResponse{Err: svStruct.ErrBody{Code: 500, Text: "Can't read uploaded file"}}
+	}
+	defer src.Close()
+
+	detectedMime, status, errResp := detectMime(ctx, src, "", name, meta.File, s.Upload)
+	if status != http.StatusOK {
+		return "", errResp
+	}
+	meta.Name, meta.Mime = name, detectedMime
+
+	docID, err := s.F.AddNewDoc(ctx, structs.File{
+		Meta:    structs.DocMeta(meta),
+		Json:    jsn,
+		Data:    src,
+		WithMD5: s.Upload.MD5,
+	})
+	if err != nil {
+		code := bulkErrCode(err)
+		if code == http.StatusInternalServerError {
+			lgr.Error(err.Error(), "fileServer", "uploadPart", "AddNewDoc")
+		}
+		return "", svStruct.ErrResponse{Err: svStruct.ErrBody{Code: code, Text: bulkErrText(err)}}
+	}
+
+	return docID, svStruct.ErrResponse{}
+}
+
+// GetDocsArchive streams the selected documents as a zip or tar.gz archive.
+// Documents the caller can't read are left out.
+func (s *FileServer) GetDocsArchive(c *gin.Context) {
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
+
+	token := c.Query("token")
+	format := c.DefaultQuery("format", archiveZip)
+	if format != archiveZip && format != archiveTarGz {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "format must be zip or tar.gz",
+		}})
+		return
+	}
+	ids := parseDocIDs(c.Query("ids"))
+	if len(ids) == 0 || len(ids) > maxBulkItems {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: fmt.Sprintf("From 1 to %d ids are expected", maxBulkItems),
+		}})
+		return
+	}
+	if id, ok := firstBadID(ids); !ok {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: fmt.Sprintf("%q isn't a document id", id),
+		}})
+		return
+	}
+
+	docs, err := s.F.OpenDocs(c.Request.Context(), token, ids)
+	if err != nil {
+		lgr.Error(err.Error(), "fileServer", "GetDocsArchive", "OpenDocs")
+
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Internal server error",
+		}})
+		return
+	}
+	defer func() {
+		for _, doc := range docs {
+			doc.Data.Close()
+		}
+	}()
+	if len(docs) == 0 {
+		errJSON(c, http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 404,
+			Text: "Looks like there are no such documents",
+		}})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "documents." + format}))
+	c.Header("Cache-Control", "no-store")
+	if format == archiveZip {
+		c.Header("Content-Type", "application/zip")
+		err = writeZip(c.Writer, docs)
+	} else {
+		c.Header("Content-Type", "application/gzip")
+		err = writeTarGz(c.Writer, docs)
+	}
+	if err != nil { // Headers are sent already, so the client gets a truncated archive
+		lgr.Error(err.Error(), "fileServer", "GetDocsArchive", "write archive")
+	}
+}
+
+// archiveNames returns unique names of entries. Documents may share names, so "name (2).ext" and so on are used.
+func archiveNames(docs []structs.GetDoc) []string {
+	used := make(map[string]bool)
+	names := make([]string, len(docs))
+	for i, doc := range docs {
+		name := strings.ReplaceAll(doc.Name, "/", "_")
+		if !doc.IsFile && path.Ext(name) == "" {
+			name += ".json"
+		}
+		ext := path.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s (%d)%s", base, n, ext)
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+func writeZip(w io.Writer, docs []structs.GetDoc) error {
+	zw := zip.NewWriter(w)
+	for i, name := range archiveNames(docs) {
+		entry, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(entry, docs[i].Data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeTarGz(w io.Writer, docs []structs.GetDoc) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for i, name := range archiveNames(docs) {
+		size, err := docs[i].Data.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		if _, err := docs[i].Data.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		err = tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: time.Now(), Typeflag: tar.TypeReg})
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, docs[i].Data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
@@ -0,0 +1,100 @@
+package servers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	svStruct "github.com/Kapeland/task-Astral/internal/services/structs"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// fakeFileModel fails AddNewDoc of documents named in errs. Methods not used by tests panic.
+type fakeFileModel struct {
+	FileModelManager
+	errs map[string]error
+}
+
+func (f *fakeFileModel) AddNewDoc(ctx context.Context, doc structs.File) (string, error) {
+	if err := f.errs[doc.Meta.Name]; err != nil {
+		return "", err
+	}
+	return "id-" + doc.Meta.Name, nil
+}
+
+func newBulkRequest(t *testing.T, meta string, names ...string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if meta != "" {
+		w.WriteField("meta", meta)
+	}
+	for _, name := range names {
+		part, err := w.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte("content of " + name))
+	}
+	w.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/docs/bulk", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func TestUploadDocs(t *testing.T) {
+	s := &FileServer{F: &fakeFileModel{errs: map[string]error{
+		"dup.txt":    models.ErrConflict,
+		"broken.txt": errors.New("connection reset"),
+	}}}
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	longName := strings.Repeat("x", maxDocNameLen+1)
+	c.Request = newBulkRequest(t, `{"file": true}`, "ok.txt", "dup.txt", "broken.txt", longName)
+
+	s.UploadDocs(c)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; want 200", rec.Code)
+	}
+	resp := svStruct.BulkResp{}
+	if err := jsoniter.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	want := []svStruct.BulkItem{
+		{ID: "id-ok.txt", Name: "ok.txt", OK: true},
+		{Name: "dup.txt", Code: http.StatusBadRequest, Error: "Duplicated doc"},
+		{Name: "broken.txt", Code: http.StatusInternalServerError, Error: "Internal server error"},
+		{Name: longName, Code: http.StatusBadRequest, Error: "Bad document name"},
+	}
+	if len(resp.Data.Results) != len(want) {
+		t.Fatalf("results = %+v; want %+v", resp.Data.Results, want)
+	}
+	for i := range want {
+		if resp.Data.Results[i] != want[i] {
+			t.Errorf("result %d = %+v; want %+v", i, resp.Data.Results[i], want[i])
+		}
+	}
+}
+
+func TestUploadDocsWithoutMeta(t *testing.T) {
+	s := &FileServer{F: &fakeFileModel{}}
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = newBulkRequest(t, "", "ok.txt")
+
+	s.UploadDocs(c)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d; want 400", rec.Code)
+	}
+}
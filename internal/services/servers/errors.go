@@ -0,0 +1,13 @@
+package servers
+
+import (
+	svStruct "github.com/Kapeland/task-Astral/internal/services/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// errJSON writes the error with the id of the request, so a client can quote it when reporting a problem
+func errJSON(c *gin.Context, status int, resp svStruct.ErrResponse) {
+	resp.Err.RequestID = logger.RequestID(c.Request.Context())
+	c.JSON(status, resp)
+}
@@ -0,0 +1,149 @@
+package servers
+
+import (
+	"context"
+	"fmt"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	svStruct "github.com/Kapeland/task-Astral/internal/services/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"net/http"
+	"time"
+)
+
+type FeedModelManager interface {
+	Subscribe(ctx context.Context, token string) (<-chan structs.DocEvent, func(), error)
+}
+
+type FeedServer struct {
+	Fd             FeedModelManager
+	Heartbeat      time.Duration // Keeps idle connections open through proxies
+	AllowedOrigins []string      // Origins of other sites whose pages may open the websocket
+	WebSockets     *WebSockets   // Websockets are closed through it on shutdown
+}
+
+const defaultFeedHeartbeat = 25 * time.Second
+
+func (s *FeedServer) heartbeat() time.Duration {
+	if s.Heartbeat <= 0 {
+		return defaultFeedHeartbeat
+	}
+	return s.Heartbeat
+}
+
+// GetEvents streams events of documents of the user and of documents shared with the user.
+// The stream is Server-Sent Events, or a websocket if the client asks to upgrade.
+// The stream ends if the client can't keep up, then the client should list documents again
+func (s *FeedServer) GetEvents(c *gin.Context) {
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
+
+	if isWebSocketUpgrade(c.Request) && !isOriginAllowed(c.Request, s.AllowedOrigins) {
+		errJSON(c, http.StatusForbidden, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 403,
+			Text: "Origin is not allowed",
+		}})
+		return
+	}
+
+	events, unsubscribe, err := s.Fd.Subscribe(c.Request.Context(), c.Query("token"))
+	if err != nil {
+		lgr.Error(err.Error(), "feedServer", "GetEvents", "Subscribe")
+
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Internal server error",
+		}})
+		return
+	}
+	defer unsubscribe()
+
+	if isWebSocketUpgrade(c.Request) {
+		s.streamWebSocket(c, events)
+		return
+	}
+	s.streamSSE(c, events)
+}
+
+func (s *FeedServer) streamSSE(c *gin.Context, events <-chan structs.DocEvent) {
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-store")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // Stops nginx from buffering the stream
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(s.heartbeat())
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := jsoniter.Marshal(event)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func (s *FeedServer) streamWebSocket(c *gin.Context, events <-chan structs.DocEvent) {
+	ws, ok := upgradeWebSocket(c.Writer, c.Request)
+	if !ok {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad websocket handshake",
+		}})
+		return
+	}
+	defer ws.Close()
+	s.WebSockets.add(ws)
+	defer s.WebSockets.remove(ws)
+
+	closed := make(chan struct{})
+	go func() {
+		ws.ReadLoop()
+		close(closed)
+	}()
+
+	heartbeat := time.NewTicker(s.heartbeat())
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-events:
+			if !ok {
+				_ = ws.WriteMessage(wsClose, nil)
+				return
+			}
+			data, err := jsoniter.Marshal(event)
+			if err != nil {
+				return
+			}
+			if err := ws.WriteMessage(wsText, data); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := ws.WriteMessage(wsPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
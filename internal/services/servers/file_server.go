@@ -2,33 +2,98 @@ package servers
 
 import (
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/Kapeland/task-Astral/internal/models"
 	"github.com/Kapeland/task-Astral/internal/models/structs"
 	svStruct "github.com/Kapeland/task-Astral/internal/services/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/config"
 	"github.com/Kapeland/task-Astral/internal/utils/logger"
 	"github.com/gin-gonic/gin"
 	jsoniter "github.com/json-iterator/go"
+	"io"
+	"mime"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 type FileModelManager interface {
-	AddNewDoc(ctx context.Context, doc structs.File) error
+	AddNewDoc(ctx context.Context, doc structs.File) (string, error)
 	DeleteDoc(ctx context.Context, token string, docID string) (structs.RmDoc, error)
 	GetDocs(ctx context.Context, listInfo structs.ListInfo) ([]structs.DocEntry, error)
 	GetDoc(ctx context.Context, token string, docID string) (structs.GetDoc, error)
+	GetPublicDocs(ctx context.Context, listInfo structs.ListInfo) ([]structs.DocEntry, error)
+	GetPublicDoc(ctx context.Context, docID string) (structs.GetDoc, error)
+	RestoreDoc(ctx context.Context, token string, docID string) (structs.RmDoc, error)
+	GetTrash(ctx context.Context, token string) ([]structs.DocEntry, error)
+	DeleteDocs(ctx context.Context, token string, docIDs []string) ([]structs.BulkResult, error)
+	OpenDocs(ctx context.Context, token string, docIDs []string) ([]structs.GetDoc, error)
+	UpdateDoc(ctx context.Context, token string, docID string, upd structs.DocUpdate) error
+	SetDocTags(ctx context.Context, token string, docID string, tags []string) error
+	SetDocMetadata(ctx context.Context, token string, docID string, metadata map[string]string) error
+	GetTags(ctx context.Context, token string, prefix string, limit int) ([]structs.TagCount, error)
 }
 
 type FileServer struct {
-	F FileModelManager
-	A AuthModelManager
+	F      FileModelManager
+	A      AuthModelManager
+	Upload config.Upload
+}
+
+const maxDocNameLen = 255
+
+// isDocNameValid checks the display name of a document.
+// It's never used as a path, but it's still echoed in headers and listings.
+func isDocNameValid(s string) bool {
+	if s == "" || len(s) > maxDocNameLen || !utf8.ValidString(s) {
+		return false
+	}
+	if s == "." || s == ".." || strings.TrimSpace(s) != s {
+		return false
+	}
+	for _, c := range s {
+		if unicode.IsControl(c) || c == '/' || c == '\\' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isChecksumValid checks a hex encoded checksum supplied by the client. It's optional, so empty one is valid.
+func isChecksumValid(s string, size int) bool {
+	if s == "" {
+		return true
+	}
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == size
+}
+
+// setDigestHeaders sets ETag and Digest of the document content. Documents uploaded before checksums were stored have none.
+func setDigestHeaders(h http.Header, sha256Hex string, md5Hex string) {
+	var digests []string
+	if sum, err := hex.DecodeString(sha256Hex); err == nil && len(sum) == sha256.Size {
+		h.Set("ETag", `"`+sha256Hex+`"`)
+		digests = append(digests, "sha-256="+base64.StdEncoding.EncodeToString(sum))
+	}
+	if sum, err := hex.DecodeString(md5Hex); err == nil && len(sum) == md5.Size {
+		digests = append(digests, "md5="+base64.StdEncoding.EncodeToString(sum))
+	}
+	if len(digests) > 0 {
+		h.Set("Digest", strings.Join(digests, ","))
+	}
 }
 
 func (s *FileServer) UploadDoc(c *gin.Context) {
-	lgr := logger.GetLogger()
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
 
 	form, _ := c.MultipartForm()
 
@@ -47,7 +112,7 @@ func (s *FileServer) UploadDoc(c *gin.Context) {
 	if !ok {
 		lgr.Error("no 'file' in multipart/form", "fileServer", "UploadDoc", "MultipartForm")
 
-		c.JSON(http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
 			Code: 400,
 			Text: "no 'file' in multipart/form",
 		}})
@@ -58,43 +123,77 @@ func (s *FileServer) UploadDoc(c *gin.Context) {
 
 	jsoniter.Unmarshal([]byte(meta[0]), &varMeta) // mw will check error
 
-	doc := svStruct.AddDocForm{
-		Meta: varMeta,
-		Json: jsoniter.RawMessage(jsn[0]),
+	if !isDocNameValid(varMeta.Name) {
+		lgr.Info("Bad document name", "fileServer", "UploadDoc", "isDocNameValid")
+
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad document name",
+		}})
+		return
 	}
 
-	if doc.Meta.File {
-		err := c.SaveUploadedFile(file[0], "./file-storage/"+doc.Meta.Name)
-		if err != nil {
-			lgr.Error(err.Error(), "fileServer", "UploadDoc", "SaveUploadedFile")
+	if !isChecksumValid(varMeta.SHA256, sha256.Size) || !isChecksumValid(varMeta.MD5, md5.Size) {
+		lgr.Info("Bad checksum", "fileServer", "UploadDoc", "isChecksumValid")
 
-			c.JSON(http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
-				Code: 500,
-				Text: "Can't save uploaded file",
-			}})
-			return
-		}
-	} else {
-		err := c.SaveUploadedFile(file[0], "./file-storage/json/"+doc.Meta.Name)
-		if err != nil {
-			lgr.Error(err.Error(), "fileServer", "UploadDoc", "SaveUploadedFile")
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad checksum",
+		}})
+		return
+	}
 
-			c.JSON(http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
-				Code: 500,
-				Text: "Can't save uploaded file",
-			}})
-			return
-		}
+	if varMeta.Tags, ok = normalizeTags(varMeta.Tags); !ok || !isMetadataValid(varMeta.Metadata) {
+		lgr.Info("Bad tags or metadata", "fileServer", "UploadDoc", "normalizeTags")
+
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad tags or metadata",
+		}})
+		return
+	}
+	if !isFolderIDValid(varMeta.Folder) {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Looks like there is no such folder",
+		}})
+		return
 	}
 
-	status, errResp := s.uploadDoc(c.Request.Context(), doc)
+	src, err := file[0].Open()
+	if err != nil {
+		lgr.Error(err.Error(), "fileServer", "UploadDoc", "Open")
 
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Can't read uploaded file",
+		}})
+		return
+	}
+	defer src.Close()
+
+	detectedMime, status, errResp := detectMime(c.Request.Context(), src, varMeta.Mime, varMeta.Name, varMeta.File, s.Upload)
 	if status != http.StatusOK {
-		c.JSON(status, errResp)
+		lgr.Info(errResp.Err.Text, "fileServer", "UploadDoc", "detectMime")
+
+		errJSON(c, status, errResp)
+		return
+	}
+	varMeta.Mime = detectedMime
+
+	doc := svStruct.AddDocForm{
+		Meta: varMeta,
+		Json: jsoniter.RawMessage(jsn[0]),
+		Data: src,
+	}
+
+	status, errResp = s.uploadDoc(c.Request.Context(), doc)
+
+	if status != http.StatusOK {
+		errJSON(c, status, errResp)
 		return
 	}
 	var newData []byte
-	var err error
 	if containsJSON {
 		newData, err = jsoniter.Marshal(svStruct.AddDocResp{Data: svStruct.DocData{
 			JSON: jsoniter.RawMessage(jsn[0]),
@@ -110,7 +209,7 @@ func (s *FileServer) UploadDoc(c *gin.Context) {
 	if err != nil {
 		lgr.Error(err.Error(), "fileServer", "UploadDoc", "jsoniter.Marshal")
 
-		c.JSON(http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
 			Code: 500,
 			Text: "Can't marshal response",
 		}})
@@ -121,7 +220,7 @@ func (s *FileServer) UploadDoc(c *gin.Context) {
 	if err != nil {
 		lgr.Error(err.Error(), "fileServer", "UploadDoc", "jsoniter.Unmarshal")
 
-		c.JSON(http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
 			Code: 500,
 			Text: "Can't unmarshal response",
 		}})
@@ -132,11 +231,13 @@ func (s *FileServer) UploadDoc(c *gin.Context) {
 }
 
 func (s *FileServer) uploadDoc(ctx context.Context, doc svStruct.AddDocForm) (int, svStruct.ErrResponse) {
-	lgr := logger.GetLogger()
+	lgr := logger.GetLogger().WithContext(ctx)
 
-	err := s.F.AddNewDoc(ctx, structs.File{
-		Meta: structs.DocMeta(doc.Meta),
-		Json: doc.Json,
+	_, err := s.F.AddNewDoc(ctx, structs.File{
+		Meta:    structs.DocMeta(doc.Meta),
+		Json:    doc.Json,
+		Data:    doc.Data,
+		WithMD5: s.Upload.MD5 || doc.Meta.MD5 != "",
 	})
 	if err != nil {
 		switch {
@@ -155,6 +256,20 @@ func (s *FileServer) uploadDoc(ctx context.Context, doc svStruct.AddDocForm) (in
 				Code: 400,
 				Text: "Duplicated doc",
 			}}
+		case errors.Is(err, models.ErrFolderNotFound):
+			lgr.Info("No such folder", "fileServer", "uploadDoc", "AddNewDoc")
+
+			return http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 400,
+				Text: "Looks like there is no such folder",
+			}}
+		case errors.Is(err, models.ErrChecksumMismatch):
+			lgr.Info("Checksum mismatch", "fileServer", "uploadDoc", "AddNewDoc")
+
+			return http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 400,
+				Text: "Checksum mismatch",
+			}}
 		case errors.Is(err, models.ErrInvalidInput):
 			lgr.Info("Can't set grants to unexisting user", "fileServer", "uploadDoc", "AddNewDoc")
 
@@ -177,23 +292,26 @@ func (s *FileServer) uploadDoc(ctx context.Context, doc svStruct.AddDocForm) (in
 }
 
 func (s *FileServer) DeleteDoc(c *gin.Context) {
-	lgr := logger.GetLogger()
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
 
-	docID := c.Param("id")
+	docID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
 	token := c.Query("token")
 
 	doc, status, errResp := s.deleteDoc(c.Request.Context(), token, docID)
 
 	if status != http.StatusOK {
-		c.JSON(status, errResp)
+		errJSON(c, status, errResp)
 		return
 	}
 
-	newData, err := jsoniter.Marshal(svStruct.LogoutResp{jsoniter.RawMessage(fmt.Sprintf("{\"%s\":true}", doc.ID))})
+	newData, err := jsoniter.Marshal(svStruct.LogoutResp{Resp: jsoniter.RawMessage(fmt.Sprintf("{\"%s\":true}", doc.ID))})
 	if err != nil {
 		lgr.Error(err.Error(), "fileServer", "DeleteDoc", "jsoniter.Marshal")
 
-		c.JSON(http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
 			Code: 500,
 			Text: "Can't marshal response",
 		}})
@@ -205,30 +323,19 @@ func (s *FileServer) DeleteDoc(c *gin.Context) {
 	if err != nil {
 		lgr.Error(err.Error(), "fileServer", "DeleteDoc", "jsoniter.Unmarshal")
 
-		c.JSON(http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
 			Code: 500,
 			Text: "Can't unmarshal response",
 		}})
 		return
 	}
 
-	err = os.Remove("./file-storage/" + doc.Name)
-	if err != nil {
-		lgr.Error(err.Error(), "fileServer", "DeleteDoc", "Remove")
-
-		c.JSON(http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
-			Code: 500,
-			Text: "Can't remove file from FS",
-		}})
-		return
-	}
-
 	c.JSON(status, tmp)
 
 }
 
 func (s *FileServer) deleteDoc(ctx context.Context, token string, docID string) (structs.RmDoc, int, svStruct.ErrResponse) {
-	lgr := logger.GetLogger()
+	lgr := logger.GetLogger().WithContext(ctx)
 
 	doc, err := s.F.DeleteDoc(ctx, token, docID)
 	if err != nil {
@@ -250,8 +357,62 @@ func (s *FileServer) deleteDoc(ctx context.Context, token string, docID string)
 	return doc, http.StatusOK, svStruct.ErrResponse{}
 }
 
+// UpdateDoc renames the document, toggles its visibility or corrects its mime. Only the owner can do it.
+func (s *FileServer) UpdateDoc(c *gin.Context) {
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
+
+	docID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	req := svStruct.UpdateDocReq{}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Name == nil && req.Public == nil && req.Mime == nil) {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad request body",
+		}})
+		return
+	}
+	if req.Name != nil && !isDocNameValid(*req.Name) {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad document name",
+		}})
+		return
+	}
+	if req.Mime != nil {
+		checked, status, errResp := checkMime(*req.Mime, s.Upload)
+		if status != http.StatusOK {
+			errJSON(c, status, errResp)
+			return
+		}
+		req.Mime = &checked
+	}
+
+	err := s.F.UpdateDoc(c.Request.Context(), c.Query("token"), docID, structs.DocUpdate(req))
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			errJSON(c, http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 404,
+				Text: "Looks like there is no such document",
+			}})
+			return
+		}
+		lgr.Error(err.Error(), "fileServer", "UpdateDoc", "UpdateDoc")
+
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Internal server error",
+		}})
+		return
+	}
+
+	c.JSON(http.StatusOK, svStruct.LogoutResp{Resp: jsoniter.RawMessage(fmt.Sprintf("{\"%s\":true}", docID))})
+}
+
 func (s *FileServer) GetDocsList(c *gin.Context) {
-	lgr := logger.GetLogger()
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
 
 	token := c.Query("token")
 	login := c.Query("login")
@@ -262,7 +423,7 @@ func (s *FileServer) GetDocsList(c *gin.Context) {
 	if err != nil {
 		lgr.Error(err.Error(), "fileServer", "GetDocsList", "strconv.Atoi")
 
-		c.JSON(http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
 			Code: 400,
 			Text: "bad limit val",
 		}})
@@ -270,17 +431,18 @@ func (s *FileServer) GetDocsList(c *gin.Context) {
 	}
 
 	listReq := svStruct.GetDocListReq{
-		Token: token,
-		Login: login,
-		Key:   key,
-		Value: val,
-		Limit: limit,
+		Token:  token,
+		Login:  login,
+		Key:    key,
+		Value:  val,
+		Limit:  limit,
+		Folder: c.Query("folder"),
 	}
 
 	docs, status, errResp := s.getDocsList(c.Request.Context(), listReq)
 
 	if status != http.StatusOK {
-		c.JSON(status, errResp)
+		errJSON(c, status, errResp)
 		return
 	}
 	if len(docs) == 0 {
@@ -291,15 +453,32 @@ func (s *FileServer) GetDocsList(c *gin.Context) {
 		for i, doc := range docs {
 			dataResp.Data.Docs[i] = svStruct.Doc(doc)
 		}
-		c.JSON(status, svStruct.Response{dataResp})
+		c.JSON(status, svStruct.Response{DataResp: dataResp})
 	}
 
 }
 
 func (s *FileServer) getDocsList(ctx context.Context, listInfo svStruct.GetDocListReq) ([]structs.DocEntry, int, svStruct.ErrResponse) {
-	lgr := logger.GetLogger()
+	lgr := logger.GetLogger().WithContext(ctx)
 
-	docs, err := s.F.GetDocs(ctx, structs.ListInfo(listInfo))
+	// Nothing can be in a folder or have an id which isn't a UUID
+	if (listInfo.Folder != structs.RootFolder && !isFolderIDValid(listInfo.Folder)) || (listInfo.Key == "id" && !isIDValid(listInfo.Value)) {
+		return []structs.DocEntry{}, http.StatusOK, svStruct.ErrResponse{}
+	}
+
+	var docs []structs.DocEntry
+	var err error
+	if listInfo.Token == "" { // Anonymous access, see mw.ValidateTokenOrAnonymous
+		docs, err = s.F.GetPublicDocs(ctx, structs.ListInfo(listInfo))
+	} else {
+		docs, err = s.F.GetDocs(ctx, structs.ListInfo(listInfo))
+	}
+	if errors.Is(err, models.ErrInvalidInput) {
+		return []structs.DocEntry{}, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "login is required without token",
+		}}
+	}
 	if err != nil && !errors.Is(err, models.ErrNotFound) {
 		lgr.Error(err.Error(), "fileServer", "getDocsList", "GetDocs")
 
@@ -313,29 +492,49 @@ func (s *FileServer) getDocsList(ctx context.Context, listInfo svStruct.GetDocLi
 }
 
 func (s *FileServer) GetDoc(c *gin.Context) {
-	lgr := logger.GetLogger()
-
-	docID := c.Param("id")
+	docID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
 	token := c.Query("token")
 
 	doc, status, errResp := s.getDoc(c.Request.Context(), token, docID)
 
 	if status != http.StatusOK {
-		c.JSON(status, errResp)
+		errJSON(c, status, errResp)
 		return
 	}
+	defer doc.Data.Close()
+
+	writeDoc(c, doc, "GetDoc")
+}
+
+// writeDoc sends the document: files as they are, json documents inside of JSON response
+func writeDoc(c *gin.Context, doc structs.GetDoc, method string) {
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
+
+	setDigestHeaders(c.Writer.Header(), doc.SHA256, doc.MD5)
 
 	if doc.IsFile {
-		c.Writer.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=%s", doc.Name))
+		c.Writer.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.Name}))
 		c.Writer.Header().Set("Content-Type", doc.Mime)
-		c.File("./file-storage/" + doc.Name)
+		http.ServeContent(c.Writer, c.Request, doc.Name, time.Time{}, doc.Data)
 	} else {
-		data, err := os.ReadFile("./file-storage/json/" + doc.Name)
+		data, err := io.ReadAll(doc.Data)
+		if err != nil {
+			lgr.Error(err.Error(), "fileServer", method, "io.ReadAll")
+
+			errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 500,
+				Text: "Can't read document",
+			}})
+			return
+		}
 		newData, err := jsoniter.Marshal(jsoniter.RawMessage(fmt.Sprintf("{\"data\": %s}", string(data))))
 		if err != nil {
-			lgr.Error(err.Error(), "fileServer", "GetDoc", "jsoniter.Marshal")
+			lgr.Error(err.Error(), "fileServer", method, "jsoniter.Marshal")
 
-			c.JSON(http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
 				Code: 500,
 				Text: "Can't marshal response",
 			}})
@@ -345,23 +544,28 @@ func (s *FileServer) GetDoc(c *gin.Context) {
 		err = jsoniter.Unmarshal(newData, &tmp)
 
 		if err != nil {
-			lgr.Error(err.Error(), "fileServer", "GetDoc", "jsoniter.Unmarshal")
+			lgr.Error(err.Error(), "fileServer", method, "jsoniter.Unmarshal")
 
-			c.JSON(http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
 				Code: 500,
 				Text: "Can't unmarshal response",
 			}})
 			return
 		}
-		c.JSON(status, tmp)
+		c.JSON(http.StatusOK, tmp)
 	}
-
 }
 
 func (s *FileServer) getDoc(ctx context.Context, token string, docID string) (structs.GetDoc, int, svStruct.ErrResponse) {
-	lgr := logger.GetLogger()
+	lgr := logger.GetLogger().WithContext(ctx)
 
-	doc, err := s.F.GetDoc(ctx, token, docID)
+	var doc structs.GetDoc
+	var err error
+	if token == "" { // Anonymous access, see mw.ValidateTokenOrAnonymous
+		doc, err = s.F.GetPublicDoc(ctx, docID)
+	} else {
+		doc, err = s.F.GetDoc(ctx, token, docID)
+	}
 	if err != nil {
 		if errors.Is(err, models.ErrNotFound) {
 			return structs.GetDoc{}, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
@@ -375,6 +579,24 @@ func (s *FileServer) getDoc(ctx context.Context, token string, docID string) (st
 				Text: "Forbidden",
 			}}
 		}
+		if errors.Is(err, models.ErrScanPending) {
+			return structs.GetDoc{}, http.StatusConflict, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 409,
+				Text: "Document is being scanned for malware, try again later",
+			}}
+		}
+		if errors.Is(err, models.ErrScanFailed) {
+			return structs.GetDoc{}, http.StatusConflict, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 409,
+				Text: "Document can't be scanned for malware, so it isn't served",
+			}}
+		}
+		if errors.Is(err, models.ErrInfected) {
+			return structs.GetDoc{}, http.StatusForbidden, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 403,
+				Text: "Document is infected and quarantined",
+			}}
+		}
 		lgr.Error(err.Error(), "fileServer", "getDoc", "GetDoc")
 
 		return structs.GetDoc{}, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
@@ -0,0 +1,290 @@
+package servers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	svStruct "github.com/Kapeland/task-Astral/internal/services/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"net/http"
+	"strconv"
+)
+
+type FolderModelManager interface {
+	CreateFolder(ctx context.Context, token string, parentID string, name string) (structs.Folder, error)
+	GetRootFolders(ctx context.Context, token string) ([]structs.Folder, error)
+	GetFolder(ctx context.Context, token string, folderID string) (structs.FolderView, error)
+	UpdateFolder(ctx context.Context, token string, folderID string, name *string, parentID *string) error
+	DeleteFolder(ctx context.Context, token string, folderID string) (int, error)
+	SetFolderGrants(ctx context.Context, token string, folderID string, logins []string) error
+	MoveDoc(ctx context.Context, token string, docID string, folderID string) error
+}
+
+type FolderServer struct {
+	Fo FolderModelManager
+	F  FileModelManager
+}
+
+func toSvFolder(folder structs.Folder) svStruct.Folder {
+	return svStruct.Folder{ID: folder.ID, Name: folder.Name, Parent: folder.ParentID, Created: folder.CreatedAt}
+}
+
+func toSvFolders(folders []structs.Folder) []svStruct.Folder {
+	out := make([]svStruct.Folder, len(folders))
+	for i, folder := range folders {
+		out[i] = toSvFolder(folder)
+	}
+	return out
+}
+
+// folderErrResponse maps errors of folder operations. notFound is the text for models.ErrNotFound.
+func folderErrResponse(ctx context.Context, err error, notFound string, method string) (int, svStruct.ErrResponse) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 404,
+			Text: notFound,
+		}}
+	case errors.Is(err, models.ErrConflict):
+		return http.StatusConflict, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 409,
+			Text: "There is a folder with the same name already",
+		}}
+	case errors.Is(err, models.ErrInvalidInput):
+		return http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad grants",
+		}}
+	default:
+		lgr.Error(err.Error(), "folderServer", method, "FolderModelManager")
+
+		return http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Internal server error",
+		}}
+	}
+}
+
+func (s *FolderServer) CreateFolder(c *gin.Context) {
+	token := c.Query("token")
+
+	req := svStruct.FolderReq{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == nil || !isDocNameValid(*req.Name) {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad folder name",
+		}})
+		return
+	}
+	parentID := ""
+	if req.Parent != nil {
+		parentID = *req.Parent
+	}
+	if !isFolderIDValid(parentID) {
+		errJSON(c, http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 404,
+			Text: "Looks like there is no such parent folder",
+		}})
+		return
+	}
+
+	folder, err := s.Fo.CreateFolder(c.Request.Context(), token, parentID, *req.Name)
+	if err != nil {
+		status, errResp := folderErrResponse(c.Request.Context(), err, "Looks like there is no such parent folder", "CreateFolder")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	svFolder := toSvFolder(folder)
+	c.JSON(http.StatusOK, svStruct.FolderResp{Data: svStruct.FolderBody{Folder: &svFolder, Folders: []svStruct.Folder{}}})
+}
+
+// GetRootFolders returns folders of the token owner which aren't inside other folders
+func (s *FolderServer) GetRootFolders(c *gin.Context) {
+	folders, err := s.Fo.GetRootFolders(c.Request.Context(), c.Query("token"))
+	if err != nil {
+		status, errResp := folderErrResponse(c.Request.Context(), err, "Looks like there is no such folder", "GetRootFolders")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, svStruct.FolderResp{Data: svStruct.FolderBody{Folders: toSvFolders(folders)}})
+}
+
+// GetFolder returns the folder with its breadcrumbs, subfolders and documents
+func (s *FolderServer) GetFolder(c *gin.Context) {
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
+
+	token := c.Query("token")
+	folderID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	limit := 0
+	if c.Query("limit") != "" {
+		var err error
+		if limit, err = strconv.Atoi(c.Query("limit")); err != nil {
+			errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 400,
+				Text: "bad limit val",
+			}})
+			return
+		}
+	}
+
+	view, err := s.Fo.GetFolder(c.Request.Context(), token, folderID)
+	if err != nil {
+		status, errResp := folderErrResponse(c.Request.Context(), err, "Looks like there is no such folder", "GetFolder")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	docs, err := s.F.GetDocs(c.Request.Context(), structs.ListInfo{
+		Token:  token,
+		Limit:  limit,
+		Folder: folderID,
+	})
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		lgr.Error(err.Error(), "folderServer", "GetFolder", "GetDocs")
+
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Internal server error",
+		}})
+		return
+	}
+
+	svFolder := toSvFolder(view.Folder)
+	resp := svStruct.FolderResp{Data: svStruct.FolderBody{
+		Folder:      &svFolder,
+		Breadcrumbs: toSvFolders(view.Breadcrumbs),
+		Folders:     toSvFolders(view.Subfolders),
+		Docs:        make([]svStruct.Doc, len(docs)),
+	}}
+	for i, doc := range docs {
+		resp.Data.Docs[i] = svStruct.Doc(doc)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateFolder renames and/or moves the folder. Empty parent_id moves it to the root.
+func (s *FolderServer) UpdateFolder(c *gin.Context) {
+	token := c.Query("token")
+	folderID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	req := svStruct.FolderReq{}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Name != nil && !isDocNameValid(*req.Name)) {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad folder name",
+		}})
+		return
+	}
+
+	if req.Parent != nil && !isFolderIDValid(*req.Parent) {
+		errJSON(c, http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 404,
+			Text: "Looks like there is no such folder or parent folder",
+		}})
+		return
+	}
+
+	err := s.Fo.UpdateFolder(c.Request.Context(), token, folderID, req.Name, req.Parent)
+	if err != nil {
+		// Moving a folder inside itself looks the same as moving it to a missing folder
+		status, errResp := folderErrResponse(c.Request.Context(), err, "Looks like there is no such folder or parent folder", "UpdateFolder")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, svStruct.LogoutResp{Resp: jsoniter.RawMessage(fmt.Sprintf("{\"%s\":true}", folderID))})
+}
+
+// DeleteFolder deletes the folder with its subfolders. Documents inside are moved to the trash.
+func (s *FolderServer) DeleteFolder(c *gin.Context) {
+	token := c.Query("token")
+	folderID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	trashed, err := s.Fo.DeleteFolder(c.Request.Context(), token, folderID)
+	if err != nil {
+		status, errResp := folderErrResponse(c.Request.Context(), err, "Looks like there is no such folder", "DeleteFolder")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, svStruct.LogoutResp{Resp: jsoniter.RawMessage(
+		fmt.Sprintf("{\"%s\":true,\"trashed\":%d}", folderID, trashed))})
+}
+
+// SetFolderGrants replaces logins granted access to the folder and everything inside it
+func (s *FolderServer) SetFolderGrants(c *gin.Context) {
+	token := c.Query("token")
+	folderID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	req := svStruct.GrantsReq{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad request body",
+		}})
+		return
+	}
+
+	err := s.Fo.SetFolderGrants(c.Request.Context(), token, folderID, req.Grant)
+	if err != nil {
+		status, errResp := folderErrResponse(c.Request.Context(), err, "Looks like there is no such folder", "SetFolderGrants")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, svStruct.LogoutResp{Resp: jsoniter.RawMessage(fmt.Sprintf("{\"%s\":true}", folderID))})
+}
+
+// MoveDoc puts the document into a folder of its owner. Empty folder_id moves it to the root.
+func (s *FolderServer) MoveDoc(c *gin.Context) {
+	token := c.Query("token")
+	docID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	req := svStruct.MoveDocReq{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad request body",
+		}})
+		return
+	}
+	if !isFolderIDValid(req.Folder) {
+		errJSON(c, http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 404,
+			Text: "Looks like there is no such document or folder",
+		}})
+		return
+	}
+
+	err := s.Fo.MoveDoc(c.Request.Context(), token, docID, req.Folder)
+	if err != nil {
+		status, errResp := folderErrResponse(c.Request.Context(), err, "Looks like there is no such document or folder", "MoveDoc")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, svStruct.LogoutResp{Resp: jsoniter.RawMessage(fmt.Sprintf("{\"%s\":true}", docID))})
+}
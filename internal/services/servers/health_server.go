@@ -0,0 +1,42 @@
+package servers
+
+import (
+	"context"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	svStruct "github.com/Kapeland/task-Astral/internal/services/structs"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type HealthModelManager interface {
+	Ready(ctx context.Context) structs.Readiness
+}
+
+type HealthServer struct {
+	H        HealthModelManager
+	Stopping <-chan struct{} // Closed once the service starts to shut down
+}
+
+// Healthz answers while the process can serve requests. Dependencies aren't checked
+func (s *HealthServer) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, svStruct.HealthResp{Status: structs.HealthOK})
+}
+
+// Readyz checks the dependencies and reports each of them. 503 tells to keep traffic away until they are back.
+// A degraded service is still ready, a stopping one isn't
+func (s *HealthServer) Readyz(c *gin.Context) {
+	select {
+	case <-s.Stopping:
+		c.JSON(http.StatusServiceUnavailable, structs.Readiness{Status: structs.HealthStopping})
+		return
+	default:
+	}
+
+	readiness := s.H.Ready(c.Request.Context())
+
+	status := http.StatusOK
+	if readiness.Status == structs.HealthDown {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, readiness)
+}
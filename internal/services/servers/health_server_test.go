@@ -0,0 +1,47 @@
+package servers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/gin-gonic/gin"
+)
+
+// fakeHealthModel reports every dependency as up
+type fakeHealthModel struct{}
+
+func (fakeHealthModel) Ready(ctx context.Context) structs.Readiness {
+	return structs.Readiness{Status: structs.HealthOK}
+}
+
+func TestReadyzStopping(t *testing.T) {
+	stopping := make(chan struct{})
+	s := &HealthServer{H: fakeHealthModel{}, Stopping: stopping}
+	router := gin.New()
+	router.GET("/readyz", s.Readyz)
+
+	for _, tt := range []struct {
+		name string
+		stop bool
+		want int
+	}{
+		{"serving", false, http.StatusOK},
+		{"stopping", true, http.StatusServiceUnavailable},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.stop {
+				close(stopping)
+			}
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d; want %d, body %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package servers
+
+import (
+	svStruct "github.com/Kapeland/task-Astral/internal/services/structs"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// isIDValid checks that s is a UUID in the canonical form, like the ids the service generates.
+// Other strings never reach queries, which compare them with uuid columns.
+func isIDValid(s string) bool {
+	return len(s) == 36 && uuid.Validate(s) == nil
+}
+
+// isFolderIDValid is isIDValid which also accepts the empty id of the root
+func isFolderIDValid(s string) bool {
+	return s == "" || isIDValid(s)
+}
+
+// paramID returns the id from the path. Anything but a UUID can't be found, so 404 is written for it.
+func paramID(c *gin.Context, name string) (string, bool) {
+	id := c.Param(name)
+	if !isIDValid(id) {
+		errJSON(c, http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 404,
+			Text: "Looks like there is no such item",
+		}})
+		return "", false
+	}
+	return id, true
+}
@@ -0,0 +1,81 @@
+package servers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIsIDValid(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"0b9f4c3e-8a1d-4d57-9d1e-3f2a5c6b7d80", true},
+		{"0B9F4C3E-8A1D-4D57-9D1E-3F2A5C6B7D80", true},
+		{"", false},
+		{"root", false},
+		{"0b9f4c3e8a1d4d579d1e3f2a5c6b7d80", false},
+		{"{0b9f4c3e-8a1d-4d57-9d1e-3f2a5c6b7d80}", false},
+		{"urn:uuid:0b9f4c3e-8a1d-4d57-9d1e-3f2a5c6b7d80", false},
+		{"0b9f4c3e-8a1d-4d57-9d1e-3f2a5c6b7d8z", false},
+		{"' or 1=1 --", false},
+	}
+	for _, tt := range tests {
+		if got := isIDValid(tt.id); got != tt.want {
+			t.Errorf("isIDValid(%q) = %v; want %v", tt.id, got, tt.want)
+		}
+	}
+	if !isFolderIDValid("") {
+		t.Errorf("isFolderIDValid(\"\") = false; want true")
+	}
+}
+
+// TestBadIDs checks that ids which aren't UUIDs are answered before the models are called.
+// The fake models panic on any call.
+func TestBadIDs(t *testing.T) {
+	const goodID = "0b9f4c3e-8a1d-4d57-9d1e-3f2a5c6b7d80"
+
+	file := &FileServer{F: &fakeFileModel{}}
+	folder := &FolderServer{Fo: struct{ FolderModelManager }{}, F: file.F}
+	router := gin.New()
+	router.GET("/docs/:id", file.GetDoc)
+	router.PATCH("/docs/:id", file.UpdateDoc)
+	router.PUT("/docs/:id/tags", file.SetDocTags)
+	router.POST("/docs/:id/move", folder.MoveDoc)
+	router.GET("/folders/:id", folder.GetFolder)
+	router.PATCH("/folders/:id", folder.UpdateFolder)
+	router.POST("/folders", folder.CreateFolder)
+	router.POST("/docs/bulk-delete", file.BulkDeleteDocs)
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodGet, "/docs/not-a-uuid", "", http.StatusNotFound},
+		{http.MethodPatch, "/docs/1", `{"name": "a"}`, http.StatusNotFound},
+		{http.MethodPut, "/docs/x/tags", `{"tags": ["a"]}`, http.StatusNotFound},
+		{http.MethodPost, "/docs/x/move", `{"folder_id": ""}`, http.StatusNotFound},
+		{http.MethodPost, "/docs/" + goodID + "/move", `{"folder_id": "root"}`, http.StatusNotFound},
+		{http.MethodGet, "/folders/root", "", http.StatusNotFound},
+		{http.MethodPatch, "/folders/" + goodID, `{"parent_id": "x"}`, http.StatusNotFound},
+		{http.MethodPost, "/folders", `{"name": "a", "parent_id": "x"}`, http.StatusNotFound},
+		{http.MethodPost, "/docs/bulk-delete", `{"ids": ["` + goodID + `", "x"]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d; want %d, body %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
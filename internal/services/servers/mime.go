@@ -0,0 +1,144 @@
+package servers
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	svStruct "github.com/Kapeland/task-Astral/internal/services/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/gabriel-vasile/mimetype"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// detectMime sniffs the type of the uploaded content and checks it against allow/deny lists from the config.
+// The declared type is never trusted: the detected one is returned. A type declared by the client or implied
+// by the extension of the document name that doesn't match the content is only logged. src is rewound afterwards.
+func detectMime(ctx context.Context, src io.ReadSeeker, declared string, name string, isFile bool, cfg config.Upload) (string, int, svStruct.ErrResponse) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	detected, err := mimetype.DetectReader(src)
+	if err != nil {
+		lgr.Error(err.Error(), "fileServer", "detectMime", "DetectReader")
+
+		return "", http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Can't read uploaded file",
+		}}
+	}
+
+	if !isFile { // Content of json documents is returned inside of JSON response, so it must be valid
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			lgr.Error(err.Error(), "fileServer", "detectMime", "Seek")
+
+			return "", http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 500,
+				Text: "Can't read uploaded file",
+			}}
+		}
+		data, err := io.ReadAll(src)
+		if err != nil || !jsoniter.Valid(data) {
+			return "", http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 400,
+				Text: "Document content is not valid JSON",
+			}}
+		}
+	}
+
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		lgr.Error(err.Error(), "fileServer", "detectMime", "Seek")
+
+		return "", http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Can't read uploaded file",
+		}}
+	}
+
+	// Generic types say nothing about the content, so there is nothing to compare with
+	if !isGenericMime(detected) {
+		if declared != "" && !isMimeMatching(detected, declared) {
+			lgr.Info("declared mime "+declared+" doesn't match content "+detected.String(), "fileServer", "detectMime", "isMimeMatching")
+		}
+		if byExt := mime.TypeByExtension(filepath.Ext(name)); byExt != "" && !isMimeMatching(detected, byExt) {
+			lgr.Info("extension of "+name+" doesn't match content "+detected.String(), "fileServer", "detectMime", "isMimeMatching")
+		}
+	}
+
+	if isMimeListed(detected, cfg.DeniedMime) || (len(cfg.AllowedMime) != 0 && !isMimeListed(detected, cfg.AllowedMime)) {
+		return "", http.StatusUnsupportedMediaType, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 415,
+			Text: "Mime " + detected.String() + " is not allowed",
+		}}
+	}
+
+	return detected.String(), http.StatusOK, svStruct.ErrResponse{}
+}
+
+func isGenericMime(m *mimetype.MIME) bool {
+	return m.Is("application/octet-stream") || m.Is("text/plain")
+}
+
+// isMimeMatching checks whether m or any of its parents is the expected type. Parameters are ignored.
+func isMimeMatching(m *mimetype.MIME, expected string) bool {
+	mediaType, _, err := mime.ParseMediaType(expected)
+	if err != nil {
+		return false
+	}
+	for ; m != nil; m = m.Parent() {
+		if m.Is(mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMimeListed checks m against the list of types. Types like "image/*" match the whole group.
+func isMimeListed(m *mimetype.MIME, list []string) bool {
+	for _, item := range list {
+		group, isGroup := strings.CutSuffix(item, "/*")
+		if !isGroup {
+			if isMimeMatching(m, item) {
+				return true
+			}
+			continue
+		}
+		for t := m; t != nil; t = t.Parent() {
+			if strings.HasPrefix(t.String(), group+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkMime checks the type set by the owner of a stored document against allow/deny lists from the config.
+// The content isn't sniffed again, so only known types are accepted. Returns the canonical type.
+func checkMime(declared string, cfg config.Upload) (string, int, svStruct.ErrResponse) {
+	mediaType, _, err := mime.ParseMediaType(declared)
+	if err != nil {
+		return "", http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad mime " + declared,
+		}}
+	}
+	m := mimetype.Lookup(mediaType)
+	if m == nil {
+		return "", http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Unknown mime " + mediaType,
+		}}
+	}
+
+	if isMimeListed(m, cfg.DeniedMime) || (len(cfg.AllowedMime) != 0 && !isMimeListed(m, cfg.AllowedMime)) {
+		return "", http.StatusUnsupportedMediaType, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 415,
+			Text: "Mime " + m.String() + " is not allowed",
+		}}
+	}
+
+	return m.String(), http.StatusOK, svStruct.ErrResponse{}
+}
@@ -0,0 +1,51 @@
+package servers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+)
+
+func TestDetectMime(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x02\x00\x00\x00"
+	tests := []struct {
+		name       string
+		content    string
+		declared   string
+		docName    string
+		isFile     bool
+		cfg        config.Upload
+		wantMime   string
+		wantStatus int
+	}{
+		{"json declared as plain/text", `{"a": 1}`, "plain/text", "doc", false, config.Upload{}, "application/json", http.StatusOK},
+		{"png declared as pdf", png, "application/pdf", "pic.pdf", true, config.Upload{}, "image/png", http.StatusOK},
+		{"invalid json", `{"a":`, "application/json", "doc", false, config.Upload{}, "", http.StatusBadRequest},
+		{"denied", png, "image/png", "pic.png", true, config.Upload{DeniedMime: []string{"image/*"}}, "", http.StatusUnsupportedMediaType},
+		{"not allowed", png, "image/png", "pic.png", true, config.Upload{AllowedMime: []string{"application/pdf"}}, "", http.StatusUnsupportedMediaType},
+		{"allowed", png, "", "pic", true, config.Upload{AllowedMime: []string{"image/png"}}, "image/png", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := strings.NewReader(tt.content)
+
+			got, status, errResp := detectMime(context.Background(), src, tt.declared, tt.docName, tt.isFile, tt.cfg)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d (%s); want %d", status, errResp.Err.Text, tt.wantStatus)
+			}
+			if got != tt.wantMime {
+				t.Errorf("mime = %q; want %q", got, tt.wantMime)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			if rest, _ := io.ReadAll(src); string(rest) != tt.content {
+				t.Errorf("src isn't rewound")
+			}
+		})
+	}
+}
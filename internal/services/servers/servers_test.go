@@ -0,0 +1,16 @@
+package servers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	logger.CreateLogger(&config.Config{Logger: config.Logger{Lvl: "error", LogRate: 1}})
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
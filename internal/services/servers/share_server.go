@@ -0,0 +1,183 @@
+package servers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	svStruct "github.com/Kapeland/task-Astral/internal/services/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"net/http"
+	"time"
+)
+
+type ShareModelManager interface {
+	CreateShareLink(ctx context.Context, token string, link structs.ShareLink) (structs.ShareLink, error)
+	GetShareLinks(ctx context.Context, token string, docID string) ([]structs.ShareLink, error)
+	RevokeShareLink(ctx context.Context, token string, docID string, slug string) error
+	OpenShareLink(ctx context.Context, slug string, password string) (structs.GetDoc, error)
+}
+
+type ShareServer struct {
+	S ShareModelManager
+}
+
+// maxSharePasswordLen is the limit of bcrypt, longer passwords would be truncated silently
+const maxSharePasswordLen = 72
+
+// sharePasswordHeader carries the password of a share link. It's never taken from the URL, which ends up in logs
+const sharePasswordHeader = "X-Share-Password"
+
+func toSvShare(link structs.ShareLink) svStruct.Share {
+	return svStruct.Share{
+		Slug:         link.Slug,
+		URL:          "/s/" + link.Slug,
+		Password:     link.HasPassword,
+		ExpiresAt:    link.ExpiresAt,
+		MaxDownloads: link.MaxDownloads,
+		Downloads:    link.Downloads,
+		Created:      link.CreatedAt,
+	}
+}
+
+// shareErrResponse maps errors of share link operations
+func shareErrResponse(ctx context.Context, err error, method string) (int, svStruct.ErrResponse) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 404,
+			Text: "Looks like there is no such document or link",
+		}}
+	case errors.Is(err, models.ErrShareExpired):
+		return http.StatusGone, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 410,
+			Text: "Link is expired or has no downloads left",
+		}}
+	case errors.Is(err, models.ErrBadSharePassword):
+		return http.StatusUnauthorized, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 401,
+			Text: "Bad password",
+		}}
+	case errors.Is(err, models.ErrScanPending):
+		return http.StatusConflict, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 409,
+			Text: "Document is being scanned for malware, try again later",
+		}}
+	case errors.Is(err, models.ErrScanFailed):
+		return http.StatusConflict, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 409,
+			Text: "Document can't be scanned for malware, so it isn't served",
+		}}
+	case errors.Is(err, models.ErrInfected):
+		return http.StatusForbidden, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 403,
+			Text: "Document is infected and quarantined",
+		}}
+	default:
+		lgr.Error(err.Error(), "shareServer", method, "ShareModelManager")
+
+		return http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Internal server error",
+		}}
+	}
+}
+
+// CreateShareLink makes a link to the document which can be opened without an account
+func (s *ShareServer) CreateShareLink(c *gin.Context) {
+	req := svStruct.ShareReq{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad request body",
+		}})
+		return
+	}
+	if len(req.Password) > maxSharePasswordLen ||
+		(req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now())) ||
+		(req.MaxDownloads != nil && *req.MaxDownloads <= 0) {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad password, expiry or max downloads",
+		}})
+		return
+	}
+
+	docID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	link, err := s.S.CreateShareLink(c.Request.Context(), c.Query("token"), structs.ShareLink{
+		DocID:        docID,
+		Password:     req.Password,
+		ExpiresAt:    req.ExpiresAt,
+		MaxDownloads: req.MaxDownloads,
+	})
+	if err != nil {
+		status, errResp := shareErrResponse(c.Request.Context(), err, "CreateShareLink")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	share := toSvShare(link)
+	c.JSON(http.StatusOK, svStruct.ShareResp{Data: svStruct.ShareBody{Share: &share, Shares: []svStruct.Share{}}})
+}
+
+// GetShareLinks lists links to the document
+func (s *ShareServer) GetShareLinks(c *gin.Context) {
+	docID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	links, err := s.S.GetShareLinks(c.Request.Context(), c.Query("token"), docID)
+	if err != nil {
+		status, errResp := shareErrResponse(c.Request.Context(), err, "GetShareLinks")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	resp := svStruct.ShareResp{Data: svStruct.ShareBody{Shares: make([]svStruct.Share, len(links))}}
+	for i, link := range links {
+		resp.Data.Shares[i] = toSvShare(link)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RevokeShareLink deletes the link, it stops working at once
+func (s *ShareServer) RevokeShareLink(c *gin.Context) {
+	slug := c.Param("slug")
+	docID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	err := s.S.RevokeShareLink(c.Request.Context(), c.Query("token"), docID, slug)
+	if err != nil {
+		status, errResp := shareErrResponse(c.Request.Context(), err, "RevokeShareLink")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, svStruct.LogoutResp{Resp: jsoniter.RawMessage(fmt.Sprintf("{\"%s\":true}", slug))})
+}
+
+// OpenShareLink sends the document of the link. No token is needed
+func (s *ShareServer) OpenShareLink(c *gin.Context) {
+	doc, err := s.S.OpenShareLink(c.Request.Context(), c.Param("slug"), c.GetHeader(sharePasswordHeader))
+	if err != nil {
+		status, errResp := shareErrResponse(c.Request.Context(), err, "OpenShareLink")
+		errJSON(c, status, errResp)
+		return
+	}
+	defer doc.Data.Close()
+
+	c.Writer.Header().Set("Cache-Control", "no-store")
+	writeDoc(c, doc, "OpenShareLink")
+}
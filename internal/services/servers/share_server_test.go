@@ -0,0 +1,70 @@
+package servers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/gin-gonic/gin"
+)
+
+// fakeShareModel opens the link only with the password. Methods not used by tests panic.
+type fakeShareModel struct {
+	ShareModelManager
+	password string
+}
+
+func (f *fakeShareModel) OpenShareLink(ctx context.Context, slug string, password string) (structs.GetDoc, error) {
+	if password != f.password {
+		return structs.GetDoc{}, models.ErrBadSharePassword
+	}
+	return structs.GetDoc{
+		Name:   "doc.txt",
+		Mime:   "text/plain",
+		IsFile: true,
+		Data:   nopReadSeekCloser{strings.NewReader("content")},
+	}, nil
+}
+
+type nopReadSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopReadSeekCloser) Close() error {
+	return nil
+}
+
+func TestOpenShareLinkPassword(t *testing.T) {
+	s := &ShareServer{S: &fakeShareModel{password: "s3cret"}}
+	router := gin.New()
+	router.GET("/s/:slug", s.OpenShareLink)
+
+	tests := []struct {
+		name, query, header string
+		want                int
+	}{
+		{"header", "", "s3cret", http.StatusOK},
+		{"wrong header", "", "guess", http.StatusUnauthorized},
+		{"query param is ignored", "?password=s3cret", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/s/abc"+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set(sharePasswordHeader, tt.header)
+			}
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d; want %d, body %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package servers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	svStruct "github.com/Kapeland/task-Astral/internal/services/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxTags          = 50
+	maxTagLen        = 64
+	maxMetadataKeys  = 50
+	maxMetadataKey   = 64
+	maxMetadataValue = 1024
+	defaultTagsLimit = 20
+	maxTagsLimit     = 100
+)
+
+// normalizeTags trims tags and drops duplicates. Returns false if any tag is bad.
+func normalizeTags(tags []string) ([]string, bool) {
+	if len(tags) > maxTags {
+		return nil, false
+	}
+
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || len(tag) > maxTagLen || !utf8.ValidString(tag) {
+			return nil, false
+		}
+		for _, c := range tag {
+			if unicode.IsControl(c) {
+				return nil, false
+			}
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		out = append(out, tag)
+	}
+
+	return out, true
+}
+
+// isMetadataValid checks metadata keys are usable in "meta.<key>" filters of the list.
+func isMetadataValid(metadata map[string]string) bool {
+	if len(metadata) > maxMetadataKeys {
+		return false
+	}
+
+	for k, v := range metadata {
+		if k == "" || len(k) > maxMetadataKey || len(v) > maxMetadataValue || !utf8.ValidString(v) {
+			return false
+		}
+		for _, c := range k {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func tagErrResponse(ctx context.Context, err error, method string) (int, svStruct.ErrResponse) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	if errors.Is(err, models.ErrNotFound) {
+		return http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 404,
+			Text: "Looks like there is no such document",
+		}}
+	}
+	lgr.Error(err.Error(), "fileServer", method, "FileModelManager")
+
+	return http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+		Code: 500,
+		Text: "Internal server error",
+	}}
+}
+
+// SetDocTags replaces tags of the document
+func (s *FileServer) SetDocTags(c *gin.Context) {
+	docID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	req := svStruct.TagsReq{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Tags == nil {
+		ok = false
+	} else {
+		req.Tags, ok = normalizeTags(req.Tags)
+	}
+	if !ok {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad tags",
+		}})
+		return
+	}
+
+	if err := s.F.SetDocTags(c.Request.Context(), c.Query("token"), docID, req.Tags); err != nil {
+		status, errResp := tagErrResponse(c.Request.Context(), err, "SetDocTags")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, svStruct.LogoutResp{Resp: jsoniter.RawMessage(fmt.Sprintf("{\"%s\":true}", docID))})
+}
+
+// SetDocMetadata replaces metadata of the document
+func (s *FileServer) SetDocMetadata(c *gin.Context) {
+	docID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	req := svStruct.MetadataReq{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Metadata == nil || !isMetadataValid(req.Metadata) {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad metadata",
+		}})
+		return
+	}
+
+	if err := s.F.SetDocMetadata(c.Request.Context(), c.Query("token"), docID, req.Metadata); err != nil {
+		status, errResp := tagErrResponse(c.Request.Context(), err, "SetDocMetadata")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, svStruct.LogoutResp{Resp: jsoniter.RawMessage(fmt.Sprintf("{\"%s\":true}", docID))})
+}
+
+// GetTags returns tags of the token owner starting with prefix, the most used first
+func (s *FileServer) GetTags(c *gin.Context) {
+	limit := defaultTagsLimit
+	if c.Query("limit") != "" {
+		var err error
+		if limit, err = strconv.Atoi(c.Query("limit")); err != nil || limit <= 0 {
+			errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 400,
+				Text: "bad limit val",
+			}})
+			return
+		}
+	}
+	limit = min(limit, maxTagsLimit)
+
+	tags, err := s.F.GetTags(c.Request.Context(), c.Query("token"), strings.TrimSpace(c.Query("prefix")), limit)
+	if err != nil {
+		status, errResp := tagErrResponse(c.Request.Context(), err, "GetTags")
+		errJSON(c, status, errResp)
+		return
+	}
+	if tags == nil {
+		tags = []structs.TagCount{}
+	}
+
+	c.JSON(http.StatusOK, svStruct.TagsResp{Data: svStruct.TagsBody{Tags: tags}})
+}
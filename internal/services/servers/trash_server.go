@@ -0,0 +1,127 @@
+package servers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	svStruct "github.com/Kapeland/task-Astral/internal/services/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"net/http"
+)
+
+func (s *FileServer) GetTrash(c *gin.Context) {
+	token := c.Query("token")
+
+	docs, status, errResp := s.getTrash(c.Request.Context(), token)
+
+	if status != http.StatusOK {
+		errJSON(c, status, errResp)
+		return
+	}
+	if len(docs) == 0 {
+		c.JSON(http.StatusOK, gin.H{})
+		return
+	}
+	dataResp := svStruct.DataResp{}
+	dataResp.Data.Docs = make([]svStruct.Doc, len(docs))
+	for i, doc := range docs {
+		dataResp.Data.Docs[i] = svStruct.Doc(doc)
+	}
+	c.JSON(http.StatusOK, svStruct.Response{DataResp: dataResp})
+}
+
+func (s *FileServer) getTrash(ctx context.Context, token string) ([]structs.DocEntry, int, svStruct.ErrResponse) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	docs, err := s.F.GetTrash(ctx, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidToken):
+			return []structs.DocEntry{}, http.StatusUnauthorized, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 401,
+				Text: "Token is not valid",
+			}}
+		case errors.Is(err, models.ErrNotFound):
+			return []structs.DocEntry{}, http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 404,
+				Text: "Trash is not found",
+			}}
+		}
+		lgr.Error(err.Error(), "fileServer", "getTrash", "GetTrash")
+
+		return []structs.DocEntry{}, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Internal server error",
+		}}
+	}
+
+	return docs, http.StatusOK, svStruct.ErrResponse{}
+}
+
+func (s *FileServer) RestoreDoc(c *gin.Context) {
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
+
+	docID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	token := c.Query("token")
+
+	doc, status, errResp := s.restoreDoc(c.Request.Context(), token, docID)
+
+	if status != http.StatusOK {
+		errJSON(c, status, errResp)
+		return
+	}
+
+	newData, err := jsoniter.Marshal(svStruct.LogoutResp{Resp: jsoniter.RawMessage(fmt.Sprintf("{\"%s\":true}", doc.ID))})
+	if err != nil {
+		lgr.Error(err.Error(), "fileServer", "RestoreDoc", "jsoniter.Marshal")
+
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Can't marshal response",
+		}})
+		return
+	}
+	var tmp map[string]interface{}
+	err = jsoniter.Unmarshal(newData, &tmp)
+
+	if err != nil {
+		lgr.Error(err.Error(), "fileServer", "RestoreDoc", "jsoniter.Unmarshal")
+
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Can't unmarshal response",
+		}})
+		return
+	}
+
+	c.JSON(status, tmp)
+}
+
+func (s *FileServer) restoreDoc(ctx context.Context, token string, docID string) (structs.RmDoc, int, svStruct.ErrResponse) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	doc, err := s.F.RestoreDoc(ctx, token, docID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return structs.RmDoc{}, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 400,
+				Text: "Looks like there is no such document in the trash",
+			}}
+		}
+		lgr.Error(err.Error(), "fileServer", "restoreDoc", "RestoreDoc")
+
+		return structs.RmDoc{}, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Internal error during document restoring",
+		}}
+	}
+
+	return doc, http.StatusOK, svStruct.ErrResponse{}
+}
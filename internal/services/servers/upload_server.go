@@ -0,0 +1,421 @@
+package servers
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	svStruct "github.com/Kapeland/task-Astral/internal/services/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Resumable uploads follow the core of the tus protocol (https://tus.io/protocols/resumable-upload)
+// with creation, termination and expiration extensions. The document meta is passed in Upload-Metadata
+// under the "meta" key, and the json under the "json" key, both base64 encoded.
+const (
+	tusVersion      = "1.0.0"
+	tusExtensions   = "creation,termination,expiration"
+	tusContentType  = "application/offset+octet-stream"
+	uploadsLocation = "/api/uploads/"
+)
+
+type UploadModelManager interface {
+	CreateUpload(ctx context.Context, token string, up structs.Upload) (structs.Upload, error)
+	GetUpload(ctx context.Context, token string, uploadID string) (structs.Upload, error)
+	WriteChunk(ctx context.Context, token string, uploadID string, offset int64, src io.Reader) (structs.Upload, error)
+	OpenUpload(ctx context.Context, token string, uploadID string) (structs.Upload, io.ReadSeekCloser, error)
+	FinishUpload(ctx context.Context, token string, uploadID string, doc structs.File) error
+	DeleteUpload(ctx context.Context, token string, uploadID string) error
+}
+
+type UploadServer struct {
+	U         UploadModelManager
+	Upload    config.Upload
+	Resumable config.Resumable
+}
+
+// parseUploadMetadata parses "key base64value" pairs separated by commas. Values may be omitted.
+func parseUploadMetadata(header string) (map[string]string, bool) {
+	pairs := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return pairs, true
+	}
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, false
+		}
+		value := ""
+		if len(fields) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, false
+			}
+			value = string(decoded)
+		}
+		pairs[fields[0]] = value
+	}
+	return pairs, true
+}
+
+func setUploadHeaders(c *gin.Context, up structs.Upload) {
+	c.Header("Upload-Offset", strconv.FormatInt(up.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(up.Length, 10))
+	c.Header("Upload-Expires", up.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Header("Cache-Control", "no-store")
+}
+
+// GetUploadOptions tells clients what is supported
+func (s *UploadServer) GetUploadOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	if maxSize := s.Resumable.MaxSize; maxSize > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(maxSize, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (s *UploadServer) CreateUpload(c *gin.Context) {
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
+
+	c.Header("Tus-Resumable", tusVersion)
+	token := c.Query("token")
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad Upload-Length",
+		}})
+		return
+	}
+	if maxSize := s.Resumable.MaxSize; maxSize > 0 && length > maxSize {
+		errJSON(c, http.StatusRequestEntityTooLarge, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 413,
+			Text: "Upload is too large",
+		}})
+		return
+	}
+
+	metadata, ok := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if !ok {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad Upload-Metadata",
+		}})
+		return
+	}
+	varMeta := svStruct.DocMeta{}
+	if err := jsoniter.Unmarshal([]byte(metadata["meta"]), &varMeta); err != nil {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad 'meta' in Upload-Metadata",
+		}})
+		return
+	}
+	if !isDocNameValid(varMeta.Name) {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad document name",
+		}})
+		return
+	}
+	if !isChecksumValid(varMeta.SHA256, sha256.Size) || !isChecksumValid(varMeta.MD5, md5.Size) {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad checksum",
+		}})
+		return
+	}
+	if varMeta.Tags, ok = normalizeTags(varMeta.Tags); !ok || !isMetadataValid(varMeta.Metadata) {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad tags or metadata",
+		}})
+		return
+	}
+	if !isFolderIDValid(varMeta.Folder) {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Looks like there is no such folder",
+		}})
+		return
+	}
+
+	up, err := s.U.CreateUpload(c.Request.Context(), token, structs.Upload{
+		Meta:   structs.DocMeta(varMeta),
+		Json:   jsoniter.RawMessage(metadata["json"]),
+		Length: length,
+	})
+	if err != nil {
+		lgr.Error(err.Error(), "uploadServer", "CreateUpload", "CreateUpload")
+
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Internal server error",
+		}})
+		return
+	}
+
+	c.Header("Location", uploadsLocation+up.ID)
+	setUploadHeaders(c, up)
+	c.Status(http.StatusCreated)
+}
+
+// HeadUpload reports how many bytes have been received, so the client knows where to resume from
+func (s *UploadServer) HeadUpload(c *gin.Context) {
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
+
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Cache-Control", "no-store")
+
+	if !isIDValid(c.Param("id")) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	up, err := s.U.GetUpload(c.Request.Context(), c.Query("token"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		lgr.Error(err.Error(), "uploadServer", "HeadUpload", "GetUpload")
+
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	setUploadHeaders(c, up)
+	c.Status(http.StatusOK)
+}
+
+// PatchUpload appends a chunk to the upload. The last chunk turns the upload into a document.
+// If that fails on the server side, PATCH with the final offset and an empty body retries it.
+func (s *UploadServer) PatchUpload(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	token := c.Query("token")
+	uploadID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if c.ContentType() != tusContentType {
+		errJSON(c, http.StatusUnsupportedMediaType, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 415,
+			Text: "Content-Type must be " + tusContentType,
+		}})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad Upload-Offset",
+		}})
+		return
+	}
+
+	up, status, errResp := s.writeChunk(c.Request.Context(), token, uploadID, offset, c.Request.Body)
+	if status != http.StatusOK {
+		errJSON(c, status, errResp)
+		return
+	}
+	setUploadHeaders(c, up)
+
+	if up.IsComplete() {
+		status, errResp = s.finishUpload(c.Request.Context(), token, uploadID)
+		if status != http.StatusOK {
+			errJSON(c, status, errResp)
+			return
+		}
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (s *UploadServer) writeChunk(ctx context.Context, token string, uploadID string, offset int64, src io.Reader) (structs.Upload, int, svStruct.ErrResponse) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	up, err := s.U.WriteChunk(ctx, token, uploadID, offset, src)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNotFound):
+			return up, http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 404,
+				Text: "Looks like there is no such upload",
+			}}
+		case errors.Is(err, models.ErrConflict):
+			return up, http.StatusLocked, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 423,
+				Text: "Upload is being written by another request",
+			}}
+		case errors.Is(err, models.ErrOffsetMismatch):
+			return up, http.StatusConflict, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 409,
+				Text: "Upload-Offset doesn't match, expected " + strconv.FormatInt(up.Offset, 10),
+			}}
+		case errors.Is(err, models.ErrTooLarge):
+			return up, http.StatusRequestEntityTooLarge, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 413,
+				Text: "Chunk exceeds Upload-Length",
+			}}
+		default:
+			lgr.Error(err.Error(), "uploadServer", "writeChunk", "WriteChunk")
+
+			return up, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 500,
+				Text: "Internal server error",
+			}}
+		}
+	}
+
+	return up, http.StatusOK, svStruct.ErrResponse{}
+}
+
+// finishUpload adds the document. Uploads rejected for their content are removed, since retrying won't help.
+func (s *UploadServer) finishUpload(ctx context.Context, token string, uploadID string) (int, svStruct.ErrResponse) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	up, data, err := s.U.OpenUpload(ctx, token, uploadID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNotFound): // Finished or removed by another request meanwhile
+			return http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 404,
+				Text: "Looks like there is no such upload",
+			}}
+		case errors.Is(err, models.ErrUploadIncomplete):
+			return http.StatusConflict, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 409,
+				Text: "Upload is not complete",
+			}}
+		default:
+			lgr.Error(err.Error(), "uploadServer", "finishUpload", "OpenUpload")
+
+			return http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 500,
+				Text: "Internal server error",
+			}}
+		}
+	}
+	defer data.Close()
+
+	detectedMime, status, errResp := detectMime(ctx, data, up.Meta.Mime, up.Meta.Name, up.Meta.File, s.Upload)
+	if status != http.StatusOK {
+		lgr.Info(errResp.Err.Text, "uploadServer", "finishUpload", "detectMime")
+		s.dropUpload(ctx, token, uploadID)
+
+		return status, errResp
+	}
+	up.Meta.Mime = detectedMime
+
+	err = s.U.FinishUpload(ctx, token, uploadID, structs.File{
+		Meta:    up.Meta,
+		Json:    up.Json,
+		Data:    data,
+		WithMD5: s.Upload.MD5 || up.Meta.MD5 != "",
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNotFound):
+			return http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 404,
+				Text: "Looks like there is no such upload",
+			}}
+		case errors.Is(err, models.ErrUploadFinishing):
+			return http.StatusLocked, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 423,
+				Text: "Upload is being finished by another request",
+			}}
+		case errors.Is(err, models.ErrConflict):
+			s.dropUpload(ctx, token, uploadID)
+
+			return http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 400,
+				Text: "Duplicated doc",
+			}}
+		case errors.Is(err, models.ErrChecksumMismatch):
+			s.dropUpload(ctx, token, uploadID)
+
+			return http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 400,
+				Text: "Checksum mismatch",
+			}}
+		case errors.Is(err, models.ErrFolderNotFound):
+			s.dropUpload(ctx, token, uploadID)
+
+			return http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 400,
+				Text: "Looks like there is no such folder",
+			}}
+		case errors.Is(err, models.ErrInvalidInput):
+			s.dropUpload(ctx, token, uploadID)
+
+			return http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 400,
+				Text: "Bad grants",
+			}}
+		default:
+			lgr.Error(err.Error(), "uploadServer", "finishUpload", "FinishUpload")
+
+			return http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 500,
+				Text: "Internal server error",
+			}}
+		}
+	}
+
+	return http.StatusOK, svStruct.ErrResponse{}
+}
+
+func (s *UploadServer) dropUpload(ctx context.Context, token string, uploadID string) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	if err := s.U.DeleteUpload(ctx, token, uploadID); err != nil && !errors.Is(err, models.ErrNotFound) {
+		lgr.Error(err.Error(), "uploadServer", "dropUpload", "DeleteUpload")
+	}
+}
+
+// DeleteUpload cancels the upload and removes received bytes
+func (s *UploadServer) DeleteUpload(c *gin.Context) {
+	lgr := logger.GetLogger().WithContext(c.Request.Context())
+
+	c.Header("Tus-Resumable", tusVersion)
+
+	uploadID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	err := s.U.DeleteUpload(c.Request.Context(), c.Query("token"), uploadID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			errJSON(c, http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+				Code: 404,
+				Text: "Looks like there is no such upload",
+			}})
+			return
+		}
+		lgr.Error(err.Error(), "uploadServer", "DeleteUpload", "DeleteUpload")
+
+		errJSON(c, http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 500,
+			Text: "Internal server error",
+		}})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
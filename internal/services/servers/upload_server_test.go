@@ -0,0 +1,59 @@
+package servers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/gin-gonic/gin"
+)
+
+// fakeUploadModel completes the upload with any chunk, then fails to open it with openErr.
+// Methods not used by tests panic.
+type fakeUploadModel struct {
+	UploadModelManager
+	openErr error
+}
+
+func (f *fakeUploadModel) WriteChunk(ctx context.Context, token string, uploadID string, offset int64, src io.Reader) (structs.Upload, error) {
+	n, err := io.Copy(io.Discard, src)
+	return structs.Upload{ID: uploadID, Offset: offset + n, Length: offset + n}, err
+}
+
+func (f *fakeUploadModel) OpenUpload(ctx context.Context, token string, uploadID string) (structs.Upload, io.ReadSeekCloser, error) {
+	return structs.Upload{}, nil, f.openErr
+}
+
+func TestPatchUploadFinishErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		openErr error
+		want    int
+	}{
+		{"finished by another request", models.ErrNotFound, http.StatusNotFound},
+		{"incomplete", models.ErrUploadIncomplete, http.StatusConflict},
+		{"storage down", io.ErrUnexpectedEOF, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &UploadServer{U: &fakeUploadModel{openErr: tt.openErr}}
+			router := gin.New()
+			router.PATCH("/api/uploads/:id", s.PatchUpload)
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPatch, "/api/uploads/0b7c1f3e-8d4a-4c52-9a57-3f0e6b2d9c41", strings.NewReader("content"))
+			req.Header.Set("Content-Type", tusContentType)
+			req.Header.Set("Upload-Offset", "0")
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d; want %d, body %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package servers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	svStruct "github.com/Kapeland/task-Astral/internal/services/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+type WebhookModelManager interface {
+	CreateWebhook(ctx context.Context, token string, url string, events []string) (structs.Webhook, error)
+	GetWebhooks(ctx context.Context, token string) ([]structs.Webhook, error)
+	DeleteWebhook(ctx context.Context, token string, webhookID string) error
+	GetDeliveries(ctx context.Context, token string, webhookID string, filter structs.DeliveryFilter) ([]structs.WebhookDelivery, error)
+	RetryDelivery(ctx context.Context, token string, webhookID string, deliveryID int64) error
+}
+
+type WebhookServer struct {
+	W WebhookModelManager
+}
+
+const maxWebhookURLLen = 2048
+
+func toSvWebhook(webhook structs.Webhook) svStruct.Webhook {
+	events := []string(webhook.Events)
+	if events == nil {
+		events = []string{}
+	}
+	return svStruct.Webhook{
+		ID:      webhook.ID,
+		URL:     webhook.URL,
+		Events:  events,
+		Created: webhook.CreatedAt,
+	}
+}
+
+// isWebhookValid checks that the endpoint is an absolute http(s) url and all events are known
+func isWebhookValid(req svStruct.WebhookReq) bool {
+	if len(req.URL) > maxWebhookURLLen {
+		return false
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return false
+	}
+	for _, event := range req.Events {
+		if !slices.Contains(structs.EventTypes, event) {
+			return false
+		}
+	}
+	return true
+}
+
+// uniqueEvents sorts events and drops repeated ones. Compact drops only adjacent repeats, so sorting goes first
+func uniqueEvents(events []string) []string {
+	events = slices.Clone(events)
+	slices.Sort(events)
+	return slices.Compact(events)
+}
+
+// webhookErrResponse maps errors of webhook operations
+func webhookErrResponse(ctx context.Context, err error, method string) (int, svStruct.ErrResponse) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	if errors.Is(err, models.ErrNotFound) {
+		return http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 404,
+			Text: "Looks like there is no such webhook or delivery",
+		}}
+	}
+	lgr.Error(err.Error(), "webhookServer", method, "WebhookModelManager")
+
+	return http.StatusInternalServerError, svStruct.ErrResponse{Err: svStruct.ErrBody{
+		Code: 500,
+		Text: "Internal server error",
+	}}
+}
+
+// CreateWebhook registers an endpoint for events of the user's documents. The signing secret is returned only here
+func (s *WebhookServer) CreateWebhook(c *gin.Context) {
+	req := svStruct.WebhookReq{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad request body",
+		}})
+		return
+	}
+	if !isWebhookValid(req) {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad url or events",
+		}})
+		return
+	}
+	webhook, err := s.W.CreateWebhook(c.Request.Context(), c.Query("token"), req.URL, uniqueEvents(req.Events))
+	if err != nil {
+		status, errResp := webhookErrResponse(c.Request.Context(), err, "CreateWebhook")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	resp := toSvWebhook(webhook)
+	resp.Secret = webhook.Secret
+	c.JSON(http.StatusOK, svStruct.WebhookResp{Data: svStruct.WebhookBody{Webhook: &resp, Webhooks: []svStruct.Webhook{}}})
+}
+
+// GetWebhooks lists webhooks of the user
+func (s *WebhookServer) GetWebhooks(c *gin.Context) {
+	webhooks, err := s.W.GetWebhooks(c.Request.Context(), c.Query("token"))
+	if err != nil {
+		status, errResp := webhookErrResponse(c.Request.Context(), err, "GetWebhooks")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	resp := svStruct.WebhookResp{Data: svStruct.WebhookBody{Webhooks: make([]svStruct.Webhook, len(webhooks))}}
+	for i, webhook := range webhooks {
+		resp.Data.Webhooks[i] = toSvWebhook(webhook)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteWebhook deletes the webhook with its delivery history
+func (s *WebhookServer) DeleteWebhook(c *gin.Context) {
+	webhookID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	err := s.W.DeleteWebhook(c.Request.Context(), c.Query("token"), webhookID)
+	if err != nil {
+		status, errResp := webhookErrResponse(c.Request.Context(), err, "DeleteWebhook")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, svStruct.LogoutResp{Resp: jsoniter.RawMessage(fmt.Sprintf("{\"%s\":true}", webhookID))})
+}
+
+// GetDeliveries returns the delivery history of the webhook, paged from the newest by 'before' and 'limit'.
+// status=dead lists deliveries which ran out of attempts
+func (s *WebhookServer) GetDeliveries(c *gin.Context) {
+	webhookID, found := paramID(c, "id")
+	if !found {
+		return
+	}
+	filter := structs.DeliveryFilter{Status: c.Query("status")}
+
+	var err error
+	ok := filter.Status == "" || filter.Status == structs.DeliveryPending ||
+		filter.Status == structs.DeliveryDelivered || filter.Status == structs.DeliveryDead
+	if v := c.Query("before"); ok && v != "" {
+		filter.BeforeID, err = strconv.ParseInt(v, 10, 64)
+		ok = err == nil && filter.BeforeID > 0
+	}
+	if v := c.Query("limit"); ok && v != "" {
+		filter.Limit, err = strconv.Atoi(v)
+		ok = err == nil && filter.Limit >= 0
+	}
+	if !ok {
+		errJSON(c, http.StatusBadRequest, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 400,
+			Text: "Bad filter",
+		}})
+		return
+	}
+
+	deliveries, err := s.W.GetDeliveries(c.Request.Context(), c.Query("token"), webhookID, filter)
+	if err != nil {
+		status, errResp := webhookErrResponse(c.Request.Context(), err, "GetDeliveries")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	resp := svStruct.DeliveriesResp{Data: svStruct.DeliveriesBody{Deliveries: []structs.WebhookDelivery{}}}
+	if len(deliveries) > 0 {
+		resp.Data.Deliveries = deliveries
+		resp.Data.Next = strconv.FormatInt(deliveries[len(deliveries)-1].ID, 10)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RetryDelivery sends the dead delivery again
+func (s *WebhookServer) RetryDelivery(c *gin.Context) {
+	deliveryID, err := strconv.ParseInt(c.Param("delivery"), 10, 64)
+	if err != nil || deliveryID <= 0 || !isIDValid(c.Param("id")) {
+		errJSON(c, http.StatusNotFound, svStruct.ErrResponse{Err: svStruct.ErrBody{
+			Code: 404,
+			Text: "Looks like there is no such webhook or delivery",
+		}})
+		return
+	}
+
+	err = s.W.RetryDelivery(c.Request.Context(), c.Query("token"), c.Param("id"), deliveryID)
+	if err != nil {
+		status, errResp := webhookErrResponse(c.Request.Context(), err, "RetryDelivery")
+		errJSON(c, status, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, svStruct.LogoutResp{Resp: jsoniter.RawMessage(fmt.Sprintf("{\"%d\":true}", deliveryID))})
+}
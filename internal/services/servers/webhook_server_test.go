@@ -0,0 +1,60 @@
+package servers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/gin-gonic/gin"
+)
+
+func TestUniqueEvents(t *testing.T) {
+	tests := []struct {
+		events, want []string
+	}{
+		{nil, []string{}},
+		{[]string{"doc.created"}, []string{"doc.created"}},
+		{[]string{"doc.deleted", "doc.created", "doc.deleted"}, []string{"doc.created", "doc.deleted"}},
+		{[]string{"doc.shared", "doc.created", "doc.shared", "doc.created"}, []string{"doc.created", "doc.shared"}},
+	}
+	for _, tt := range tests {
+		if got := uniqueEvents(tt.events); !slices.Equal(got, tt.want) {
+			t.Errorf("uniqueEvents(%q) = %q; want %q", tt.events, got, tt.want)
+		}
+	}
+}
+
+// fakeWebhookModel keeps the events of the created webhook. Methods not used by tests panic.
+type fakeWebhookModel struct {
+	WebhookModelManager
+	events []string
+}
+
+func (f *fakeWebhookModel) CreateWebhook(ctx context.Context, token string, url string, events []string) (structs.Webhook, error) {
+	f.events = events
+	return structs.Webhook{ID: "id", URL: url, Events: events}, nil
+}
+
+func TestCreateWebhookRepeatedEvents(t *testing.T) {
+	w := &fakeWebhookModel{}
+	s := &WebhookServer{W: w}
+	router := gin.New()
+	router.POST("/api/webhooks", s.CreateWebhook)
+	rec := httptest.NewRecorder()
+	body := `{"url": "https://example.com/hook", "events": ["doc.deleted", "doc.created", "doc.deleted"]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; want 200, body %s", rec.Code, rec.Body)
+	}
+	if want := []string{"doc.created", "doc.deleted"}; !slices.Equal(w.events, want) {
+		t.Errorf("events = %q; want %q", w.events, want)
+	}
+}
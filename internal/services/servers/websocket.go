@@ -0,0 +1,274 @@
+package servers
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Just enough of RFC 6455 to push text messages to a client and answer its control frames
+
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Opcodes of websocket frames
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// Bits of the first byte of a frame
+const (
+	wsFin = 0x80
+	wsRSV = 0x70 // No extensions are negotiated, so the bits must be zero
+)
+
+// Close statuses sent to clients
+const (
+	wsGoingAway     = 1001 // The server is shutting down
+	wsProtocolError = 1002 // The client has broken the protocol
+)
+
+// wsWriteTimeout bounds a write to a client, so a client which doesn't read can't hold the stream
+const wsWriteTimeout = 10 * time.Second
+
+// wsMaxClientFrame limits frames read from clients, they aren't expected to send anything but control frames
+const wsMaxClientFrame = 4096
+
+var (
+	errWSFrameTooLarge = errors.New("websocket frame is too large")
+	errWSProtocol      = errors.New("websocket frame breaks the protocol")
+)
+
+// isWebSocketUpgrade tells whether the client asks to switch the request to a websocket
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+// isOriginAllowed tells whether a browser on Origin may open the websocket. Clients which aren't browsers
+// don't send Origin. Pages of the service itself and of allowed origins may connect, others are refused,
+// so a page of another site can't read the stream of a user
+func isOriginAllowed(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(strings.TrimSuffix(a, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex // Writes come from the feed and from answers to pings
+}
+
+// upgradeWebSocket completes the handshake and takes the connection over from the http server
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, bool) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, false
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, false
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, false
+	}
+	// Deadlines of the http server are left on the connection, the stream outlives them
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, false
+	}
+
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	_, err = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, false
+	}
+
+	return &wsConn{conn: conn, rw: rw}, true
+}
+
+// WriteMessage sends a frame of opcode. Frames of a server aren't masked
+func (ws *wsConn) WriteMessage(opcode byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if err := ws.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+
+	header := []byte{wsFin | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	if _, err := ws.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := ws.rw.Write(payload); err != nil {
+		return err
+	}
+	return ws.rw.Flush()
+}
+
+// ReadLoop answers pings and returns when the client closes the connection or it breaks.
+// Data frames of the client are ignored
+func (ws *wsConn) ReadLoop() {
+	for {
+		opcode, payload, err := ws.readFrame()
+		if errors.Is(err, errWSProtocol) {
+			_ = ws.WriteMessage(wsClose, binary.BigEndian.AppendUint16(nil, wsProtocolError))
+			return
+		}
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsPing:
+			if err := ws.WriteMessage(wsPong, payload); err != nil {
+				return
+			}
+		case wsClose:
+			_ = ws.WriteMessage(wsClose, payload)
+			return
+		}
+	}
+}
+
+// readFrame reads a frame of the client. Clients must mask their frames, and as they only send control frames,
+// which can't be fragmented, fragments are refused as well as reserved bits
+func (ws *wsConn) readFrame() (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(ws.rw, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode, masked := head[0]&0x0F, head[1]&0x80 != 0
+	if head[0]&wsRSV != 0 || head[0]&wsFin == 0 || opcode == wsContinuation || !masked {
+		return 0, nil, errWSProtocol
+	}
+
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > wsMaxClientFrame {
+		return 0, nil, errWSFrameTooLarge
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(ws.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return opcode, payload, nil
+}
+
+func (ws *wsConn) Close() error {
+	return ws.conn.Close()
+}
+
+// WebSockets keeps websockets taken over from the http server, as its Shutdown doesn't close them.
+// A nil WebSockets keeps nothing
+type WebSockets struct {
+	mu    sync.Mutex
+	conns map[*wsConn]struct{}
+}
+
+func NewWebSockets() *WebSockets {
+	return &WebSockets{conns: make(map[*wsConn]struct{})}
+}
+
+func (w *WebSockets) add(ws *wsConn) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.conns[ws] = struct{}{}
+}
+
+func (w *WebSockets) remove(ws *wsConn) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.conns, ws)
+}
+
+// CloseAll tells clients that the server is going away and closes their websockets.
+// It's meant for http.Server.RegisterOnShutdown
+func (w *WebSockets) CloseAll() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	conns := make([]*wsConn, 0, len(w.conns))
+	for ws := range w.conns {
+		conns = append(conns, ws)
+	}
+	w.mu.Unlock()
+
+	for _, ws := range conns {
+		_ = ws.WriteMessage(wsClose, binary.BigEndian.AppendUint16(nil, wsGoingAway))
+		ws.Close()
+	}
+}
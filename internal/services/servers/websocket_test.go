@@ -0,0 +1,194 @@
+package servers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// clientFrame builds a frame of a client with the payload masked
+func clientFrame(first byte, payload []byte) []byte {
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{first, 0x80 | byte(len(payload))}, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+// dialWebSocket opens a websocket to a server which answers pings until the client leaves.
+// The websocket is kept in websockets
+func dialWebSocket(t *testing.T, websockets *WebSockets) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, ok := upgradeWebSocket(w, r)
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer ws.Close()
+		websockets.add(ws)
+		defer websockets.remove(ws)
+		ws.ReadLoop()
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// The key and the accept value are the example of RFC 6455
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d; want 101", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+	return conn, br
+}
+
+// readServerFrame reads a frame of the server, which is short and not masked
+func readServerFrame(t *testing.T, br *bufio.Reader) (byte, []byte) {
+	t.Helper()
+
+	var head [2]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	if head[0]&wsFin == 0 || head[1]&0x80 != 0 {
+		t.Fatalf("frame header %x; want a final unmasked frame", head)
+	}
+	payload := make([]byte, head[1])
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0F, payload
+}
+
+func TestWebSocketPing(t *testing.T) {
+	conn, br := dialWebSocket(t, nil)
+
+	if _, err := conn.Write(clientFrame(wsFin|wsPing, []byte("hi"))); err != nil {
+		t.Fatal(err)
+	}
+	if opcode, payload := readServerFrame(t, br); opcode != wsPong || string(payload) != "hi" {
+		t.Errorf("answer to ping = %x %q; want pong \"hi\"", opcode, payload)
+	}
+
+	if _, err := conn.Write(clientFrame(wsFin|wsClose, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if opcode, _ := readServerFrame(t, br); opcode != wsClose {
+		t.Errorf("answer to close = %x; want close", opcode)
+	}
+}
+
+func TestWebSocketClosesOnProtocolError(t *testing.T) {
+	conn, br := dialWebSocket(t, nil)
+
+	if _, err := conn.Write([]byte{wsFin | wsPing, 0}); err != nil { // Not masked
+		t.Fatal(err)
+	}
+
+	opcode, payload := readServerFrame(t, br)
+	if opcode != wsClose || len(payload) != 2 || binary.BigEndian.Uint16(payload) != wsProtocolError {
+		t.Fatalf("answer = %x %x; want close 1002", opcode, payload)
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("connection is kept open: %v", err)
+	}
+}
+
+func TestWebSocketsCloseAll(t *testing.T) {
+	websockets := NewWebSockets()
+	conn, br := dialWebSocket(t, websockets)
+	// The answer tells the websocket is kept already
+	if _, err := conn.Write(clientFrame(wsFin|wsPing, nil)); err != nil {
+		t.Fatal(err)
+	}
+	readServerFrame(t, br)
+
+	websockets.CloseAll()
+
+	opcode, payload := readServerFrame(t, br)
+	if opcode != wsClose || len(payload) != 2 || binary.BigEndian.Uint16(payload) != wsGoingAway {
+		t.Fatalf("frame = %x %x; want close 1001", opcode, payload)
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("connection is kept open: %v", err)
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		frame   []byte
+		wantErr error
+	}{
+		{"masked ping", clientFrame(wsFin|wsPing, []byte("hi")), nil},
+		{"not masked", []byte{wsFin | wsPing, 0}, errWSProtocol},
+		{"first fragment", clientFrame(wsText, []byte("hi")), errWSProtocol},
+		{"continuation", clientFrame(wsFin|wsContinuation, []byte("hi")), errWSProtocol},
+		{"reserved bit", clientFrame(wsFin|0x40|wsPing, nil), errWSProtocol},
+		{"too large", append([]byte{wsFin | wsText, 0x80 | 126}, 0xFF, 0xFF), errWSFrameTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := &wsConn{rw: bufio.NewReadWriter(bufio.NewReader(bytes.NewReader(tt.frame)), nil)}
+
+			_, payload, err := ws.readFrame()
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if err == nil && string(payload) != "hi" {
+				t.Errorf("payload = %q; want it unmasked", payload)
+			}
+		})
+	}
+}
+
+func TestIsOriginAllowed(t *testing.T) {
+	allowed := []string{"https://app.example.com/"}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"https://api.example.com", true},
+		{"https://app.example.com", true},
+		{"https://evil.example.net", false},
+		{"http://app.example.com", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://api.example.com/api/events", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := isOriginAllowed(r, allowed); got != tt.want {
+			t.Errorf("isOriginAllowed(%q) = %v; want %v", tt.origin, got, tt.want)
+		}
+	}
+}
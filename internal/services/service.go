@@ -6,71 +6,272 @@ import (
 	"github.com/Kapeland/task-Astral/internal/services/servers"
 	"github.com/Kapeland/task-Astral/internal/utils/config"
 	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/Kapeland/task-Astral/internal/utils/metrics"
 	"github.com/chenyahui/gin-cache"
 	"github.com/chenyahui/gin-cache/persist"
 	"github.com/gin-gonic/gin"
-	"github.com/go-redis/redis/v8"
+	"net/http"
 	"strconv"
 	"time"
 )
 
 type Service struct {
-	fm servers.FileModelManager
-	am servers.AuthModelManager
-	um UsersModelManager
+	fm  servers.FileModelManager
+	am  servers.AuthModelManager
+	um  UsersModelManager
+	upm servers.UploadModelManager
+	fom servers.FolderModelManager
+	shm servers.ShareModelManager
+	aum servers.AuditModelManager
+	wm  servers.WebhookModelManager
+	fdm servers.FeedModelManager
+	hm  servers.HealthModelManager
+	rc  ResponseCache
 }
 
-func NewService(fm servers.FileModelManager, am servers.AuthModelManager, um UsersModelManager) Service {
-	return Service{fm: fm, am: am, um: um}
+// ResponseCache keeps responses to reads and drops them when data changes
+type ResponseCache interface {
+	persist.CacheStore
+	mw.CachePurger
 }
 
-func (s Service) Launch(cfg *config.Config, lgr *logger.Logger) {
-	ctx := context.Background()
+func NewService(fm servers.FileModelManager, am servers.AuthModelManager, um UsersModelManager, upm servers.UploadModelManager,
+	fom servers.FolderModelManager, shm servers.ShareModelManager, aum servers.AuditModelManager,
+	wm servers.WebhookModelManager, fdm servers.FeedModelManager, hm servers.HealthModelManager, rc ResponseCache) Service {
+	return Service{fm: fm, am: am, um: um, upm: upm, fom: fom, shm: shm, aum: aum, wm: wm, fdm: fdm, hm: hm, rc: rc}
+}
 
-	implAuth := servers.AuthServer{A: s.am}
-	implFile := servers.FileServer{F: s.fm, A: s.am}
+const defaultMetricsPath = "/metrics"
+
+func metricsPath(cfg config.Metrics) string {
+	if cfg.Path == "" {
+		return defaultMetricsPath
+	}
+	return cfg.Path
+}
+
+const defaultShutdownTimeout = 30 * time.Second
+
+func shutdownTimeout(cfg config.Rest) time.Duration {
+	if cfg.ShutdownTimeout <= 0 {
+		return defaultShutdownTimeout
+	}
+	return cfg.ShutdownTimeout
+}
 
-	redisStore := persist.NewRedisStore(redis.NewClient(&redis.Options{
-		Network: "tcp",
-		Addr:    cfg.Redis.Host + ":" + strconv.Itoa(cfg.Redis.Port),
-		DB:      cfg.Redis.DB,
-	}))
+// Defaults of config.Rest. Uploads bigger than read_timeout allows go by parts through /api/uploads
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultReadTimeout       = 10 * time.Minute
+)
 
-	if res, err := redisStore.RedisClient.Ping(ctx).Result(); err != nil {
-		lgr.Error(res, "Service", "Launch", "Ping")
-		return
+// newServer serves handler on the port of cfg. Clients which send requests too slowly are cut off
+func newServer(cfg config.Rest, handler http.Handler) *http.Server {
+	readHeaderTimeout, readTimeout := cfg.ReadHeaderTimeout, cfg.ReadTimeout
+	if readHeaderTimeout <= 0 {
+		readHeaderTimeout = defaultReadHeaderTimeout
+	}
+	if readTimeout <= 0 {
+		readTimeout = defaultReadTimeout
 	}
+	return &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Port),
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+	}
+}
+
+// Launch serves the API until ctx is done. Then /readyz reports 503, new connections are refused and requests
+// in progress are given rest.shutdown_timeout to finish, after which the remaining connections are closed.
+// Websockets aren't requests of the http server any more, so they are closed at once
+func (s Service) Launch(ctx context.Context, cfg *config.Config, lgr *logger.Logger) error {
+	websockets := servers.NewWebSockets()
+	router, err := s.newRouter(ctx, cfg, websockets, lgr)
+	if err != nil {
+		return err
+	}
+
+	srv := newServer(cfg.Rest, router)
+	srv.RegisterOnShutdown(websockets.CloseAll)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		lgr.Error(err.Error(), "Service", "Launch", "ListenAndServe")
+		return err
+	case <-ctx.Done():
+	}
+
+	lgr.Info("shutting down, draining requests", "Service", "Launch", "ctx.Done")
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout(cfg.Rest))
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		lgr.Error(err.Error(), "Service", "Launch", "Shutdown")
+		srv.Close() // Requests still in progress are cut off
+		return err
+	}
+	lgr.Info("all requests are drained", "Service", "Launch", "Shutdown")
+
+	return nil
+}
+
+// newRouter routes the API. ctx is the one of Launch: readiness fails once it's done, purges of the cache outlive it
+func (s Service) newRouter(ctx context.Context, cfg *config.Config, websockets *servers.WebSockets,
+	lgr *logger.Logger) (*gin.Engine, error) {
+	implAuth := servers.AuthServer{A: s.am}
+	implFile := servers.FileServer{F: s.fm, A: s.am, Upload: cfg.Upload}
+	implUpload := servers.UploadServer{U: s.upm, Upload: cfg.Upload, Resumable: cfg.Resumable}
+	implFolder := servers.FolderServer{Fo: s.fom, F: s.fm}
+	implShare := servers.ShareServer{S: s.shm}
+	implAudit := servers.AuditServer{Au: s.aum, AdminToken: cfg.Admin.Token}
+	implWebhook := servers.WebhookServer{W: s.wm}
+	implFeed := servers.FeedServer{Fd: s.fdm, Heartbeat: cfg.Feed.Heartbeat, AllowedOrigins: cfg.Feed.AllowedOrigins,
+		WebSockets: websockets}
+	implHealth := servers.HealthServer{H: s.hm, Stopping: ctx.Done()}
 
 	if !cfg.Project.Debug {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// Hits and misses of the cache are counted for metrics. Cached responses keep the request id of the current request
+	cacheByURI := func() gin.HandlerFunc {
+		return cache.CacheByRequestURI(s.rc, 2*time.Minute,
+			cache.WithOnHitCache(mw.CacheHit), cache.WithOnMissCache(mw.CacheMiss),
+			cache.WithDiscardHeaders([]string{mw.RequestIDHeader}))
+	}
+
 	router := gin.New()
 	router.HandleMethodNotAllowed = true // Обрабатывает 405 код
+	// Client IPs are used by rate limits and audit, so X-Forwarded-For is believed only from the configured proxies
+	if err := router.SetTrustedProxies(cfg.Rest.TrustedProxies); err != nil {
+		lgr.Error(err.Error(), "Service", "newRouter", "SetTrustedProxies")
+		return nil, err
+	}
+	router.Use(mw.RequestID())
 	router.Use(gin.Logger())
+	router.Use(mw.Tracing())
+	router.Use(mw.Metrics())
 	router.Use(gin.Recovery())
+	router.Use(mw.Client())
+
+	// Public documents may be read without a token. The limit goes before the cache, so cached responses count too.
+	// Documents aren't cached, so that every download is checked and audited
+	validateRead := mw.ValidateTokenInQuery(s.am, lgr)
+	var anonLimiter *mw.RateLimiter
+	if cfg.Anonymous.Enabled {
+		validateRead = mw.ValidateTokenOrAnonymous(s.am, lgr)
+		if cfg.Anonymous.Rate > 0 {
+			anonLimiter = mw.NewRateLimiter(cfg.Anonymous.Rate, cfg.Anonymous.Burst)
+		}
+	}
+	anonLimit := mw.LimitAnonymous(anonLimiter)
+
+	// The cache is purged by requests drained after ctx is done too
+	purgeCtx := context.WithoutCancel(ctx)
 
 	usrGr := router.Group("/api")
 	{
-		usrGr.POST("/register", mw.CachePurge(ctx, redisStore, lgr), implAuth.Register)
+		usrGr.POST("/register", mw.CachePurge(purgeCtx, s.rc, lgr), implAuth.Register)
 	}
 	authGR := router.Group("/api")
 	{
-		authGR.POST("/auth", mw.CachePurge(ctx, redisStore, lgr), implAuth.Auth)
-		authGR.DELETE("/auth/:token", mw.CachePurge(ctx, redisStore, lgr), implAuth.Logout)
+		authGR.POST("/auth", mw.CachePurge(purgeCtx, s.rc, lgr), implAuth.Auth)
+		authGR.DELETE("/auth/:token", mw.CachePurge(purgeCtx, s.rc, lgr), implAuth.Logout)
 	}
 
 	docsGr := router.Group("/api")
 	{
-		docsGr.POST("/docs", mw.ValidateTokenInMultipartFrom(s.am, lgr), mw.CachePurge(ctx, redisStore, lgr), implFile.UploadDoc)
-		docsGr.GET("/docs", cache.CacheByRequestURI(redisStore, 2*time.Minute), mw.ValidateTokenInQuery(s.am, lgr), implFile.GetDocsList)
-		docsGr.HEAD("/docs", cache.CacheByRequestURI(redisStore, 2*time.Minute), mw.ValidateTokenInQuery(s.am, lgr), implFile.GetDocsList)
-		docsGr.GET("/docs/:id", cache.CacheByRequestURI(redisStore, 2*time.Minute), mw.ValidateTokenInQuery(s.am, lgr), implFile.GetDoc)
-		docsGr.HEAD("/docs/:id", cache.CacheByRequestURI(redisStore, 2*time.Minute), mw.ValidateTokenInQuery(s.am, lgr), implFile.GetDoc)
-		docsGr.DELETE("/docs/:id", mw.ValidateTokenInQuery(s.am, lgr), mw.CachePurge(ctx, redisStore, lgr), implFile.DeleteDoc)
+		docsGr.POST("/docs", mw.ValidateTokenInMultipartFrom(s.am, lgr), mw.CachePurge(purgeCtx, s.rc, lgr), implFile.UploadDoc)
+		docsGr.POST("/docs/bulk", mw.ValidateTokenInMultipartFrom(s.am, lgr), mw.CachePurge(purgeCtx, s.rc, lgr), implFile.UploadDocs)
+		docsGr.POST("/docs/bulk-delete", mw.ValidateTokenInQuery(s.am, lgr), mw.CachePurge(purgeCtx, s.rc, lgr), implFile.BulkDeleteDocs)
+		docsGr.GET("/docs/archive", mw.ValidateTokenInQuery(s.am, lgr), implFile.GetDocsArchive)
+		docsGr.GET("/docs", anonLimit, cacheByURI(), validateRead, implFile.GetDocsList)
+		docsGr.HEAD("/docs", anonLimit, cacheByURI(), validateRead, implFile.GetDocsList)
+		docsGr.GET("/docs/:id", anonLimit, validateRead, implFile.GetDoc)
+		docsGr.HEAD("/docs/:id", anonLimit, validateRead, implFile.GetDoc)
+		docsGr.PATCH("/docs/:id", mw.ValidateTokenInQuery(s.am, lgr), mw.CachePurgeDoc(purgeCtx, s.rc, lgr), implFile.UpdateDoc)
+		docsGr.DELETE("/docs/:id", mw.ValidateTokenInQuery(s.am, lgr), mw.CachePurge(purgeCtx, s.rc, lgr), implFile.DeleteDoc)
+		docsGr.PUT("/docs/:id/tags", mw.ValidateTokenInQuery(s.am, lgr), mw.CachePurge(purgeCtx, s.rc, lgr), implFile.SetDocTags)
+		docsGr.PUT("/docs/:id/metadata", mw.ValidateTokenInQuery(s.am, lgr), mw.CachePurge(purgeCtx, s.rc, lgr), implFile.SetDocMetadata)
+		docsGr.GET("/tags", cacheByURI(), mw.ValidateTokenInQuery(s.am, lgr), implFile.GetTags)
+	}
+
+	trashGr := router.Group("/api")
+	{
+		trashGr.GET("/trash", mw.ValidateTokenInQuery(s.am, lgr), implFile.GetTrash)
+		trashGr.POST("/trash/:id/restore", mw.ValidateTokenInQuery(s.am, lgr), mw.CachePurge(purgeCtx, s.rc, lgr), implFile.RestoreDoc)
+	}
+
+	foldersGr := router.Group("/api")
+	{
+		foldersGr.GET("/folders", cacheByURI(), mw.ValidateTokenInQuery(s.am, lgr), implFolder.GetRootFolders)
+		foldersGr.POST("/folders", mw.ValidateTokenInQuery(s.am, lgr), mw.CachePurge(purgeCtx, s.rc, lgr), implFolder.CreateFolder)
+		foldersGr.GET("/folders/:id", cacheByURI(), mw.ValidateTokenInQuery(s.am, lgr), implFolder.GetFolder)
+		foldersGr.PATCH("/folders/:id", mw.ValidateTokenInQuery(s.am, lgr), mw.CachePurge(purgeCtx, s.rc, lgr), implFolder.UpdateFolder)
+		foldersGr.DELETE("/folders/:id", mw.ValidateTokenInQuery(s.am, lgr), mw.CachePurge(purgeCtx, s.rc, lgr), implFolder.DeleteFolder)
+		foldersGr.PUT("/folders/:id/grants", mw.ValidateTokenInQuery(s.am, lgr), mw.CachePurge(purgeCtx, s.rc, lgr), implFolder.SetFolderGrants)
+		foldersGr.POST("/docs/:id/move", mw.ValidateTokenInQuery(s.am, lgr), mw.CachePurge(purgeCtx, s.rc, lgr), implFolder.MoveDoc)
 	}
 
-	if err := router.Run(":" + strconv.Itoa(cfg.Rest.Port)); err != nil {
-		lgr.Error(err.Error(), "Service", "Launch", "router.Run")
+	uploadsGr := router.Group("/api")
+	{
+		uploadsGr.OPTIONS("/uploads", implUpload.GetUploadOptions)
+		uploadsGr.POST("/uploads", mw.ValidateTokenInQuery(s.am, lgr), implUpload.CreateUpload)
+		uploadsGr.HEAD("/uploads/:id", mw.ValidateTokenInQuery(s.am, lgr), implUpload.HeadUpload)
+		uploadsGr.PATCH("/uploads/:id", mw.ValidateTokenInQuery(s.am, lgr), mw.CachePurge(purgeCtx, s.rc, lgr), implUpload.PatchUpload)
+		uploadsGr.DELETE("/uploads/:id", mw.ValidateTokenInQuery(s.am, lgr), implUpload.DeleteUpload)
 	}
+
+	// Download counters change on every request, so nothing here is cached
+	sharesGr := router.Group("/api")
+	{
+		sharesGr.POST("/docs/:id/shares", mw.ValidateTokenInQuery(s.am, lgr), implShare.CreateShareLink)
+		sharesGr.GET("/docs/:id/shares", mw.ValidateTokenInQuery(s.am, lgr), implShare.GetShareLinks)
+		sharesGr.DELETE("/docs/:id/shares/:slug", mw.ValidateTokenInQuery(s.am, lgr), implShare.RevokeShareLink)
+	}
+
+	// Passwords of links are checked with bcrypt, so every attempt counts, even with a token
+	var shareLimiter *mw.RateLimiter
+	if cfg.Shares.Rate > 0 {
+		shareLimiter = mw.NewRateLimiter(cfg.Shares.Rate, cfg.Shares.Burst)
+	}
+	router.GET("/s/:slug", mw.LimitByIP(shareLimiter), implShare.OpenShareLink)
+
+	// New events appear all the time, so nothing here is cached
+	auditGr := router.Group("/api")
+	{
+		auditGr.GET("/admin/audit", implAudit.GetEvents)
+		auditGr.GET("/docs/:id/activity", mw.ValidateTokenInQuery(s.am, lgr), implAudit.GetDocActivity)
+	}
+
+	// Delivery statuses change in the background, so nothing here is cached
+	webhooksGr := router.Group("/api")
+	{
+		webhooksGr.POST("/webhooks", mw.ValidateTokenInQuery(s.am, lgr), implWebhook.CreateWebhook)
+		webhooksGr.GET("/webhooks", mw.ValidateTokenInQuery(s.am, lgr), implWebhook.GetWebhooks)
+		webhooksGr.DELETE("/webhooks/:id", mw.ValidateTokenInQuery(s.am, lgr), implWebhook.DeleteWebhook)
+		webhooksGr.GET("/webhooks/:id/deliveries", mw.ValidateTokenInQuery(s.am, lgr), implWebhook.GetDeliveries)
+		webhooksGr.POST("/webhooks/:id/deliveries/:delivery/retry", mw.ValidateTokenInQuery(s.am, lgr), implWebhook.RetryDelivery)
+	}
+
+	// Probes of orchestrators. Readiness is checked live every time
+	router.GET("/healthz", implHealth.Healthz)
+	router.HEAD("/healthz", implHealth.Healthz)
+	router.GET("/readyz", implHealth.Readyz)
+	router.HEAD("/readyz", implHealth.Readyz)
+
+	if cfg.Metrics.Enabled {
+		router.GET(metricsPath(cfg.Metrics), mw.BearerToken(cfg.Metrics.Token), gin.WrapH(metrics.Handler()))
+	}
+
+	// Streams are long-lived and per user, so nothing here is cached
+	if cfg.Feed.Enabled {
+		router.GET("/api/events", mw.ValidateTokenInQuery(s.am, lgr), implFeed.GetEvents)
+	}
+
+	return router, nil
 }
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+)
+
+func TestNewServerTimeouts(t *testing.T) {
+	tests := []struct {
+		name                  string
+		cfg                   config.Rest
+		wantHeader, wantWhole time.Duration
+	}{
+		{"defaults", config.Rest{Port: 8080}, defaultReadHeaderTimeout, defaultReadTimeout},
+		{"configured", config.Rest{Port: 8080, ReadHeaderTimeout: 5 * time.Second, ReadTimeout: time.Minute},
+			5 * time.Second, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newServer(tt.cfg, nil)
+
+			if srv.Addr != ":8080" {
+				t.Errorf("Addr = %q; want :8080", srv.Addr)
+			}
+			if srv.ReadHeaderTimeout != tt.wantHeader || srv.ReadTimeout != tt.wantWhole {
+				t.Errorf("timeouts = %v, %v; want %v, %v", srv.ReadHeaderTimeout, srv.ReadTimeout, tt.wantHeader, tt.wantWhole)
+			}
+		})
+	}
+}
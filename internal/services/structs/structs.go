@@ -1,7 +1,9 @@
 package structs
 
 import (
+	"github.com/Kapeland/task-Astral/internal/models/structs"
 	jsoniter "github.com/json-iterator/go"
+	"io"
 	"time"
 )
 
@@ -28,6 +30,7 @@ type LogoutResp struct {
 type AddDocForm struct {
 	Meta DocMeta
 	Json jsoniter.RawMessage
+	Data io.Reader
 }
 
 type DocMeta struct {
@@ -37,6 +40,12 @@ type DocMeta struct {
 	Token  string   `json:"token"`
 	Mime   string   `json:"mime"`
 	Grant  []string `json:"grant"`
+	SHA256 string   `json:"sha256"`
+	MD5    string   `json:"md5"`
+	Folder string   `json:"folder_id"`
+
+	Tags     []string          `json:"tags"`
+	Metadata map[string]string `json:"metadata"`
 }
 
 type DocData struct {
@@ -49,11 +58,12 @@ type AddDocResp struct {
 }
 
 type GetDocListReq struct {
-	Token string `json:"token"`
-	Login string `json:"login"`
-	Key   string `json:"key"`
-	Value string `json:"value"`
-	Limit int    `json:"limit"`
+	Token  string `json:"token"`
+	Login  string `json:"login"`
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Limit  int    `json:"limit"`
+	Folder string `json:"folder"`
 }
 
 type DataResp struct {
@@ -63,13 +73,160 @@ type DataBody struct {
 	Docs []Doc `json:"docs,omitempty"`
 }
 type Doc struct {
+	ID      string     `json:"id"`
+	Name    string     `json:"name"`
+	Mime    string     `json:"mime"`
+	IsFile  bool       `json:"file"`
+	Public  bool       `json:"public"`
+	Created time.Time  `json:"created"`
+	Granted []string   `json:"grant"`
+	Deleted *time.Time `json:"deleted,omitempty"`
+	SHA256  string     `json:"sha256,omitempty"`
+	MD5     string     `json:"md5,omitempty"`
+	Folder  string     `json:"folder_id,omitempty"`
+
+	Tags     structs.Tags     `json:"tags,omitempty"`
+	Metadata structs.Metadata `json:"metadata,omitempty"`
+}
+
+type BulkDeleteReq struct {
+	IDs []string `json:"ids"`
+}
+
+// BulkItem is the outcome of a bulk operation for one document
+type BulkItem struct {
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	OK    bool   `json:"ok"`
+	Code  int    `json:"code,omitempty"` // Status code of the failure
+	Error string `json:"error,omitempty"`
+}
+
+type BulkResp struct {
+	Data BulkBody `json:"data"`
+}
+type BulkBody struct {
+	Results []BulkItem `json:"results"`
+}
+
+// FolderReq changes only the fields which are present
+type FolderReq struct {
+	Name   *string `json:"name"`
+	Parent *string `json:"parent_id"`
+}
+
+type Folder struct {
 	ID      string    `json:"id"`
 	Name    string    `json:"name"`
-	Mime    string    `json:"mime"`
-	IsFile  bool      `json:"file"`
-	Public  bool      `json:"public"`
+	Parent  string    `json:"parent_id,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+type FolderResp struct {
+	Data FolderBody `json:"data"`
+}
+type FolderBody struct {
+	Folder      *Folder  `json:"folder,omitempty"`
+	Breadcrumbs []Folder `json:"breadcrumbs,omitempty"`
+	Folders     []Folder `json:"folders"`
+	Docs        []Doc    `json:"docs,omitempty"`
+}
+
+type GrantsReq struct {
+	Grant []string `json:"grant"`
+}
+
+type TagsReq struct {
+	Tags []string `json:"tags"`
+}
+
+type MetadataReq struct {
+	Metadata map[string]string `json:"metadata"`
+}
+
+type TagsResp struct {
+	Data TagsBody `json:"data"`
+}
+
+type TagsBody struct {
+	Tags []structs.TagCount `json:"tags"`
+}
+
+// UpdateDocReq holds fields to change. Missing fields are kept
+type UpdateDocReq struct {
+	Name   *string `json:"name"`
+	Public *bool   `json:"public"`
+	Mime   *string `json:"mime"`
+}
+
+// ShareReq describes a new share link. All fields are optional
+type ShareReq struct {
+	Password     string     `json:"password"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	MaxDownloads *int       `json:"max_downloads"`
+}
+
+type Share struct {
+	Slug         string     `json:"slug"`
+	URL          string     `json:"url"`
+	Password     bool       `json:"password"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	MaxDownloads *int       `json:"max_downloads,omitempty"`
+	Downloads    int        `json:"downloads"`
+	Created      time.Time  `json:"created"`
+}
+
+type ShareResp struct {
+	Data ShareBody `json:"data"`
+}
+type ShareBody struct {
+	Share  *Share  `json:"share,omitempty"`
+	Shares []Share `json:"shares"`
+}
+
+type AuditResp struct {
+	Data AuditBody `json:"data"`
+}
+type AuditBody struct {
+	Events []structs.AuditEvent `json:"events"`
+	Next   string               `json:"next,omitempty"` // Value of 'before' for the next page, none on the last one
+}
+
+type WebhookReq struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"` // Empty subscribes to all events
+}
+
+type Webhook struct {
+	ID      string    `json:"id"`
+	URL     string    `json:"url"`
+	Events  []string  `json:"events"`
+	Secret  string    `json:"secret,omitempty"` // Shown only when the webhook is created
 	Created time.Time `json:"created"`
-	Granted []string  `json:"grant"`
+}
+
+type WebhookResp struct {
+	Data WebhookBody `json:"data"`
+}
+type WebhookBody struct {
+	Webhook  *Webhook  `json:"webhook,omitempty"`
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+type DeliveriesResp struct {
+	Data DeliveriesBody `json:"data"`
+}
+type DeliveriesBody struct {
+	Deliveries []structs.WebhookDelivery `json:"deliveries"`
+	Next       string                    `json:"next,omitempty"` // Value of 'before' for the next page, none on the last one
+}
+
+type MoveDocReq struct {
+	Folder string `json:"folder_id"` // Empty for the root
+}
+
+type HealthResp struct {
+	Status string `json:"status"`
 }
 
 type Response struct {
@@ -81,6 +238,7 @@ type ErrResponse struct {
 }
 
 type ErrBody struct {
-	Code int    `json:"code"`
-	Text string `json:"text"`
+	Code      int    `json:"code"`
+	Text      string `json:"text"`
+	RequestID string `json:"request_id,omitempty"`
 }
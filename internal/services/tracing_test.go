@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/storage"
+	"github.com/Kapeland/task-Astral/internal/storage/db"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/audit"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/auth"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/files"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/folders"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/users"
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/Kapeland/task-Astral/internal/utils/tracing"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const fakeDriverName = "fakepg"
+
+func TestMain(m *testing.M) {
+	logger.CreateLogger(&config.Config{Logger: config.Logger{Lvl: "error", LogRate: 1}})
+	gin.SetMode(gin.TestMode)
+	sql.Register(fakeDriverName, fakeDriver{})
+	os.Exit(m.Run())
+}
+
+// fakeDriver answers the queries of a download of a clean document of alice. Statements change nothing
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{query: query}, nil
+}
+
+func (fakeConn) Close() error {
+	return nil
+}
+
+func (fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeStmt struct {
+	query string
+}
+
+func (fakeStmt) Close() error {
+	return nil
+}
+
+func (fakeStmt) NumInput() int {
+	return -1
+}
+
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	switch {
+	case strings.Contains(s.query, "SELECT login, valid_until, token FROM auth_schema.users_auth"):
+		return &fakeRows{columns: []string{"login", "valid_until", "token"},
+			values: [][]driver.Value{{"alice", time.Now().Add(time.Hour), "token"}}}, nil
+	case strings.Contains(s.query, "SELECT login FROM auth_schema.users_auth"):
+		return &fakeRows{columns: []string{"login"}, values: [][]driver.Value{{"alice"}}}, nil
+	case strings.Contains(s.query, "scan_status"):
+		return &fakeRows{columns: []string{"id", "mime", "title", "owner", "is_public", "file", "scan_status", "sha256", "md5"},
+			values: [][]driver.Value{{testDocID, "text/plain", "doc.txt", "alice", false, true, "clean", "", ""}}}, nil
+	case strings.Contains(s.query, "key_id, wrapped_key"):
+		return &fakeRows{columns: []string{"id", "key_id", "wrapped_key"}, values: [][]driver.Value{{testDocID, nil, nil}}}, nil
+	default:
+		return &fakeRows{}, nil
+	}
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// fakeProvider keeps the content of every document in memory. Methods not used by tests panic.
+type fakeProvider struct {
+	storage.FileProvider
+}
+
+func (fakeProvider) OpenFile(key string) (io.ReadSeekCloser, error) {
+	return nopReadSeekCloser{strings.NewReader("content")}, nil
+}
+
+type nopReadSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopReadSeekCloser) Close() error {
+	return nil
+}
+
+const testDocID = "0b7c1f3e-8d4a-4c52-9a57-3f0e6b2d9c41"
+
+func TestTracingGetDoc(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(tracing.NewProvider(sdktrace.NewSimpleSpanProcessor(exporter), "test", sdktrace.AlwaysSample()))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	pg := db.NewPgDatabase(sqlx.MustOpen(fakeDriverName, ""))
+	t.Cleanup(pg.Close)
+	authStorage := storage.NewAuthStorage(auth.New(pg))
+	usersStorage := storage.NewUsersStorage(users.New(pg))
+	auditStorage := storage.NewAuditStorage(audit.New(pg))
+	folderStorage := storage.NewFolderStorage(folders.New(pg))
+	fileStorage := storage.NewFileStorage(files.New(pg), fakeProvider{}, nil)
+	fmdl := models.NewModelFiles(&fileStorage, &usersStorage, &authStorage, &folderStorage, &auditStorage)
+	amdl := models.NewModelAuth(&authStorage, &usersStorage, &auditStorage)
+	serv := NewService(&fmdl, &amdl, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	lgr := logger.GetLogger()
+	router, err := serv.newRouter(context.Background(), &config.Config{Project: config.Project{Debug: true}}, nil, &lgr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/docs/"+testDocID+"?token=token", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "content" {
+		t.Fatalf("status = %d, body %q; want 200 \"content\"", rec.Code, rec.Body)
+	}
+
+	spans := exporter.GetSpans()
+	find := func(name string) tracetest.SpanStub {
+		t.Helper()
+		for _, span := range spans {
+			if span.Name == name {
+				return span
+			}
+		}
+		t.Fatalf("no span %s among %d", name, len(spans))
+		return tracetest.SpanStub{}
+	}
+
+	server := find("GET /api/docs/:id")
+	if server.Parent.IsValid() {
+		t.Errorf("request span has a parent %s", server.Parent.SpanID())
+	}
+	for _, link := range []struct{ child, parent string }{
+		{"ModelAuth.ValidateToken", "GET /api/docs/:id"},
+		{"auth.GetSecretBySecret", "ModelAuth.ValidateToken"},
+		{"ModelFiles.GetDoc", "GET /api/docs/:id"},
+		{"files.GetDoc", "ModelFiles.GetDoc"},
+		{"files.GetDocKey", "ModelFiles.GetDoc"},
+		{"blob.open", "ModelFiles.GetDoc"},
+	} {
+		child, parent := find(link.child), find(link.parent)
+		if child.Parent.SpanID() != parent.SpanContext.SpanID() {
+			t.Errorf("parent of %s is %s; want %s", link.child, child.Parent.SpanID(), parent.SpanContext.SpanID())
+		}
+		if child.SpanContext.TraceID() != server.SpanContext.TraceID() {
+			t.Errorf("%s is in trace %s; want %s", link.child, child.SpanContext.TraceID(), server.SpanContext.TraceID())
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package clamav
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+)
+
+// chunkSize must not exceed StreamMaxLength of clamd
+const chunkSize = 64 * 1024
+
+// ErrScanFailed is an ERROR reply of clamd, e.g. to a stream over its size limit. The document is rejected
+var ErrScanFailed = fmt.Errorf("clamd failed to scan: %w", models.ErrScanRejected)
+
+// Client talks to clamd using its INSTREAM protocol. Any daemon speaking the protocol will do,
+// e.g. a fake one listening on a local port.
+type Client struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// New creates a clamd client. network is "tcp" or "unix".
+func New(network string, address string, timeout time.Duration) *Client {
+	return &Client{network: network, address: address, timeout: timeout}
+}
+
+// Scan streams data to clamd and returns its verdict. Every chunk and the reply must come within the timeout,
+// so large documents aren't cut off while clamd keeps up
+func (c *Client) Scan(ctx context.Context, data io.Reader) (structs.ScanVerdict, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return structs.ScanVerdict{}, err
+	}
+	defer conn.Close()
+
+	w := bufio.NewWriterSize(conn, chunkSize+4)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return structs.ScanVerdict{}, err
+	}
+
+	buf := make([]byte, chunkSize)
+	size := make([]byte, 4)
+	for {
+		n, err := data.Read(buf)
+		if n > 0 {
+			c.extendDeadline(conn)
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := w.Write(size); err != nil {
+				return earlyReply(conn, err)
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return earlyReply(conn, err)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return structs.ScanVerdict{}, err
+		}
+	}
+	c.extendDeadline(conn)
+	binary.BigEndian.PutUint32(size, 0) // End of stream
+	if _, err := w.Write(size); err != nil {
+		return earlyReply(conn, err)
+	}
+	if err := w.Flush(); err != nil {
+		return earlyReply(conn, err)
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return structs.ScanVerdict{}, err
+	}
+
+	return parseReply(reply)
+}
+
+// Ping checks that clamd is reachable
+func (c *Client) Ping(ctx context.Context) error {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	c.extendDeadline(conn)
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return err
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("%w: unexpected reply to PING: %s", ErrScanFailed, reply)
+	}
+	return nil
+}
+
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	dialer := net.Dialer{}
+	return dialer.DialContext(ctx, c.network, c.address)
+}
+
+// extendDeadline gives the next exchange with clamd the timeout
+func (c *Client) extendDeadline(conn net.Conn) {
+	if c.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+}
+
+// earlyReply returns the reply clamd may have sent before closing the connection in the middle of the stream,
+// e.g. when the size limit is exceeded. writeErr is returned if there is none.
+func earlyReply(conn net.Conn, writeErr error) (structs.ScanVerdict, error) {
+	reply, err := readReply(conn)
+	if err != nil || reply == "" {
+		return structs.ScanVerdict{}, writeErr
+	}
+	return parseReply(reply)
+}
+
+// readReply reads a reply terminated by a zero byte
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && !(errors.Is(err, io.EOF) && len(reply) > 0) {
+		return "", err
+	}
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
+
+// parseReply parses replies like "stream: OK", "stream: Eicar-Signature FOUND" or "... ERROR"
+func parseReply(reply string) (structs.ScanVerdict, error) {
+	result := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case result == "OK":
+		return structs.ScanVerdict{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return structs.ScanVerdict{
+			Infected:  true,
+			Signature: strings.TrimSuffix(result, " FOUND"),
+		}, nil
+	default:
+		return structs.ScanVerdict{}, fmt.Errorf("%w: %s", ErrScanFailed, reply)
+	}
+}
@@ -0,0 +1,162 @@
+package clamav
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models"
+)
+
+const sizeLimitReply = "INSTREAM size limit exceeded. ERROR"
+
+// fakeDaemon speaks zINSTREAM and zPING like clamd. It replies with reply to every stream,
+// or with sizeLimitReply once more than limit bytes are streamed if limit isn't 0.
+type fakeDaemon struct {
+	reply    string
+	limit    int
+	received chan []byte
+}
+
+func startFakeDaemon(t *testing.T, d *fakeDaemon) *Client {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	d.received = make(chan []byte, 1)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+
+	return New("tcp", l.Addr().String(), time.Second)
+}
+
+func (d *fakeDaemon) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	cmd, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+	switch cmd {
+	case "zPING\x00":
+		conn.Write([]byte("PONG\x00"))
+		return
+	case "zINSTREAM\x00":
+	default:
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+
+	var data bytes.Buffer
+	size := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, size); err != nil {
+			return
+		}
+		n := binary.BigEndian.Uint32(size)
+		if n == 0 {
+			break
+		}
+		if _, err := io.CopyN(&data, r, int64(n)); err != nil {
+			return
+		}
+		if d.limit > 0 && data.Len() > d.limit {
+			conn.Write([]byte(sizeLimitReply + "\x00"))
+			io.Copy(io.Discard, r) // Let the client finish writing, so the reply isn't lost to a reset
+			return
+		}
+	}
+	d.received <- data.Bytes()
+	conn.Write([]byte(d.reply + "\x00"))
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name          string
+		reply         string
+		wantInfected  bool
+		wantSignature string
+		wantErr       bool
+	}{
+		{"clean", "stream: OK", false, "", false},
+		{"infected", "stream: Eicar-Test-Signature FOUND", true, "Eicar-Test-Signature", false},
+		{"error", "stream: Can't allocate memory ERROR", false, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDaemon{reply: tt.reply}
+			c := startFakeDaemon(t, d)
+			data := strings.Repeat("x", chunkSize*2+100) // Several chunks
+
+			verdict, err := c.Scan(context.Background(), strings.NewReader(data))
+			if tt.wantErr {
+				if !errors.Is(err, ErrScanFailed) {
+					t.Fatalf("Scan error = %v; want ErrScanFailed", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if verdict.Infected != tt.wantInfected || verdict.Signature != tt.wantSignature {
+				t.Errorf("verdict = %+v; want infected %v, signature %q", verdict, tt.wantInfected, tt.wantSignature)
+			}
+			if got := <-d.received; string(got) != data {
+				t.Errorf("daemon received %d bytes; want %d", len(got), len(data))
+			}
+		})
+	}
+}
+
+func TestScanSizeLimit(t *testing.T) {
+	c := startFakeDaemon(t, &fakeDaemon{limit: chunkSize})
+
+	_, err := c.Scan(context.Background(), strings.NewReader(strings.Repeat("x", chunkSize*4)))
+	if !errors.Is(err, ErrScanFailed) || !strings.Contains(err.Error(), "size limit") {
+		t.Fatalf("Scan error = %v; want ErrScanFailed with the size limit reply", err)
+	}
+	if !errors.Is(err, models.ErrScanRejected) {
+		t.Errorf("Scan error = %v; want the document rejected", err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	c := startFakeDaemon(t, &fakeDaemon{})
+
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+}
+
+func TestScanUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	_, err = New("tcp", addr, time.Second).Scan(context.Background(), strings.NewReader("x"))
+	if err == nil || errors.Is(err, ErrScanFailed) || errors.Is(err, models.ErrScanRejected) {
+		t.Fatalf("Scan error = %v; want a network error, so the document is retried", err)
+	}
+}
@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"context"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"time"
+)
+
+type AuditRepo interface {
+	AddEvent(ctx context.Context, event *structs.AuditEvent) error
+	GetEvents(ctx context.Context, filter structs.AuditFilter) ([]structs.AuditEvent, error)
+}
+
+type AuditStorage struct {
+	auditRepo AuditRepo
+}
+
+func NewAuditStorage(auditRepo AuditRepo) AuditStorage {
+	return AuditStorage{auditRepo: auditRepo}
+}
+
+// AddEvent appends event to the audit log. Zero CreatedAt is set to now.
+func (s *AuditStorage) AddEvent(ctx context.Context, event structs.AuditEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	return s.auditRepo.AddEvent(ctx, &event)
+}
+
+func (s *AuditStorage) GetEvents(ctx context.Context, filter structs.AuditFilter) ([]structs.AuditEvent, error) {
+	return s.auditRepo.GetEvents(ctx, filter)
+}
@@ -0,0 +1,96 @@
+package cachestore
+
+import (
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	stateClosed   breakerState = iota // Calls go through
+	stateOpen                         // Calls are refused until the cooldown passes
+	stateHalfOpen                     // One call probes whether the dependency is back
+)
+
+// Answers of breaker.allow
+const (
+	allowed = iota
+	refused
+	probe // The caller must report the result, other calls are refused meanwhile
+)
+
+// breaker stops calls to a dependency after threshold consecutive failures and lets one call through
+// every cooldown to find out if it's back
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown}
+}
+
+func (b *breaker) allow() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateClosed:
+		return allowed
+	case stateOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return refused
+		}
+		b.state = stateHalfOpen
+		return probe
+	default: // Another call is probing
+		return refused
+	}
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = stateClosed
+	b.failures = 0
+}
+
+// failure counts the failed call. Returns true if the breaker has opened by it
+func (b *breaker) failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == stateClosed && b.failures < b.threshold {
+		return false
+	}
+	opened := b.state == stateClosed
+	b.state = stateOpen
+	b.openedAt = time.Now()
+	return opened
+}
+
+// trip opens the breaker at once. Returns true if it has been closed
+func (b *breaker) trip() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	opened := b.state == stateClosed
+	b.state = stateOpen
+	b.openedAt = time.Now()
+	return opened
+}
+
+func (b *breaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state != stateClosed
+}
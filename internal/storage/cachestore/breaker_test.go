@@ -0,0 +1,75 @@
+package cachestore
+
+import (
+	"testing"
+	"time"
+)
+
+// cooledDown lets the cooldown of an open breaker pass
+func cooledDown(b *breaker) {
+	b.openedAt = time.Now().Add(-2 * b.cooldown)
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b := newBreaker(3, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if b.failure() {
+			t.Fatalf("opened after %d failures; want 3", i+1)
+		}
+		if b.allow() != allowed {
+			t.Fatalf("calls are refused after %d failures", i+1)
+		}
+	}
+	if !b.failure() {
+		t.Fatalf("not opened after 3 failures")
+	}
+	if b.allow() != refused || !b.isOpen() {
+		t.Errorf("calls go through an open breaker")
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b := newBreaker(2, time.Minute)
+
+	b.failure()
+	b.success()
+	if b.failure() {
+		t.Errorf("opened by failures which aren't consecutive")
+	}
+}
+
+func TestBreakerProbeCloses(t *testing.T) {
+	b := newBreaker(1, time.Minute)
+	b.failure()
+	cooledDown(b)
+
+	if got := b.allow(); got != probe {
+		t.Fatalf("allow after the cooldown = %d; want probe", got)
+	}
+	if got := b.allow(); got != refused {
+		t.Fatalf("allow while probing = %d; want refused", got)
+	}
+	b.success()
+
+	if b.isOpen() || b.allow() != allowed {
+		t.Errorf("breaker isn't closed after a successful probe")
+	}
+}
+
+func TestBreakerProbeFailureOpens(t *testing.T) {
+	b := newBreaker(3, time.Minute)
+	b.trip()
+	cooledDown(b)
+
+	if got := b.allow(); got != probe {
+		t.Fatalf("allow after the cooldown = %d; want probe", got)
+	}
+	if b.failure() {
+		t.Errorf("failed probe reports the breaker as just opened")
+	}
+
+	if got := b.allow(); got != refused {
+		t.Errorf("allow after a failed probe = %d; want refused until the next cooldown", got)
+	}
+}
@@ -0,0 +1,195 @@
+package cachestore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+
+	"github.com/chenyahui/gin-cache/persist"
+	"github.com/go-redis/redis/v8"
+)
+
+// Defaults of config.Cache
+const (
+	defaultThreshold = 5
+	defaultCooldown  = 10 * time.Second
+	defaultMemoryTTL = 30 * time.Second
+)
+
+// keyPrefix is put before keys of responses in Redis. The feed and the outbox sinks use the same Redis,
+// so only keys with it are purged
+const keyPrefix = "cache:"
+
+// probeTimeout bounds the purge made by the probe, so a request doesn't hang on a Redis which is still down
+const probeTimeout = 2 * time.Second
+
+// Store keeps cached responses in Redis. While Redis is unavailable they are kept in memory of this instance,
+// so the cache stays fast and requests don't wait for Redis. Redis is probed every cooldown and the cached
+// responses are purged from it when it's back, as purges made meanwhile have missed it
+type Store struct {
+	redis     *persist.RedisStore
+	memory    *persist.MemoryStore
+	memoryTTL time.Duration
+	breaker   *breaker
+}
+
+var _ persist.CacheStore = (*Store)(nil)
+
+func New(rdb *redis.Client, cfg config.Cache) *Store {
+	threshold, cooldown, memoryTTL := cfg.FailureThreshold, cfg.Cooldown, cfg.MemoryTTL
+	if threshold <= 0 {
+		threshold = defaultThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultCooldown
+	}
+	if memoryTTL <= 0 {
+		memoryTTL = defaultMemoryTTL
+	}
+	return &Store{
+		redis:     persist.NewRedisStore(rdb),
+		memory:    persist.NewMemoryStore(memoryTTL),
+		memoryTTL: memoryTTL,
+		breaker:   newBreaker(threshold, cooldown),
+	}
+}
+
+// useRedis tells whether Redis may be called. The probe after the cooldown purges the cached responses from Redis,
+// so nothing stale is served
+func (s *Store) useRedis() bool {
+	switch s.breaker.allow() {
+	case allowed:
+		return true
+	case probe:
+		ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+		defer cancel()
+		if err := s.deleteMatching(ctx, []string{"*"}); err != nil {
+			s.breaker.failure()
+			return false
+		}
+		s.breaker.success()
+		logger.GetLogger().Info("redis is back, the response cache has moved back to it", "Store", "useRedis", "deleteMatching")
+		return true
+	default:
+		return false
+	}
+}
+
+// done reports the result of a Redis call. Misses are answers, not failures
+func (s *Store) done(err error) bool {
+	if err == nil || errors.Is(err, persist.ErrCacheMiss) {
+		s.breaker.success()
+		return true
+	}
+	if s.breaker.failure() {
+		s.switchToMemory(err)
+	}
+	return false
+}
+
+// switchToMemory drops responses left in memory since the last outage, purges made since then have missed them
+func (s *Store) switchToMemory(err error) {
+	_ = s.memory.Cache.Purge()
+	logger.GetLogger().Warn("redis is unavailable, responses are cached in memory: "+err.Error(), "Store", "switchToMemory", "")
+}
+
+func (s *Store) Get(key string, value interface{}) error {
+	if s.useRedis() {
+		err := s.redis.Get(keyPrefix+key, value)
+		if s.done(err) {
+			return err
+		}
+	}
+	return s.memory.Get(key, value)
+}
+
+func (s *Store) Set(key string, value interface{}, expire time.Duration) error {
+	if s.useRedis() && s.done(s.redis.Set(keyPrefix+key, value, expire)) {
+		return nil
+	}
+	return s.memory.Set(key, value, min(expire, s.memoryTTL))
+}
+
+func (s *Store) Delete(key string) error {
+	_ = s.memory.Delete(key)
+	if s.useRedis() {
+		err := s.redis.Delete(keyPrefix + key)
+		s.done(err)
+		return err
+	}
+	return nil
+}
+
+// PurgeAll removes all cached responses
+func (s *Store) PurgeAll(ctx context.Context) error {
+	_ = s.memory.Cache.Purge()
+	if !s.useRedis() {
+		return nil // Redis is purged when it's back
+	}
+	return s.purged(s.deleteMatching(ctx, []string{"*"}))
+}
+
+// PurgeMatching removes cached responses with keys matching the Redis patterns. All responses kept in memory are removed
+func (s *Store) PurgeMatching(ctx context.Context, patterns []string) error {
+	_ = s.memory.Cache.Purge()
+	if !s.useRedis() {
+		return nil
+	}
+	return s.purged(s.deleteMatching(ctx, patterns))
+}
+
+// deleteMatching removes keys of responses matching the patterns from Redis, other keys are kept
+func (s *Store) deleteMatching(ctx context.Context, patterns []string) error {
+	for _, pattern := range redisPatterns(patterns) {
+		iter := s.redis.RedisClient.Scan(ctx, 0, pattern, 0).Iterator()
+		for iter.Next(ctx) {
+			if err := s.redis.RedisClient.Del(ctx, iter.Val()).Err(); err != nil {
+				return err
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redisPatterns puts keyPrefix before the patterns of response keys
+func redisPatterns(patterns []string) []string {
+	prefixed := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		prefixed[i] = keyPrefix + pattern
+	}
+	return prefixed
+}
+
+// purged reports the result of a purge. Redis which has missed a purge may serve stale responses,
+// so it isn't used until it's purged by the next probe
+func (s *Store) purged(err error) error {
+	if err == nil {
+		s.breaker.success()
+		return nil
+	}
+	if s.breaker.trip() {
+		s.switchToMemory(err)
+	}
+	return err
+}
+
+// Check reports whether responses are cached in Redis
+func (s *Store) Check(context.Context) error {
+	if s.breaker.isOpen() {
+		return errors.New("redis is unavailable, responses are cached in memory")
+	}
+	return nil
+}
+
+// Trip switches the cache to memory at once, e.g. if Redis hasn't answered at startup
+func (s *Store) Trip(err error) {
+	if s.breaker.trip() {
+		s.switchToMemory(err)
+	}
+}
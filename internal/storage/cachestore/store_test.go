@@ -0,0 +1,301 @@
+package cachestore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+
+	"github.com/chenyahui/gin-cache/persist"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestMain(m *testing.M) {
+	logger.CreateLogger(&config.Config{Logger: config.Logger{Lvl: "error", LogRate: 1}})
+	os.Exit(m.Run())
+}
+
+func TestRedisPatterns(t *testing.T) {
+	got := redisPatterns([]string{"*", "/api/docs?*"})
+
+	want := []string{"cache:*", "cache:/api/docs?*"}
+	if !slices.Equal(got, want) {
+		t.Errorf("redisPatterns = %q; want %q", got, want)
+	}
+}
+
+// fakeRedis speaks enough RESP for the store: GET, SET, DEL and SCAN over keys kept in memory.
+// Expiry isn't kept
+type fakeRedis struct {
+	mu   sync.Mutex
+	keys map[string]string
+}
+
+func startFakeRedis(t *testing.T) (*fakeRedis, *redis.Client) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	r := &fakeRedis{keys: make(map[string]string)}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+
+	rdb := redis.NewClient(&redis.Options{Addr: l.Addr().String(), MaxRetries: -1})
+	t.Cleanup(func() { rdb.Close() })
+	return r, rdb
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+
+	for {
+		cmd, err := readCommand(br)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, r.do(cmd)); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads an array of bulk strings
+func readCommand(br *bufio.Reader) ([]string, error) {
+	readLine := func(prefix byte) (int, error) {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+		if len(line) < 3 || line[0] != prefix {
+			return 0, errors.New("bad RESP line " + strconv.Quote(line))
+		}
+		return strconv.Atoi(line[1 : len(line)-2])
+	}
+
+	n, err := readLine('*')
+	if err != nil {
+		return nil, err
+	}
+	cmd := make([]string, n)
+	for i := range cmd {
+		size, err := readLine('$')
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(br, arg); err != nil {
+			return nil, err
+		}
+		cmd[i] = string(arg[:size])
+	}
+	return cmd, nil
+}
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func (r *fakeRedis) do(cmd []string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch cmd[0] {
+	case "get":
+		value, ok := r.keys[cmd[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(value)
+	case "set":
+		r.keys[cmd[1]] = cmd[2]
+		return "+OK\r\n"
+	case "del":
+		deleted := 0
+		for _, key := range cmd[1:] {
+			if _, ok := r.keys[key]; ok {
+				delete(r.keys, key)
+				deleted++
+			}
+		}
+		return ":" + strconv.Itoa(deleted) + "\r\n"
+	case "scan": // scan 0 match <pattern>, all keys are returned at once
+		var matched []string
+		for key := range r.keys {
+			if globMatch(cmd[3], key) {
+				matched = append(matched, key)
+			}
+		}
+		reply := "*2\r\n" + bulk("0") + "*" + strconv.Itoa(len(matched)) + "\r\n"
+		for _, key := range matched {
+			reply += bulk(key)
+		}
+		return reply
+	default:
+		return "-ERR unknown command '" + cmd[0] + "'\r\n"
+	}
+}
+
+// globMatch matches like Redis does with * and ?, which unlike path.Match cross slashes
+func globMatch(pattern string, s string) bool {
+	switch {
+	case pattern == "":
+		return s == ""
+	case pattern[0] == '*':
+		for i := 0; i <= len(s); i++ {
+			if globMatch(pattern[1:], s[i:]) {
+				return true
+			}
+		}
+		return false
+	case s == "":
+		return false
+	case pattern[0] == '?' || pattern[0] == s[0]:
+		return globMatch(pattern[1:], s[1:])
+	default:
+		return false
+	}
+}
+
+func (r *fakeRedis) has(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.keys[key]
+	return ok
+}
+
+func (r *fakeRedis) set(key string, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[key] = value
+}
+
+func TestStoreUsesRedis(t *testing.T) {
+	r, rdb := startFakeRedis(t)
+	s := New(rdb, config.Cache{})
+
+	if err := s.Set("/api/docs", "cached", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if !r.has(keyPrefix + "/api/docs") {
+		t.Fatalf("response isn't cached in redis")
+	}
+	var got string
+	if err := s.Get("/api/docs", &got); err != nil || got != "cached" {
+		t.Fatalf("Get = %q, %v; want \"cached\"", got, err)
+	}
+	if err := s.Get("/api/missing", &got); !errors.Is(err, persist.ErrCacheMiss) {
+		t.Errorf("Get of a missing key = %v; want ErrCacheMiss", err)
+	}
+	if err := s.Check(context.Background()); err != nil {
+		t.Errorf("Check = %v; want redis in use", err)
+	}
+}
+
+func TestStoreFallsBackToMemory(t *testing.T) {
+	r, rdb := startFakeRedis(t)
+	s := New(rdb, config.Cache{Cooldown: time.Hour})
+	s.Trip(errors.New("redis hasn't answered"))
+
+	if err := s.Set("/api/docs", "cached", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if r.has(keyPrefix + "/api/docs") {
+		t.Errorf("response is cached in redis while the breaker is open")
+	}
+	var got string
+	if err := s.Get("/api/docs", &got); err != nil || got != "cached" {
+		t.Fatalf("Get = %q, %v; want \"cached\" from memory", got, err)
+	}
+
+	// Responses cached in redis before the outage may be stale, so they aren't served
+	r.set(keyPrefix+"/api/stale", "stale")
+	if err := s.Get("/api/stale", &got); !errors.Is(err, persist.ErrCacheMiss) {
+		t.Errorf("Get of a key in redis = %q, %v; want ErrCacheMiss", got, err)
+	}
+	if err := s.Check(context.Background()); err == nil {
+		t.Errorf("Check = nil; want the memory fallback reported")
+	}
+}
+
+func TestStorePurgeAll(t *testing.T) {
+	r, rdb := startFakeRedis(t)
+	s := New(rdb, config.Cache{})
+	r.set("feed:cursor", "42") // Not a response, so it's kept
+	if err := s.Set("/api/docs", "cached", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.memory.Set("/api/folders", "cached", time.Minute); err != nil { // Left from an outage
+		t.Fatal(err)
+	}
+
+	if err := s.PurgeAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.has(keyPrefix + "/api/docs") {
+		t.Errorf("response is left in redis")
+	}
+	if !r.has("feed:cursor") {
+		t.Errorf("key of others is purged from redis")
+	}
+	var got string
+	if err := s.memory.Get("/api/folders", &got); !errors.Is(err, persist.ErrCacheMiss) {
+		t.Errorf("response is left in memory: %q, %v", got, err)
+	}
+}
+
+func TestStoreProbePurgesRedis(t *testing.T) {
+	r, rdb := startFakeRedis(t)
+	s := New(rdb, config.Cache{Cooldown: time.Hour})
+	r.set(keyPrefix+"/api/docs", "stale")
+	s.Trip(errors.New("redis hasn't answered"))
+
+	// A purge while Redis is unused reaches memory only
+	if err := s.memory.Set("/api/folders", "cached", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PurgeAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	if err := s.memory.Get("/api/folders", &got); !errors.Is(err, persist.ErrCacheMiss) {
+		t.Errorf("response is left in memory: %q, %v", got, err)
+	}
+	if !r.has(keyPrefix + "/api/docs") {
+		t.Fatalf("redis is purged while the breaker is open")
+	}
+
+	// The probe after the cooldown purges what the purge has missed
+	cooledDown(s.breaker)
+	if err := s.Get("/api/docs", &got); !errors.Is(err, persist.ErrCacheMiss) {
+		t.Errorf("Get after the probe = %q, %v; want ErrCacheMiss", got, err)
+	}
+	if r.has(keyPrefix + "/api/docs") {
+		t.Errorf("stale response is left in redis after the probe")
+	}
+	if err := s.Check(context.Background()); err != nil {
+		t.Errorf("Check = %v; want redis in use again", err)
+	}
+}
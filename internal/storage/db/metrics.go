@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/utils/metrics"
+	"github.com/Kapeland/task-Astral/internal/utils/tracing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const dbPackage = "/internal/storage/db."
+
+// startQuery starts a span of the query named after the repository method which runs it, e.g. "files.GetDoc".
+// The returned function ends the span and records the duration of the query
+func startQuery(ctx context.Context, query string) (context.Context, func(error)) {
+	start := time.Now()
+	repo, method := queryCaller()
+	ctx, span := tracing.Start(ctx, repo+"."+method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBQueryText(query)))
+
+	return ctx, func(err error) {
+		result := metrics.ResultOK
+		if err != nil && !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, pgx.ErrNoRows) {
+			result = metrics.ResultError
+		} else {
+			err = nil
+		}
+		metrics.DBQueryDuration.Observe(time.Since(start).Seconds(), repo, method, result)
+		tracing.End(span, err)
+	}
+}
+
+// queryCaller returns the package and the function of the first caller outside of this package,
+// e.g. "files" and "GetDoc" for files.(*Repo).GetDoc
+func queryCaller() (string, string) {
+	pcs := make([]uintptr, 8)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.Contains(frame.Function, dbPackage) {
+			return splitFuncName(frame.Function)
+		}
+		if !more {
+			return "unknown", "unknown"
+		}
+	}
+}
+
+func splitFuncName(name string) (string, string) {
+	name = name[strings.LastIndex(name, "/")+1:]
+	parts := strings.Split(name, ".")
+	if len(parts) < 2 {
+		return "unknown", name
+	}
+	for _, part := range parts[1:] {
+		// Receivers like (*Repo) and closures like func1 are skipped
+		if !strings.HasPrefix(part, "(") && !strings.HasPrefix(part, "func") {
+			return parts[0], part
+		}
+	}
+	return parts[0], "unknown"
+}
+
+// Tx is a transaction whose queries are measured and traced like the ones of PgDatabase
+type Tx struct {
+	*sqlx.Tx
+	ctx context.Context // Commit is traced within the context the transaction has begun in
+}
+
+func (tx *Tx) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, end := startQuery(ctx, query)
+	err := tx.Tx.GetContext(ctx, dest, query, args...)
+	end(err)
+	return err
+}
+
+func (tx *Tx) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, end := startQuery(ctx, query)
+	err := tx.Tx.SelectContext(ctx, dest, query, args...)
+	end(err)
+	return err
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, end := startQuery(ctx, query)
+	res, err := tx.Tx.ExecContext(ctx, query, args...)
+	end(err)
+	return res, err
+}
+
+func (tx *Tx) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	ctx, end := startQuery(ctx, query)
+	res, err := tx.Tx.NamedExecContext(ctx, query, arg)
+	end(err)
+	return res, err
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, end := startQuery(ctx, query)
+	row := tx.Tx.QueryRowContext(ctx, query, args...)
+	end(row.Err())
+	return row
+}
+
+func (tx *Tx) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	ctx, end := startQuery(ctx, query)
+	row := tx.Tx.QueryRowxContext(ctx, query, args...)
+	end(row.Err())
+	return row
+}
+
+func (tx *Tx) Commit() error {
+	_, end := startQuery(tx.ctx, "COMMIT")
+	err := tx.Tx.Commit()
+	end(err)
+	return err
+}
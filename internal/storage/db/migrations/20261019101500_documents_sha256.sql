@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE Documents
+    ADD COLUMN IF NOT EXISTS sha256 TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE Documents
+    DROP COLUMN IF EXISTS sha256;
+-- +goose StatementEnd
@@ -0,0 +1,154 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/Kapeland/task-Astral/internal/storage/file-storage/file"
+	"github.com/Kapeland/task-Astral/internal/storage/file-storage/file_provider"
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upRelocateBlobs, downRelocateBlobs)
+}
+
+type legacyDoc struct {
+	id     string
+	title  string
+	isFile bool
+}
+
+// Blobs used to be stored under client supplied names: "<title>" for files and "json/<title>" for json docs.
+// Now they are stored under document ids. Every upload overwrote the blob of an older doc with the same name,
+// so only the latest of such docs gets the blob.
+// Files can't be moved in the transaction, so blobs already moved by a failed run are skipped.
+func upRelocateBlobs(ctx context.Context, tx *sql.Tx) error {
+	lgr := logger.GetLogger()
+
+	docs, err := getLegacyDocs(ctx, tx, "DESC")
+	if err != nil {
+		return err
+	}
+	fr := file.NewRepository(file_provider.NewFileProvider(), config.GetConfig().Storage.Root)
+
+	seen := make(map[string]struct{}, len(docs))
+	for _, doc := range docs {
+		key, ok := legacyKey(doc)
+		if !ok {
+			lgr.Warn("blob name of doc "+doc.id+" escapes file-storage, skipped", "migrations", "upRelocateBlobs", "legacyKey")
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			lgr.Warn("blob of doc "+doc.id+" was overwritten by a newer doc", "migrations", "upRelocateBlobs", "")
+			continue
+		}
+		seen[key] = struct{}{}
+
+		moved, err := isMoved(fr, key, doc.id)
+		if err != nil {
+			return err
+		}
+		if moved {
+			continue
+		}
+		if err := fr.RenameFile(key, doc.id); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				lgr.Warn("blob of doc "+doc.id+" not found", "migrations", "upRelocateBlobs", "RenameFile")
+				continue
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func downRelocateBlobs(ctx context.Context, tx *sql.Tx) error {
+	lgr := logger.GetLogger()
+
+	docs, err := getLegacyDocs(ctx, tx, "ASC")
+	if err != nil {
+		return err
+	}
+	fr := file.NewRepository(file_provider.NewFileProvider(), config.GetConfig().Storage.Root)
+
+	for _, doc := range docs {
+		key, ok := legacyKey(doc)
+		if !ok {
+			continue
+		}
+		moved, err := isMoved(fr, doc.id, key)
+		if err != nil {
+			return err
+		}
+		if moved {
+			continue
+		}
+		if err := fr.RenameFile(doc.id, key); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				lgr.Warn("blob of doc "+doc.id+" not found", "migrations", "downRelocateBlobs", "RenameFile")
+				continue
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isMoved reports whether the blob has been moved from from to to already.
+// If both exist, to is kept and from is left for the scanner.
+func isMoved(fr *file.Repository, from string, to string) (bool, error) {
+	exists, err := fr.FileExists(to)
+	if err != nil || !exists {
+		return false, err
+	}
+	source, err := fr.FileExists(from)
+	if err != nil {
+		return false, err
+	}
+	if source {
+		logger.GetLogger().Warn("both "+from+" and "+to+" exist, "+from+" is left as is", "migrations", "isMoved", "FileExists")
+	}
+	return true, nil
+}
+
+func getLegacyDocs(ctx context.Context, tx *sql.Tx, order string) ([]legacyDoc, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, title, file FROM documents ORDER BY created_at `+order+`;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []legacyDoc
+	for rows.Next() {
+		doc := legacyDoc{}
+		if err := rows.Scan(&doc.id, &doc.title, &doc.isFile); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, rows.Err()
+}
+
+// legacyKey returns the old storage key of the doc. Keys escaping file-storage are reported as not ok.
+func legacyKey(doc legacyDoc) (string, bool) {
+	key := doc.title
+	if !doc.isFile {
+		key = path.Join("json", doc.title)
+	}
+	key = path.Clean(key)
+	if key == "." || key == ".." || strings.HasPrefix(key, "../") || path.IsAbs(key) {
+		return "", false
+	}
+	return key, true
+}
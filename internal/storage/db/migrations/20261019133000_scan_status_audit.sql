@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Documents uploaded before scanning was introduced are considered clean
+ALTER TABLE Documents
+    ADD COLUMN IF NOT EXISTS scan_status TEXT NOT NULL DEFAULT 'clean';
+ALTER TABLE Documents
+    ALTER COLUMN scan_status SET DEFAULT 'pending';
+
+CREATE INDEX IF NOT EXISTS idx_documents_scan_pending ON Documents (created_at) WHERE scan_status = 'pending';
+
+CREATE TABLE IF NOT EXISTS audit_events
+(
+    id         BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    actor      TEXT      NOT NULL,
+    action     TEXT      NOT NULL,
+    target     TEXT      NOT NULL DEFAULT '',
+    ip         TEXT      NOT NULL DEFAULT '',
+    user_agent TEXT      NOT NULL DEFAULT '',
+    result     TEXT      NOT NULL,
+    details    TEXT      NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_events;
+
+DROP INDEX IF EXISTS idx_documents_scan_pending;
+
+ALTER TABLE Documents
+    DROP COLUMN IF EXISTS scan_status;
+-- +goose StatementEnd
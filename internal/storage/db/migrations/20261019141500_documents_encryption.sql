@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Data key of the document wrapped by the master key key_id. NULL for documents stored as plaintext
+ALTER TABLE Documents
+    ADD COLUMN IF NOT EXISTS key_id      TEXT,
+    ADD COLUMN IF NOT EXISTS wrapped_key BYTEA;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE Documents
+    DROP COLUMN IF EXISTS key_id,
+    DROP COLUMN IF EXISTS wrapped_key;
+-- +goose StatementEnd
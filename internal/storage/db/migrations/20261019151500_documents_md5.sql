@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- MD5 of the uploaded content for legacy clients. It's computed only if enabled in the config
+ALTER TABLE Documents
+    ADD COLUMN IF NOT EXISTS md5 TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE Documents
+    DROP COLUMN IF EXISTS md5;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Resumable uploads in progress. The received part is kept in file-storage/.uploads/<id>
+CREATE TABLE IF NOT EXISTS uploads
+(
+    id            UUID PRIMARY KEY,
+    owner         TEXT      NOT NULL REFERENCES users_schema.users (login) ON DELETE CASCADE,
+    meta          TEXT      NOT NULL,
+    content       TEXT      NOT NULL DEFAULT '',
+    upload_length BIGINT    NOT NULL,
+    upload_offset BIGINT    NOT NULL DEFAULT 0,
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at    TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_uploads_expires_at ON uploads (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS uploads;
+-- +goose StatementEnd
@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS folders
+(
+    id         UUID PRIMARY KEY,
+    owner      TEXT      NOT NULL REFERENCES users_schema.users (login) ON DELETE CASCADE,
+    parent_id  UUID REFERENCES folders (id) ON DELETE CASCADE,
+    name       TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Names are unique among siblings. Root folders have no parent, so NULL is replaced by the zero uuid
+CREATE UNIQUE INDEX IF NOT EXISTS idx_folders_owner_parent_name
+    ON folders (owner, COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'), name);
+CREATE INDEX IF NOT EXISTS idx_folders_parent ON folders (parent_id);
+
+-- Grants of a folder apply to everything inside it, subfolders included
+CREATE TABLE IF NOT EXISTS folder_access
+(
+    folder_id UUID NOT NULL REFERENCES folders (id) ON DELETE CASCADE,
+    login     TEXT NOT NULL REFERENCES users_schema.users (login) ON DELETE CASCADE,
+    PRIMARY KEY (folder_id, login)
+);
+
+-- Documents without a folder are in the root
+ALTER TABLE Documents
+    ADD COLUMN IF NOT EXISTS folder_id UUID REFERENCES folders (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_documents_folder ON Documents (folder_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_documents_folder;
+ALTER TABLE Documents
+    DROP COLUMN IF EXISTS folder_id;
+
+DROP TABLE IF EXISTS folder_access;
+DROP TABLE IF EXISTS folders;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE Documents
+    ADD COLUMN IF NOT EXISTS tags     TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS metadata JSONB  NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_documents_tags ON Documents USING GIN (tags);
+CREATE INDEX IF NOT EXISTS idx_documents_metadata ON Documents USING GIN (metadata jsonb_path_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_documents_metadata;
+DROP INDEX IF EXISTS idx_documents_tags;
+
+ALTER TABLE Documents
+    DROP COLUMN IF EXISTS tags,
+    DROP COLUMN IF EXISTS metadata;
+-- +goose StatementEnd
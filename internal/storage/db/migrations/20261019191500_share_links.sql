@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Links giving access to a document without an account. Passwords are hashed with pgcrypto like user ones
+CREATE TABLE IF NOT EXISTS share_links
+(
+    slug          TEXT PRIMARY KEY,
+    document_id   UUID      NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    owner         TEXT      NOT NULL REFERENCES users_schema.users (login) ON DELETE CASCADE,
+    password_hash TEXT,
+    expires_at    TIMESTAMP,
+    max_downloads INT,
+    downloads     INT       NOT NULL DEFAULT 0,
+    created_at    TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_share_links_document_id ON share_links (document_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS share_links;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Audit events are paged by id, the newest first
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target_id ON audit_events (target, id);
+DROP INDEX IF EXISTS idx_audit_events_target;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target);
+DROP INDEX IF EXISTS idx_audit_events_target_id;
+DROP INDEX IF EXISTS idx_audit_events_actor_id;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Endpoints of users notified about their documents. Empty events mean all of them
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id         UUID PRIMARY KEY,
+    owner      TEXT      NOT NULL REFERENCES users_schema.users (login) ON DELETE CASCADE,
+    url        TEXT      NOT NULL,
+    secret     TEXT      NOT NULL,
+    events     TEXT[]    NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_owner ON webhooks (owner);
+
+-- Every event is delivered to every matching webhook separately. Deliveries out of attempts are 'dead'
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              BIGSERIAL PRIMARY KEY,
+    webhook_id      UUID      NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id        TEXT      NOT NULL,
+    event           TEXT      NOT NULL,
+    payload         TEXT      NOT NULL,
+    status          TEXT      NOT NULL DEFAULT 'pending',
+    attempts        INT       NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    response_code   INT       NOT NULL DEFAULT 0,
+    last_error      TEXT      NOT NULL DEFAULT '',
+    created_at      TIMESTAMP NOT NULL,
+    delivered_at    TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Set while the upload is being turned into a document, so that it's done once
+ALTER TABLE uploads
+    ADD COLUMN IF NOT EXISTS finishing_at TIMESTAMP NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE uploads
+    DROP COLUMN IF EXISTS finishing_at;
+-- +goose StatementEnd
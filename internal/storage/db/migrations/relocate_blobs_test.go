@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/storage/file-storage/file"
+	"github.com/Kapeland/task-Astral/internal/storage/file-storage/file_provider"
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+)
+
+func TestIsMoved(t *testing.T) {
+	logger.CreateLogger(&config.Config{Logger: config.Logger{Lvl: "error", LogRate: 1}})
+	root := t.TempDir()
+	for _, name := range []string{"not-moved.txt", "moved-id", "both.txt", "both-id"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fr := file.NewRepository(file_provider.NewFileProvider(), root)
+
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"not-moved.txt", "not-moved-id", false},
+		{"moved.txt", "moved-id", true},
+		{"both.txt", "both-id", true},
+		{"missing.txt", "missing-id", false},
+	}
+	for _, tt := range tests {
+		got, err := isMoved(fr, tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("isMoved(%s, %s): %v", tt.from, tt.to, err)
+		}
+		if got != tt.want {
+			t.Errorf("isMoved(%s, %s) = %v; want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "both.txt")); err != nil {
+		t.Errorf("source of a conflicting move must be kept: %v", err)
+	}
+}
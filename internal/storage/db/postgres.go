@@ -44,39 +44,65 @@ type PgDatabase struct {
 	db *sqlx.DB
 }
 
+// NewPgDatabase wraps an opened database, e.g. one of a fake driver in tests
+func NewPgDatabase(db *sqlx.DB) *PgDatabase {
+	return &PgDatabase{db}
+}
+
 // Get helper
 func (db PgDatabase) Get(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
-	return db.db.GetContext(ctx, dest, query, args...)
+	ctx, end := startQuery(ctx, query)
+	err := db.db.GetContext(ctx, dest, query, args...)
+	end(err)
+	return err
 }
 
 // Select helper
 func (db PgDatabase) Select(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
-	return db.db.SelectContext(ctx, dest, query, args...)
+	ctx, end := startQuery(ctx, query)
+	err := db.db.SelectContext(ctx, dest, query, args...)
+	end(err)
+	return err
 }
 
 // Exec helper
 func (db PgDatabase) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	return db.db.ExecContext(ctx, query, args...)
+	ctx, end := startQuery(ctx, query)
+	res, err := db.db.ExecContext(ctx, query, args...)
+	end(err)
+	return res, err
 }
 
 // NamedExec helper
 func (db PgDatabase) NamedExec(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
-	return db.db.NamedExecContext(ctx, query, arg)
+	ctx, end := startQuery(ctx, query)
+	res, err := db.db.NamedExecContext(ctx, query, arg)
+	end(err)
+	return res, err
 }
 
 // QueryRow helper
 func (db PgDatabase) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	return db.db.QueryRowContext(ctx, query, args...)
+	ctx, end := startQuery(ctx, query)
+	row := db.db.QueryRowContext(ctx, query, args...)
+	end(row.Err())
+	return row
 }
 
 // QueryRowx helper
 func (db PgDatabase) QueryRowx(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
-	return db.db.QueryRowxContext(ctx, query, args...)
+	ctx, end := startQuery(ctx, query)
+	row := db.db.QueryRowxContext(ctx, query, args...)
+	end(row.Err())
+	return row
 }
 
 // NamedQuery helper
 func (db PgDatabase) NamedQuery(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
-	return db.db.NamedQueryContext(ctx, query, arg)
+	ctx, end := startQuery(ctx, query)
+	rows, err := db.db.NamedQueryContext(ctx, query, arg)
+	end(err)
+	return rows, err
 }
 
 // Close closes db
@@ -94,7 +120,11 @@ func (db PgDatabase) Begin(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, e
 	return db.db.BeginTx(ctx, opts)
 }
 
-// BeginX begins transaction
-func (db PgDatabase) BeginX(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
-	return db.db.BeginTxx(ctx, opts)
+// BeginX begins transaction. Its queries are measured and traced too
+func (db PgDatabase) BeginX(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.db.BeginTxx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx, ctx}, nil
 }
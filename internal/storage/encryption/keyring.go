@@ -0,0 +1,111 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+)
+
+const keySize = 32
+
+var ErrUnknownKey = errors.New("unknown master key")
+
+// Keyring keeps master keys. Data keys of documents are wrapped by the current master key,
+// older keys are kept only to unwrap data keys until they are rotated.
+type Keyring struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewKeyringFromConfig loads master keys. Every key is either base64 encoded in the config or kept in a file.
+func NewKeyringFromConfig(cfg config.Encryption) (*Keyring, error) {
+	kr := &Keyring{current: cfg.CurrentKey, keys: make(map[string][]byte, len(cfg.Keys))}
+
+	for _, k := range cfg.Keys {
+		encoded := k.Key
+		if k.KeyFile != "" {
+			data, err := os.ReadFile(k.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+			encoded = string(data)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("master key %s: %w", k.ID, err)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("master key %s: must be %d bytes long", k.ID, keySize)
+		}
+		kr.keys[k.ID] = key
+	}
+
+	if _, ok := kr.keys[kr.current]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, kr.current)
+	}
+	return kr, nil
+}
+
+func (kr *Keyring) CurrentKeyID() string {
+	return kr.current
+}
+
+// NewDataKey generates a data key and wraps it by the current master key.
+// The wrapped key is bound to docID, so it can't be moved to another document.
+func (kr *Keyring) NewDataKey(docID string) ([]byte, string, []byte, error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, "", nil, err
+	}
+	wrapped, err := kr.wrap(kr.current, dek, docID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return dek, kr.current, wrapped, nil
+}
+
+// UnwrapKey returns the data key wrapped by the master key keyID
+func (kr *Keyring) UnwrapKey(keyID string, wrapped []byte, docID string) ([]byte, error) {
+	key, ok := kr.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrMalformedBlob
+	}
+	return aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(docID))
+}
+
+// RewrapKey wraps the data key by the current master key
+func (kr *Keyring) RewrapKey(keyID string, wrapped []byte, docID string) (string, []byte, error) {
+	dek, err := kr.UnwrapKey(keyID, wrapped, docID)
+	if err != nil {
+		return "", nil, err
+	}
+	rewrapped, err := kr.wrap(kr.current, dek, docID)
+	if err != nil {
+		return "", nil, err
+	}
+	return kr.current, rewrapped, nil
+}
+
+func (kr *Keyring) wrap(keyID string, dek []byte, docID string) ([]byte, error) {
+	aead, err := newAEAD(kr.keys[keyID])
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dek, []byte(docID)), nil
+}
@@ -0,0 +1,227 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Blobs are encrypted in segments, so that any range of plaintext can be read without decrypting the whole blob.
+// Layout: magic | nonce prefix | segment 0 | segment 1 | ... Every segment is sealed with AES-256-GCM.
+// The nonce of a segment is its prefix, index and a flag of the last segment, so segments can't be
+// reordered, dropped or appended.
+const (
+	magic       = "ASTRENC1"
+	prefixSize  = 7
+	headerSize  = len(magic) + prefixSize
+	segmentSize = 64 * 1024
+	tagSize     = 16
+)
+
+var ErrMalformedBlob = errors.New("malformed encrypted blob")
+
+func newAEAD(dek []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func segmentNonce(prefix []byte, idx uint32, last bool) []byte {
+	nonce := make([]byte, 0, 12)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, idx)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+type encryptingReader struct {
+	src    io.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	plain  []byte // One byte more than a segment to find out whether the segment is the last one
+	carry  int
+	out    []byte
+	idx    uint32
+	done   bool
+}
+
+// NewEncryptingReader returns a reader of the encrypted src
+func NewEncryptingReader(src io.Reader, dek []byte) (io.Reader, error) {
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, prefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+
+	r := &encryptingReader{
+		src:    src,
+		aead:   aead,
+		prefix: prefix,
+		plain:  make([]byte, segmentSize+1),
+	}
+	r.out = append(append(r.out, magic...), prefix...)
+	return r, nil
+}
+
+func (r *encryptingReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.seal(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *encryptingReader) seal() error {
+	n, err := io.ReadFull(r.src, r.plain[r.carry:])
+	n += r.carry
+	last := false
+	switch {
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		last = true
+	case err != nil:
+		return err
+	}
+
+	size := n
+	if !last {
+		size = segmentSize
+	}
+	r.out = r.aead.Seal(r.out[:0], segmentNonce(r.prefix, r.idx, last), r.plain[:size], nil)
+
+	if !last {
+		r.plain[0] = r.plain[segmentSize]
+		r.carry = 1
+	}
+	r.idx++
+	r.done = last
+	return nil
+}
+
+type decryptingReader struct {
+	src      io.ReadSeekCloser
+	aead     cipher.AEAD
+	prefix   []byte
+	size     int64 // Plaintext size
+	segments int64
+	off      int64
+	idx      int64 // Index of the segment in buf, -1 if none
+	buf      []byte
+	ct       []byte
+}
+
+// NewDecryptingReader returns a seekable reader of the plaintext of the encrypted src. Closing it closes src.
+func NewDecryptingReader(src io.ReadSeekCloser, dek []byte) (io.ReadSeekCloser, error) {
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	body := total - int64(headerSize)
+	if body < tagSize {
+		return nil, ErrMalformedBlob
+	}
+	// Only the single segment of an empty plaintext is empty. Any other tail no longer than a tag is
+	// a blob truncated at a segment boundary and padded, which would end the plaintext before the final segment
+	if rem := body % (segmentSize + tagSize); body != tagSize && rem != 0 && rem <= tagSize {
+		return nil, ErrMalformedBlob
+	}
+	segments := (body + segmentSize + tagSize - 1) / (segmentSize + tagSize)
+
+	header := make([]byte, headerSize)
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, err
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, ErrMalformedBlob
+	}
+
+	return &decryptingReader{
+		src:      src,
+		aead:     aead,
+		prefix:   header[len(magic):],
+		size:     body - segments*tagSize,
+		segments: segments,
+		idx:      -1,
+		ct:       make([]byte, segmentSize+tagSize),
+	}, nil
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	if r.off >= r.size {
+		return 0, io.EOF
+	}
+	idx := r.off / segmentSize
+	if idx != r.idx {
+		if err := r.open(idx); err != nil {
+			return 0, err
+		}
+	}
+	start := r.off - idx*segmentSize
+	n := copy(p, r.buf[start:min(int64(len(r.buf)), start+r.size-r.off)])
+	r.off += int64(n)
+	return n, nil
+}
+
+func (r *decryptingReader) open(idx int64) error {
+	if _, err := r.src.Seek(int64(headerSize)+idx*(segmentSize+tagSize), io.SeekStart); err != nil {
+		return err
+	}
+	n, err := io.ReadFull(r.src, r.ct)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+
+	last := idx == r.segments-1
+	r.buf, err = r.aead.Open(r.buf[:0], segmentNonce(r.prefix, uint32(idx), last), r.ct[:n], nil)
+	if err != nil {
+		r.idx = -1
+		return fmt.Errorf("%w: segment %d: %v", ErrMalformedBlob, idx, err)
+	}
+	r.idx = idx
+	return nil
+}
+
+func (r *decryptingReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.off = offset
+	return offset, nil
+}
+
+func (r *decryptingReader) Close() error {
+	return r.src.Close()
+}
@@ -0,0 +1,177 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func newDEK(t *testing.T) []byte {
+	t.Helper()
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		t.Fatal(err)
+	}
+	return dek
+}
+
+func encrypt(t *testing.T, plain []byte, dek []byte) []byte {
+	t.Helper()
+	r, err := NewEncryptingReader(bytes.NewReader(plain), dek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blob
+}
+
+func decrypt(blob []byte, dek []byte) ([]byte, error) {
+	r, err := NewDecryptingReader(nopCloser{bytes.NewReader(blob)}, dek)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	dek := newDEK(t)
+	for _, size := range []int{0, 1, segmentSize - 1, segmentSize, segmentSize + 1, 2 * segmentSize, 3*segmentSize + 17} {
+		plain := randomBytes(t, size)
+		blob := encrypt(t, plain, dek)
+
+		segments := size/segmentSize + 1
+		if size != 0 && size%segmentSize == 0 {
+			segments--
+		}
+		if want := headerSize + size + segments*tagSize; len(blob) != want {
+			t.Errorf("size %d: blob is %d bytes; want %d", size, len(blob), want)
+		}
+
+		got, err := decrypt(blob, dek)
+		if err != nil {
+			t.Fatalf("size %d: decrypt: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: decrypted content differs", size)
+		}
+	}
+}
+
+func TestStreamSeek(t *testing.T) {
+	dek := newDEK(t)
+	plain := randomBytes(t, 2*segmentSize+100)
+	blob := encrypt(t, plain, dek)
+
+	r, err := NewDecryptingReader(nopCloser{bytes.NewReader(blob)}, dek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if end, err := r.Seek(0, io.SeekEnd); err != nil || end != int64(len(plain)) {
+		t.Fatalf("Seek(0, SeekEnd) = %d, %v; want %d", end, err, len(plain))
+	}
+
+	// Back and forth across segment boundaries, so that cached segments are reused and replaced
+	for _, off := range []int64{segmentSize, 0, segmentSize - 1, segmentSize + 1, 2 * segmentSize, int64(len(plain)) - 1, int64(len(plain)), 1} {
+		if pos, err := r.Seek(off, io.SeekStart); err != nil || pos != off {
+			t.Fatalf("Seek(%d) = %d, %v", off, pos, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("read from %d: %v", off, err)
+		}
+		if !bytes.Equal(got, plain[off:]) {
+			t.Errorf("read from %d: got %d bytes, content differs from plaintext", off, len(got))
+		}
+	}
+
+	if _, err := r.Seek(segmentSize, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if pos, err := r.Seek(-2, io.SeekCurrent); err != nil || pos != segmentSize-2 {
+		t.Fatalf("Seek(-2, SeekCurrent) = %d, %v; want %d", pos, err, segmentSize-2)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(r, buf); err != nil || !bytes.Equal(buf, plain[segmentSize-2:segmentSize+2]) {
+		t.Errorf("read across the boundary = %x, %v; want %x", buf, err, plain[segmentSize-2:segmentSize+2])
+	}
+
+	if _, err := r.Seek(-1, io.SeekStart); err == nil {
+		t.Errorf("Seek to a negative position succeeded")
+	}
+}
+
+func TestStreamTampering(t *testing.T) {
+	dek := newDEK(t)
+	plain := randomBytes(t, 3*segmentSize)
+	blob := encrypt(t, plain, dek)
+	segment := func(i int) []byte {
+		start := headerSize + i*(segmentSize+tagSize)
+		return blob[start : start+segmentSize+tagSize]
+	}
+	concat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	header := blob[:headerSize]
+
+	flipped := bytes.Clone(blob)
+	flipped[headerSize+10] ^= 1
+
+	tests := []struct {
+		name string
+		blob []byte
+	}{
+		{"last segment dropped", concat(header, segment(0), segment(1))},
+		{"truncated in the middle of a segment", blob[:len(blob)-100]},
+		{"truncated at a segment and padded by a byte", concat(header, segment(0), segment(1), []byte{0})},
+		{"truncated at a segment and padded by a tag", concat(header, segment(0), segment(1), make([]byte, tagSize))},
+		{"segments reordered", concat(header, segment(1), segment(0), segment(2))},
+		{"segment duplicated", concat(header, segment(0), segment(0), segment(2))},
+		{"segment appended", concat(blob, segment(2))},
+		{"bit flipped", flipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decrypt(tt.blob, dek)
+			if !errors.Is(err, ErrMalformedBlob) {
+				t.Fatalf("decrypt error = %v; want ErrMalformedBlob", err)
+			}
+		})
+	}
+
+	t.Run("wrong key", func(t *testing.T) {
+		if _, err := decrypt(blob, newDEK(t)); !errors.Is(err, ErrMalformedBlob) {
+			t.Fatalf("decrypt error = %v; want ErrMalformedBlob", err)
+		}
+	})
+	t.Run("bad magic", func(t *testing.T) {
+		bad := bytes.Clone(blob)
+		bad[0] = 'X'
+		if _, err := decrypt(bad, dek); !errors.Is(err, ErrMalformedBlob) {
+			t.Fatalf("decrypt error = %v; want ErrMalformedBlob", err)
+		}
+	})
+}
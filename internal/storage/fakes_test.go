@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/config"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.CreateLogger(&config.Config{Logger: config.Logger{Lvl: "error", LogRate: 1}})
+	os.Exit(m.Run())
+}
+
+// fakeRepo keeps document rows in memory. Methods not used by tests panic.
+type fakeRepo struct {
+	FileRepo
+	docs []structs.DocBlob
+}
+
+func (r *fakeRepo) GetAllDocBlobs(ctx context.Context) ([]structs.DocBlob, error) {
+	return r.docs, nil
+}
+
+// GetDocKey returns no key: fake docs are stored unencrypted
+func (r *fakeRepo) GetDocKey(ctx context.Context, docID string) (*structs.DocKey, error) {
+	return &structs.DocKey{ID: docID}, nil
+}
+
+func (r *fakeRepo) IsDocStored(ctx context.Context, docID string) (bool, error) {
+	for _, doc := range r.docs {
+		if doc.ID == docID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fakeProvider keeps blobs in memory. Methods not used by tests panic.
+type fakeProvider struct {
+	FileProvider
+	blobs       map[string][]byte
+	staged      map[string]structs.StagedFile
+	stagedData  map[string][]byte
+	quarantined map[string][]byte
+}
+
+func newFakeProvider(blobs map[string][]byte) *fakeProvider {
+	return &fakeProvider{
+		blobs:       blobs,
+		staged:      make(map[string]structs.StagedFile),
+		stagedData:  make(map[string][]byte),
+		quarantined: make(map[string][]byte),
+	}
+}
+
+func (p *fakeProvider) stage(op string, key string, data []byte) {
+	name := op + "-0000-" + key
+	p.staged[name] = structs.StagedFile{Name: name, Op: op, Key: key}
+	p.stagedData[name] = data
+}
+
+func (p *fakeProvider) GetAllKeys() ([]string, error) {
+	keys := make([]string, 0, len(p.blobs))
+	for key := range p.blobs {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (p *fakeProvider) OpenFile(key string) (io.ReadSeekCloser, error) {
+	data, ok := p.blobs[key]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return nopCloser{bytes.NewReader(data)}, nil
+}
+
+func (p *fakeProvider) FileExists(key string) (bool, error) {
+	_, ok := p.blobs[key]
+	return ok, nil
+}
+
+func (p *fakeProvider) QuarantineFile(key string) error {
+	data, ok := p.blobs[key]
+	if !ok {
+		return fs.ErrNotExist
+	}
+	delete(p.blobs, key)
+	p.quarantined[key] = data
+	return nil
+}
+
+func (p *fakeProvider) RemoveFile(key string) error {
+	if _, ok := p.blobs[key]; !ok {
+		return fs.ErrNotExist
+	}
+	delete(p.blobs, key)
+	return nil
+}
+
+func (p *fakeProvider) GetStagedFiles() ([]structs.StagedFile, error) {
+	staged := make([]structs.StagedFile, 0, len(p.staged))
+	for _, sf := range p.staged {
+		staged = append(staged, sf)
+	}
+	return staged, nil
+}
+
+func (p *fakeProvider) PromoteFile(staged string, key string) error {
+	p.blobs[key] = p.stagedData[staged]
+	return p.DiscardFile(staged)
+}
+
+func (p *fakeProvider) DiscardFile(staged string) error {
+	delete(p.staged, staged)
+	delete(p.stagedData, staged)
+	return nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
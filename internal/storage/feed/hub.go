@@ -0,0 +1,122 @@
+package feed
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+
+	"github.com/go-redis/redis/v8"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// subscriberBuffer is the number of events a client may lag behind before it's disconnected
+const subscriberBuffer = 64
+
+type subscriber struct {
+	login  string
+	events chan structs.DocEvent
+}
+
+// Hub listens to the Redis channel of events and passes them on to subscribers of this instance
+type Hub struct {
+	client  *redis.Client
+	channel string
+
+	mu      sync.Mutex
+	subs    map[*subscriber]struct{}
+	stopped bool
+}
+
+func NewHub(client *redis.Client, channel string) *Hub {
+	return &Hub{client: client, channel: channel, subs: make(map[*subscriber]struct{})}
+}
+
+// Run receives events until ctx is done. The connection to Redis is restored by the client if it breaks,
+// events published meanwhile are missed
+func (h *Hub) Run(ctx context.Context) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	pubsub := h.client.Subscribe(ctx, h.channel)
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			h.closeAll()
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				h.closeAll()
+				return
+			}
+			event := structs.DocEvent{}
+			if err := jsoniter.UnmarshalFromString(msg.Payload, &event); err != nil {
+				lgr.Error(err.Error(), "Hub", "Run", "Unmarshal")
+				continue
+			}
+			h.dispatch(event)
+		}
+	}
+}
+
+// Subscribe returns events relevant to login: of its documents and of documents shared with it.
+// The channel is closed if the subscriber lags too much or the hub stops. unsubscribe must be called when done
+func (h *Hub) Subscribe(login string) (<-chan structs.DocEvent, func()) {
+	sub := &subscriber{login: login, events: make(chan structs.DocEvent, subscriberBuffer)}
+
+	h.mu.Lock()
+	if h.stopped { // Shutting down, so the stream ends at once
+		close(sub.events)
+	} else {
+		h.subs[sub] = struct{}{}
+	}
+	h.mu.Unlock()
+
+	return sub.events, func() { h.remove(sub) }
+}
+
+func (h *Hub) dispatch(event structs.DocEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		forSub := event
+		if sub.login != event.Owner {
+			if !slices.Contains(event.Logins, sub.login) {
+				continue
+			}
+			forSub.Logins = nil // Who else has access is the owner's business
+		}
+		select {
+		case sub.events <- forSub:
+		default: // The client can't keep up, it has to reconnect and list documents again
+			delete(h.subs, sub)
+			close(sub.events)
+		}
+	}
+}
+
+func (h *Hub) remove(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
+
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stopped = true
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
@@ -0,0 +1,109 @@
+package feed
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+)
+
+// drain returns events waiting in the channel and whether it's closed
+func drain(events <-chan structs.DocEvent) ([]structs.DocEvent, bool) {
+	var got []structs.DocEvent
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return got, true
+			}
+			got = append(got, event)
+		default:
+			return got, false
+		}
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	event := structs.DocEvent{ID: "e1", Type: structs.EventDocShared, DocID: "d1", Owner: "alice", Logins: []string{"bob"}}
+	tests := []struct {
+		name       string
+		login      string
+		wantEvent  bool
+		wantLogins []string
+	}{
+		{"owner gets grantees", "alice", true, []string{"bob"}},
+		{"grantee doesn't get grantees", "bob", true, nil},
+		{"stranger gets nothing", "carol", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHub(nil, "events")
+			events, unsubscribe := h.Subscribe(tt.login)
+			defer unsubscribe()
+
+			h.dispatch(event)
+
+			got, closed := drain(events)
+			if closed {
+				t.Fatalf("events are closed")
+			}
+			if !tt.wantEvent {
+				if len(got) != 0 {
+					t.Fatalf("got %+v; want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].ID != event.ID {
+				t.Fatalf("got %+v; want the event", got)
+			}
+			if !slices.Equal(got[0].Logins, tt.wantLogins) {
+				t.Errorf("logins = %v; want %v", got[0].Logins, tt.wantLogins)
+			}
+		})
+	}
+
+	if len(event.Logins) != 1 {
+		t.Errorf("logins of the event itself are changed to %v", event.Logins)
+	}
+}
+
+func TestDispatchLaggingSubscriber(t *testing.T) {
+	h := NewHub(nil, "events")
+	lagging, unsubscribeLagging := h.Subscribe("alice")
+	defer unsubscribeLagging()
+	other, unsubscribeOther := h.Subscribe("bob")
+	defer unsubscribeOther()
+
+	for range subscriberBuffer + 1 {
+		h.dispatch(structs.DocEvent{Owner: "alice"})
+	}
+
+	got, closed := drain(lagging)
+	if len(got) != subscriberBuffer || !closed {
+		t.Fatalf("lagging subscriber got %d events, closed %v; want %d and closed", len(got), closed, subscriberBuffer)
+	}
+	if _, closed := drain(other); closed {
+		t.Errorf("other subscriber is closed too")
+	}
+
+	// The hub has forgotten the lagging one, so more events and unsubscribing don't close it twice
+	h.dispatch(structs.DocEvent{Owner: "alice"})
+	unsubscribeLagging()
+}
+
+func TestSubscribeAfterStop(t *testing.T) {
+	h := NewHub(nil, "events")
+	events, unsubscribe := h.Subscribe("alice")
+	defer unsubscribe()
+
+	h.closeAll()
+	if _, closed := drain(events); !closed {
+		t.Fatalf("events aren't closed when the hub stops")
+	}
+
+	late, unsubscribeLate := h.Subscribe("alice")
+	defer unsubscribeLate()
+	if _, closed := drain(late); !closed {
+		t.Errorf("events of a subscriber after the stop aren't closed")
+	}
+}
@@ -1,27 +1,200 @@
 package file
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/url"
 	"path"
+	"strings"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
 )
 
-const filePath = "file-storage"
+// DefaultRoot is the directory of blobs used when none is configured
+const DefaultRoot = "file-storage"
+
+// stagingDir keeps blobs of not yet finished operations. It lives inside the root,
+// so that moving a blob in or out of it is an atomic rename.
+const stagingDir = ".staging"
+
+// quarantineDir keeps orphaned blobs moved aside by the scanner
+const quarantineDir = ".quarantine"
+
+// uploadsDir keeps parts of resumable uploads in progress
+const uploadsDir = ".uploads"
+
+// healthDir keeps files written and removed by readiness probes
+const healthDir = ".health"
+
+// jsonPath kept blobs of json documents before blobs were keyed by document id
+const jsonPath = "json"
+
+// Staged file names look like "<op>-<nonce>-<escaped key>".
+// The key is kept in the name so that an interrupted operation can be finished or rolled back later.
+const (
+	StagedPut = "put"
+	StagedDel = "del"
+)
 
 type FileProvider interface {
 	GetFile(path string) ([]byte, error)
+	GetAllFileNames(path string) ([]string, error)
+	OpenFile(path string) (io.ReadSeekCloser, error)
+	SaveFile(path string, src io.Reader) error
+	AppendFile(path string, offset int64, src io.Reader) (int64, error)
+	MoveFile(from, to string) error
+	RemoveFile(path string) error
+	FileExists(path string) (bool, error)
 }
 
 type Repository struct {
-	f FileProvider
+	f    FileProvider
+	root string
 }
 
-func NewRepository(f FileProvider) *Repository {
-	return &Repository{f: f}
+// NewRepository creates the repository of blobs stored in root, DefaultRoot if it's empty.
+// Failed operations of f are counted in metrics
+func NewRepository(f FileProvider, root string) *Repository {
+	if root == "" {
+		root = DefaultRoot
+	}
+	return &Repository{f: instrumentedProvider{f: f}, root: root}
 }
 
 func (r *Repository) GetFileByte(file string) ([]byte, error) {
-	bytes, err := r.f.GetFile(path.Join(filePath, file))
+	bytes, err := r.f.GetFile(path.Join(r.root, file))
 	if err != nil {
 		return nil, err
 	}
 	return bytes, nil
 }
+
+// OpenFile opens the blob stored under key for reading
+func (r *Repository) OpenFile(key string) (io.ReadSeekCloser, error) {
+	return r.f.OpenFile(path.Join(r.root, key))
+}
+
+// RenameFile moves the blob stored under from to to
+func (r *Repository) RenameFile(from string, to string) error {
+	return r.f.MoveFile(path.Join(r.root, from), path.Join(r.root, to))
+}
+
+// StageFile writes src to the staging area. The blob becomes visible under key only after PromoteFile.
+func (r *Repository) StageFile(key string, src io.Reader) (string, error) {
+	name, err := stagedName(StagedPut, key)
+	if err != nil {
+		return "", err
+	}
+	if err := r.f.SaveFile(path.Join(r.root, stagingDir, name), src); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// StageRemoval moves the blob stored under key to the staging area.
+// It can be brought back with PromoteFile or dropped with DiscardFile.
+func (r *Repository) StageRemoval(key string) (string, error) {
+	name, err := stagedName(StagedDel, key)
+	if err != nil {
+		return "", err
+	}
+	if err := r.f.MoveFile(path.Join(r.root, key), path.Join(r.root, stagingDir, name)); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// PromoteFile moves a staged blob to its place under key.
+func (r *Repository) PromoteFile(staged string, key string) error {
+	return r.f.MoveFile(path.Join(r.root, stagingDir, staged), path.Join(r.root, key))
+}
+
+func (r *Repository) DiscardFile(staged string) error {
+	return r.f.RemoveFile(path.Join(r.root, stagingDir, staged))
+}
+
+// Ping writes a small file next to the blobs and removes it. Concurrent probes use different files
+func (r *Repository) Ping() error {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	probe := path.Join(r.root, healthDir, hex.EncodeToString(nonce))
+	if err := r.f.SaveFile(probe, strings.NewReader("ok")); err != nil {
+		return err
+	}
+	return r.f.RemoveFile(probe)
+}
+
+func (r *Repository) FileExists(key string) (bool, error) {
+	return r.f.FileExists(path.Join(r.root, key))
+}
+
+// GetStagedFiles returns all blobs left in the staging area. Files with unknown names are skipped.
+func (r *Repository) GetStagedFiles() ([]structs.StagedFile, error) {
+	names, err := r.f.GetAllFileNames(path.Join(r.root, stagingDir))
+	if err != nil {
+		return nil, err
+	}
+	staged := make([]structs.StagedFile, 0, len(names))
+	for _, name := range names {
+		parts := strings.SplitN(name, "-", 3)
+		if len(parts) != 3 || (parts[0] != StagedPut && parts[0] != StagedDel) {
+			continue
+		}
+		key, err := url.PathUnescape(parts[2])
+		if err != nil {
+			continue
+		}
+		staged = append(staged, structs.StagedFile{Name: name, Op: parts[0], Key: key})
+	}
+	return staged, nil
+}
+
+func stagedName(op string, key string) (string, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return op + "-" + hex.EncodeToString(nonce) + "-" + url.PathEscape(key), nil
+}
+
+// GetAllKeys returns keys of all stored blobs. Staged and quarantined blobs are not included.
+func (r *Repository) GetAllKeys() ([]string, error) {
+	names, err := r.f.GetAllFileNames(r.root)
+	if err != nil {
+		return nil, err
+	}
+	jsonNames, err := r.f.GetAllFileNames(path.Join(r.root, jsonPath))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range jsonNames {
+		names = append(names, path.Join(jsonPath, name))
+	}
+	return names, nil
+}
+
+// QuarantineFile moves the blob stored under key out of sight. It isn't removed, so it can be inspected manually.
+func (r *Repository) QuarantineFile(key string) error {
+	return r.f.MoveFile(path.Join(r.root, key), path.Join(r.root, quarantineDir, url.PathEscape(key)))
+}
+
+func (r *Repository) RemoveFile(key string) error {
+	return r.f.RemoveFile(path.Join(r.root, key))
+}
+
+// AppendPart writes src to the part of the upload starting at offset
+func (r *Repository) AppendPart(uploadID string, offset int64, src io.Reader) (int64, error) {
+	return r.f.AppendFile(path.Join(r.root, uploadsDir, url.PathEscape(uploadID)), offset, src)
+}
+
+// OpenPart opens the part of the upload for reading
+func (r *Repository) OpenPart(uploadID string) (io.ReadSeekCloser, error) {
+	return r.f.OpenFile(path.Join(r.root, uploadsDir, url.PathEscape(uploadID)))
+}
+
+func (r *Repository) RemovePart(uploadID string) error {
+	return r.f.RemoveFile(path.Join(r.root, uploadsDir, url.PathEscape(uploadID)))
+}
@@ -0,0 +1,71 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+
+	"github.com/Kapeland/task-Astral/internal/utils/metrics"
+)
+
+// backendName labels errors of the local file system backend
+const backendName = "file"
+
+// instrumentedProvider counts failed operations of the provider. Missing files are an answer, not a failure
+type instrumentedProvider struct {
+	f FileProvider
+}
+
+func observe(op string, err error) {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		metrics.StorageErrors.Inc(backendName, op)
+	}
+}
+
+func (p instrumentedProvider) GetFile(path string) ([]byte, error) {
+	data, err := p.f.GetFile(path)
+	observe("get", err)
+	return data, err
+}
+
+func (p instrumentedProvider) GetAllFileNames(path string) ([]string, error) {
+	names, err := p.f.GetAllFileNames(path)
+	observe("list", err)
+	return names, err
+}
+
+func (p instrumentedProvider) OpenFile(path string) (io.ReadSeekCloser, error) {
+	file, err := p.f.OpenFile(path)
+	observe("open", err)
+	return file, err
+}
+
+func (p instrumentedProvider) SaveFile(path string, src io.Reader) error {
+	err := p.f.SaveFile(path, src)
+	observe("save", err)
+	return err
+}
+
+func (p instrumentedProvider) AppendFile(path string, offset int64, src io.Reader) (int64, error) {
+	n, err := p.f.AppendFile(path, offset, src)
+	observe("append", err)
+	return n, err
+}
+
+func (p instrumentedProvider) MoveFile(from, to string) error {
+	err := p.f.MoveFile(from, to)
+	observe("move", err)
+	return err
+}
+
+func (p instrumentedProvider) RemoveFile(path string) error {
+	err := p.f.RemoveFile(path)
+	observe("remove", err)
+	return err
+}
+
+func (p instrumentedProvider) FileExists(path string) (bool, error) {
+	ok, err := p.f.FileExists(path)
+	observe("exists", err)
+	return ok, err
+}
@@ -1,6 +1,9 @@
 package file_provider
 
 import (
+	"errors"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 )
@@ -16,6 +19,7 @@ func (f *FileProvider) GetFile(path string) ([]byte, error) {
 	return os.ReadFile(path)
 }
 
+// GetAllFileNames returns names of regular files in path. Directories are skipped.
 func (f *FileProvider) GetAllFileNames(path string) ([]string, error) {
 	names, err := filepath.Glob(path + "/*")
 	if err != nil {
@@ -23,7 +27,93 @@ func (f *FileProvider) GetAllFileNames(path string) ([]string, error) {
 	}
 	namesClean := make([]string, 0)
 	for _, name := range names {
+		info, err := os.Stat(name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) { // Removed in the meantime
+				continue
+			}
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
 		namesClean = append(namesClean, filepath.Base(name))
 	}
 	return namesClean, nil
 }
+
+func (f *FileProvider) OpenFile(path string) (io.ReadSeekCloser, error) {
+	return os.Open(path)
+}
+
+// SaveFile writes src to path and flushes it to disk, creating parent directories if necessary.
+// A partially written file is removed.
+func (f *FileProvider) SaveFile(path string, src io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, src); err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// AppendFile writes src to path starting at offset and flushes it to disk. Anything after offset is cut off first,
+// so bytes written by an interrupted call and not accounted for are overwritten.
+// Returns the number of bytes written even if src fails in the middle.
+func (f *FileProvider) AppendFile(path string, offset int64, src io.Reader) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	if err := out.Truncate(offset); err != nil {
+		return 0, err
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, src)
+	if syncErr := out.Sync(); syncErr != nil {
+		return 0, syncErr
+	}
+	return n, err
+}
+
+// MoveFile atomically renames from to to, creating parent directories of to if necessary.
+func (f *FileProvider) MoveFile(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+func (f *FileProvider) RemoveFile(path string) error {
+	return os.Remove(path)
+}
+
+func (f *FileProvider) FileExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
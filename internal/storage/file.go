@@ -2,36 +2,101 @@ package storage
 
 import (
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+
 	"github.com/Kapeland/task-Astral/internal/models"
 	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage/encryption"
 	"github.com/Kapeland/task-Astral/internal/storage/repository"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+	"github.com/Kapeland/task-Astral/internal/utils/metrics"
 
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 )
 
 type FileRepo interface {
 	GetAllDocsByOwner(ctx context.Context, listInfo structs.ListInfo, ownerLogin string, own bool) ([]structs.DocEntry, error)
 	DelDoc(ctx context.Context, docID string, userLogin string) (structs.RmDoc, error)
-	PostNewDoc(ctx context.Context, file *structs.File, owner string) error
+	PostNewDoc(ctx context.Context, file *structs.File, owner string) (string, error)
 	GetGrantsByDocID(ctx context.Context, docID string) ([]string, error)
 	GetDoc(ctx context.Context, docID string) (*structs.GetDoc, error)
+	IsDocStored(ctx context.Context, docID string) (bool, error)
+	GetAllDocBlobs(ctx context.Context) ([]structs.DocBlob, error)
+	TrashDoc(ctx context.Context, docID string, userLogin string) (structs.RmDoc, error)
+	RestoreDoc(ctx context.Context, docID string, userLogin string) (structs.RmDoc, error)
+	GetTrashByOwner(ctx context.Context, ownerLogin string) ([]structs.DocEntry, error)
+	GetTrashedBefore(ctx context.Context, before time.Time) ([]structs.TrashedDoc, error)
+	GetPendingDocs(ctx context.Context, limit int) ([]structs.PendingDoc, error)
+	SetScanStatus(ctx context.Context, docID string, status string) error
+	GetDocKey(ctx context.Context, docID string) (*structs.DocKey, error)
+	GetDocKeysNotWrappedBy(ctx context.Context, keyID string, limit int) ([]structs.DocKey, error)
+	UpdateDocKey(ctx context.Context, docID string, oldKeyID string, keyID string, wrapped []byte) error
+	MoveDoc(ctx context.Context, docID string, owner string, folderID string) error
+	UpdateDoc(ctx context.Context, docID string, owner string, upd structs.DocUpdate) error
+	SetDocTags(ctx context.Context, docID string, owner string, tags []string) error
+	SetDocMetadata(ctx context.Context, docID string, owner string, metadata map[string]string) error
+	GetTagsByOwner(ctx context.Context, owner string, prefix string, limit int) ([]structs.TagCount, error)
 }
 
 type FileStorage struct {
 	fr FileRepo
 	fp FileProvider
+	kr Keyring
 }
 
 type FileProvider interface {
 	GetFileByte(file string) ([]byte, error)
+	StageFile(key string, src io.Reader) (string, error)
+	StageRemoval(key string) (string, error)
+	PromoteFile(staged string, key string) error
+	DiscardFile(staged string) error
+	FileExists(key string) (bool, error)
+	GetStagedFiles() ([]structs.StagedFile, error)
+	OpenFile(key string) (io.ReadSeekCloser, error)
+	GetAllKeys() ([]string, error)
+	QuarantineFile(key string) error
+	RemoveFile(key string) error
+}
+
+// Keyring wraps data keys of documents by master keys
+type Keyring interface {
+	CurrentKeyID() string
+	NewDataKey(docID string) ([]byte, string, []byte, error)
+	UnwrapKey(keyID string, wrapped []byte, docID string) ([]byte, error)
+	RewrapKey(keyID string, wrapped []byte, docID string) (string, []byte, error)
 }
 
-func NewFileStorage(fileRepo FileRepo, fp FileProvider) FileStorage {
-	return FileStorage{fr: fileRepo, fp: fp}
+var ErrEncryptionDisabled = errors.New("encryption is disabled")
+
+// NewFileStorage creates file storage. Documents are stored encrypted if kr isn't nil.
+func NewFileStorage(fileRepo FileRepo, fp FileProvider, kr Keyring) FileStorage {
+	return FileStorage{fr: fileRepo, fp: fp, kr: kr}
 }
 
+// DeleteDoc moves the document to the trash. Its blob is kept until the document is purged.
+// Returns models.ErrNotFound or err
 func (m *FileStorage) DeleteDoc(ctx context.Context, docID string, userLogin string) (structs.RmDoc, error) {
-	doc, err := m.fr.DelDoc(ctx, docID, userLogin)
+	doc, err := m.fr.TrashDoc(ctx, docID, userLogin)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return structs.RmDoc{}, models.ErrNotFound
+		}
+		return structs.RmDoc{}, err
+	}
+	return doc, nil
+}
+
+// RestoreDoc brings the document back from the trash.
+// Returns models.ErrNotFound or err
+func (m *FileStorage) RestoreDoc(ctx context.Context, docID string, userLogin string) (structs.RmDoc, error) {
+	doc, err := m.fr.RestoreDoc(ctx, docID, userLogin)
 	if err != nil {
 		if errors.Is(err, repository.ErrObjectNotFound) {
 			return structs.RmDoc{}, models.ErrNotFound
@@ -41,18 +106,265 @@ func (m *FileStorage) DeleteDoc(ctx context.Context, docID string, userLogin str
 	return doc, nil
 }
 
-func (m *FileStorage) AddDoc(ctx context.Context, doc structs.File, owner string, logins []string) error {
-	err := m.fr.PostNewDoc(ctx, &doc, owner)
+func (m *FileStorage) GetTrash(ctx context.Context, ownerLogin string) ([]structs.DocEntry, error) {
+	docs, err := m.fr.GetTrashByOwner(ctx, ownerLogin)
+	if err != nil {
+		return []structs.DocEntry{}, err
+	}
+	return docs, nil
+}
+
+// PurgeTrash permanently deletes documents trashed before the given moment.
+// Returns the number of purged documents.
+func (m *FileStorage) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	docs, err := m.fr.GetTrashedBefore(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, doc := range docs {
+		if err := m.purgeDoc(ctx, doc); err != nil {
+			if errors.Is(err, repository.ErrObjectNotFound) { // Restored or purged in the meantime
+				continue
+			}
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// purgeDoc deletes the document row and its blob.
+// The blob is staged first and brought back if the row can't be deleted.
+func (m *FileStorage) purgeDoc(ctx context.Context, doc structs.TrashedDoc) error {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	key := doc.ID
+	end := traceBlob(ctx, "stage_removal", key)
+	staged, err := m.fp.StageRemoval(key)
+	end(err)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) { // Missing blob is fine: deleting the row repairs it
+		return err
+	}
+
+	_, err = m.fr.DelDoc(ctx, doc.ID, doc.Owner)
+	if err != nil {
+		if staged != "" {
+			if err := m.fp.PromoteFile(staged, key); err != nil {
+				lgr.Error(err.Error(), "FileStorage", "purgeDoc", "PromoteFile")
+			}
+		}
+		return err
+	}
+
+	if staged != "" {
+		if err := m.fp.DiscardFile(staged); err != nil { // Reconcile will remove it later
+			lgr.Error(err.Error(), "FileStorage", "purgeDoc", "DiscardFile")
+		}
+	}
+
+	return nil
+}
+
+// MoveDoc puts the doc into the folder. Empty folderID means the root.
+// Returns models.ErrNotFound or err
+func (m *FileStorage) MoveDoc(ctx context.Context, docID string, owner string, folderID string) error {
+	err := m.fr.MoveDoc(ctx, docID, owner, folderID)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateDoc changes title, visibility and mime of the doc.
+// The blob is stored under the document id, so renaming it doesn't touch the blob.
+// Returns models.ErrNotFound or err
+func (m *FileStorage) UpdateDoc(ctx context.Context, docID string, owner string, upd structs.DocUpdate) error {
+	err := m.fr.UpdateDoc(ctx, docID, owner, upd)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// SetDocTags replaces tags of the doc.
+// Returns models.ErrNotFound or err
+func (m *FileStorage) SetDocTags(ctx context.Context, docID string, owner string, tags []string) error {
+	err := m.fr.SetDocTags(ctx, docID, owner, tags)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// SetDocMetadata replaces metadata of the doc.
+// Returns models.ErrNotFound or err
+func (m *FileStorage) SetDocMetadata(ctx context.Context, docID string, owner string, metadata map[string]string) error {
+	err := m.fr.SetDocMetadata(ctx, docID, owner, metadata)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (m *FileStorage) GetTags(ctx context.Context, owner string, prefix string, limit int) ([]structs.TagCount, error) {
+	return m.fr.GetTagsByOwner(ctx, owner, prefix, limit)
+}
+
+// GetDocGrants returns logins granted access to the doc directly or through its folders
+func (m *FileStorage) GetDocGrants(ctx context.Context, docID string) ([]string, error) {
+	return m.fr.GetGrantsByDocID(ctx, docID)
+}
+
+// AddDoc stores the document blob and row. The blob is stored under the document id, never under its name.
+// The blob is staged first and becomes visible only after the row is committed.
+// Returns the id of the new doc, models.ErrConflict or models.ErrInvalidInput or models.ErrChecksumMismatch or err
+func (m *FileStorage) AddDoc(ctx context.Context, doc structs.File, owner string, logins []string) (string, error) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	doc.ID = uuid.NewString()
+	key := doc.ID
+	h := sha256.New()
+	var w io.Writer = h
+	md := md5.New()
+	if doc.WithMD5 {
+		w = io.MultiWriter(h, md)
+	}
+	counted := &countingReader{r: doc.Data}
+	data := io.TeeReader(counted, w)
+	if m.kr != nil {
+		dek, keyID, wrapped, err := m.kr.NewDataKey(doc.ID)
+		if err != nil {
+			return "", err
+		}
+		data, err = encryption.NewEncryptingReader(data, dek)
+		if err != nil {
+			return "", err
+		}
+		doc.KeyID, doc.WrappedKey = keyID, wrapped
+	}
+	end := traceBlob(ctx, "stage", key)
+	staged, err := m.fp.StageFile(key, data)
+	end(err)
 	if err != nil {
+		return "", err
+	}
+	metrics.StorageBytes.Add(float64(counted.n), metrics.DirectionUpload)
+	doc.SHA256 = hex.EncodeToString(h.Sum(nil))
+	if doc.WithMD5 {
+		doc.MD5 = hex.EncodeToString(md.Sum(nil))
+	}
+	if !isChecksumMatching(doc.Meta.SHA256, doc.SHA256) || !isChecksumMatching(doc.Meta.MD5, doc.MD5) {
+		if err := m.fp.DiscardFile(staged); err != nil {
+			lgr.Error(err.Error(), "FileStorage", "AddDoc", "DiscardFile")
+		}
+		return "", models.ErrChecksumMismatch
+	}
+
+	docID, err := m.fr.PostNewDoc(ctx, &doc, owner)
+	if err != nil {
+		if err := m.fp.DiscardFile(staged); err != nil {
+			lgr.Error(err.Error(), "FileStorage", "AddDoc", "DiscardFile")
+		}
 		if errors.Is(err, repository.ErrDuplicateKey) {
-			return models.ErrConflict
+			return "", models.ErrConflict
 		}
 		if errors.Is(err, repository.ErrAddGrantToLogin) {
-			return models.ErrInvalidInput
+			return "", models.ErrInvalidInput
 		}
+		return "", err
+	}
+
+	end = traceBlob(ctx, "promote", key)
+	err = m.fp.PromoteFile(staged, key)
+	end(err)
+	if err != nil {
+		// The row is already committed, so it has to be compensated
+		if _, err := m.fr.DelDoc(context.WithoutCancel(ctx), docID, owner); err != nil {
+			lgr.Error(err.Error(), "FileStorage", "AddDoc", "DelDoc")
+		}
+		if err := m.fp.DiscardFile(staged); err != nil {
+			lgr.Error(err.Error(), "FileStorage", "AddDoc", "DiscardFile")
+		}
+		return "", err
+	}
+
+	return doc.ID, nil
+}
+
+// isChecksumMatching compares the checksum supplied by the client with the computed one.
+// Nothing to compare with is a match.
+func isChecksumMatching(expected string, actual string) bool {
+	return expected == "" || strings.EqualFold(expected, actual)
+}
+
+// Reconcile finishes or rolls back blob operations interrupted by a crash and repairs what is left inconsistent.
+// A staged blob is put in place if its row exists and the blob itself is missing, otherwise it's removed.
+// Then blobs without rows are quarantined. Rows without blobs can't be repaired, their content is lost,
+// so they are only reported.
+func (m *FileStorage) Reconcile(ctx context.Context) error {
+	if err := m.finishStaged(ctx); err != nil {
+		return err
+	}
+
+	report, err := m.Scan(ctx, structs.ScanOptions{Action: ScanActionQuarantine})
+	if err != nil {
+		return err
+	}
+	lgr := logger.GetLogger().WithContext(ctx)
+	for _, doc := range report.MissingFiles {
+		lgr.Warn("blob of document "+doc.ID+" is missing", "FileStorage", "Reconcile", "Scan")
+	}
+
+	return nil
+}
+
+// finishStaged puts staged blobs in place or removes them
+func (m *FileStorage) finishStaged(ctx context.Context) error {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	staged, err := m.fp.GetStagedFiles()
+	if err != nil {
 		return err
 	}
 
+	for _, sf := range staged {
+		stored, err := m.fr.IsDocStored(ctx, sf.Key)
+		if err != nil {
+			return err
+		}
+		exists, err := m.fp.FileExists(sf.Key)
+		if err != nil {
+			return err
+		}
+
+		if stored && !exists {
+			if err := m.fp.PromoteFile(sf.Name, sf.Key); err != nil {
+				return err
+			}
+			lgr.Info("restored staged "+sf.Op+" of "+sf.Key, "FileStorage", "finishStaged", "PromoteFile")
+			continue
+		}
+
+		if err := m.fp.DiscardFile(sf.Name); err != nil {
+			return err
+		}
+		lgr.Info("discarded staged "+sf.Op+" of "+sf.Key, "FileStorage", "finishStaged", "DiscardFile")
+	}
+
 	return nil
 }
 
@@ -81,6 +393,113 @@ func (m *FileStorage) GetDocsByOwner(ctx context.Context, listInfo structs.ListI
 	return docs, nil
 }
 
+// OpenDoc opens the blob of the document for reading. Encrypted blobs are decrypted transparently.
+// Returns models.ErrNotFound or err
+func (m *FileStorage) OpenDoc(ctx context.Context, docID string) (io.ReadSeekCloser, error) {
+	key, err := m.fr.GetDocKey(ctx, docID)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return nil, models.ErrNotFound
+		}
+		return nil, err
+	}
+
+	end := traceBlob(ctx, "open", docID)
+	data, err := m.fp.OpenFile(docID)
+	end(err)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, models.ErrNotFound
+		}
+		return nil, err
+	}
+	if !key.KeyID.Valid { // Stored before encryption was enabled
+		return downloadCounter{data}, nil
+	}
+	if m.kr == nil {
+		data.Close()
+		return nil, ErrEncryptionDisabled
+	}
+
+	dek, err := m.kr.UnwrapKey(key.KeyID.String, key.WrappedKey, docID)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	plain, err := encryption.NewDecryptingReader(data, dek)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	return downloadCounter{plain}, nil
+}
+
+const rotateBatchSize = 100
+
+// RotateKeys re-wraps data keys of all documents by the current master key. Blobs aren't rewritten.
+// Returns the number of re-wrapped keys.
+func (m *FileStorage) RotateKeys(ctx context.Context) (int, error) {
+	if m.kr == nil {
+		return 0, ErrEncryptionDisabled
+	}
+
+	rotated := 0
+	for {
+		keys, err := m.fr.GetDocKeysNotWrappedBy(ctx, m.kr.CurrentKeyID(), rotateBatchSize)
+		if err != nil {
+			return rotated, err
+		}
+		if len(keys) == 0 {
+			return rotated, nil
+		}
+
+		for _, key := range keys {
+			keyID, wrapped, err := m.kr.RewrapKey(key.KeyID.String, key.WrappedKey, key.ID)
+			if err != nil {
+				return rotated, err
+			}
+			err = m.fr.UpdateDocKey(ctx, key.ID, key.KeyID.String, keyID, wrapped)
+			if err != nil && !errors.Is(err, repository.ErrObjectNotFound) { // Purged in the meantime
+				return rotated, err
+			}
+			rotated++
+		}
+	}
+}
+
+func (m *FileStorage) GetPendingDocs(ctx context.Context, limit int) ([]structs.PendingDoc, error) {
+	return m.fr.GetPendingDocs(ctx, limit)
+}
+
+// SetScanStatus sets malware scan status of the document.
+// Returns models.ErrNotFound or err
+func (m *FileStorage) SetScanStatus(ctx context.Context, docID string, status string) error {
+	err := m.fr.SetScanStatus(ctx, docID, status)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// QuarantineDoc marks the document as infected and moves its blob out of sight.
+// The status is set first, so the document can't be downloaded even if the blob can't be moved.
+// Returns models.ErrNotFound or err
+func (m *FileStorage) QuarantineDoc(ctx context.Context, docID string) error {
+	if err := m.SetScanStatus(ctx, docID, structs.ScanInfected); err != nil {
+		return err
+	}
+	end := traceBlob(ctx, "quarantine", docID)
+	err := m.fp.QuarantineFile(docID)
+	end(err)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
 func (m *FileStorage) GetDoc(ctx context.Context, docID string) (structs.GetDoc, error) {
 	doc, err := m.fr.GetDoc(ctx, docID)
 	if err != nil {
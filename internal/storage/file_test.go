@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage/file-storage/file"
+)
+
+func TestReconcile(t *testing.T) {
+	repo := &fakeRepo{docs: []structs.DocBlob{
+		{ID: "stored"},
+		{ID: "put-committed"}, // Crashed after the row was committed, before the blob was promoted
+		{ID: "del-failed"},    // Crashed after the blob was staged for removal, the row wasn't deleted
+		{ID: "lost"},
+	}}
+	fp := newFakeProvider(map[string][]byte{
+		"stored": []byte("a"),
+		"orphan": []byte("b"),
+	})
+	fp.stage(file.StagedPut, "put-committed", []byte("c"))
+	fp.stage(file.StagedPut, "put-rolled-back", []byte("d"))
+	fp.stage(file.StagedDel, "del-failed", []byte("e"))
+	store := NewFileStorage(repo, fp, nil)
+
+	if err := store.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+
+	for key, want := range map[string]string{"stored": "a", "put-committed": "c", "del-failed": "e"} {
+		if got, ok := fp.blobs[key]; !ok || string(got) != want {
+			t.Errorf("blob %s = %q, %v; want %q", key, got, ok, want)
+		}
+	}
+	if len(fp.blobs) != 3 {
+		t.Errorf("blobs = %v; want only the 3 referenced ones", fp.blobs)
+	}
+	if len(fp.staged) != 0 {
+		t.Errorf("staged = %v; want none", fp.staged)
+	}
+	if _, ok := fp.quarantined["orphan"]; !ok || len(fp.quarantined) != 1 {
+		t.Errorf("quarantined = %v; want only orphan", fp.quarantined)
+	}
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage/repository"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type FolderRepo interface {
+	CreateFolder(ctx context.Context, folder *structs.Folder) error
+	GetFolder(ctx context.Context, folderID string) (*structs.Folder, error)
+	GetSubfolders(ctx context.Context, parentID string, owner string) ([]structs.Folder, error)
+	GetBreadcrumbs(ctx context.Context, folderID string) ([]structs.Folder, error)
+	GetFolderGrants(ctx context.Context, folderID string) ([]string, error)
+	UpdateFolder(ctx context.Context, folderID string, owner string, name *string, parentID *string) error
+	DeleteFolder(ctx context.Context, folderID string, owner string) (int, error)
+	SetFolderGrants(ctx context.Context, folderID string, owner string, logins []string) error
+}
+
+type FolderStorage struct {
+	fr FolderRepo
+}
+
+func NewFolderStorage(fr FolderRepo) FolderStorage {
+	return FolderStorage{fr: fr}
+}
+
+// mapFolderErr maps repository errors to model ones
+func mapFolderErr(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrObjectNotFound):
+		return models.ErrNotFound
+	case errors.Is(err, repository.ErrDuplicateKey):
+		return models.ErrConflict
+	case errors.Is(err, repository.ErrAddGrantToLogin):
+		return models.ErrInvalidInput
+	default:
+		return err
+	}
+}
+
+// CreateFolder creates a folder inside parentID, which must belong to owner. Empty parentID means the root.
+// Returns models.ErrNotFound or models.ErrConflict or err
+func (s *FolderStorage) CreateFolder(ctx context.Context, owner string, parentID string, name string) (structs.Folder, error) {
+	folder := structs.Folder{
+		ID:        uuid.NewString(),
+		Owner:     owner,
+		ParentID:  parentID,
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+	if err := s.fr.CreateFolder(ctx, &folder); err != nil {
+		return structs.Folder{}, mapFolderErr(err)
+	}
+	return folder, nil
+}
+
+// GetFolder returns the folder regardless of its owner.
+// Returns models.ErrNotFound or err
+func (s *FolderStorage) GetFolder(ctx context.Context, folderID string) (structs.Folder, error) {
+	folder, err := s.fr.GetFolder(ctx, folderID)
+	if err != nil {
+		return structs.Folder{}, mapFolderErr(err)
+	}
+	return *folder, nil
+}
+
+func (s *FolderStorage) GetSubfolders(ctx context.Context, parentID string, owner string) ([]structs.Folder, error) {
+	return s.fr.GetSubfolders(ctx, parentID, owner)
+}
+
+func (s *FolderStorage) GetBreadcrumbs(ctx context.Context, folderID string) ([]structs.Folder, error) {
+	return s.fr.GetBreadcrumbs(ctx, folderID)
+}
+
+func (s *FolderStorage) GetFolderGrants(ctx context.Context, folderID string) ([]string, error) {
+	return s.fr.GetFolderGrants(ctx, folderID)
+}
+
+// UpdateFolder renames and/or moves the folder, both or nothing.
+// It fails with models.ErrNotFound if the folder or the new parent is missing,
+// or if the new parent is inside the folder itself.
+// Returns models.ErrNotFound or models.ErrConflict or err
+func (s *FolderStorage) UpdateFolder(ctx context.Context, folderID string, owner string, name *string, parentID *string) error {
+	return mapFolderErr(s.fr.UpdateFolder(ctx, folderID, owner, name, parentID))
+}
+
+// DeleteFolder deletes the folder with its subfolders and moves documents inside to the trash.
+// Returns the number of trashed documents, models.ErrNotFound or err
+func (s *FolderStorage) DeleteFolder(ctx context.Context, folderID string, owner string) (int, error) {
+	trashed, err := s.fr.DeleteFolder(ctx, folderID, owner)
+	if err != nil {
+		return 0, mapFolderErr(err)
+	}
+	return trashed, nil
+}
+
+// Returns models.ErrNotFound or models.ErrInvalidInput or err
+func (s *FolderStorage) SetFolderGrants(ctx context.Context, folderID string, owner string, logins []string) error {
+	return mapFolderErr(s.fr.SetFolderGrants(ctx, folderID, owner, logins))
+}
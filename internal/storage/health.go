@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Kapeland/task-Astral/internal/storage/db"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pressly/goose/v3"
+)
+
+// Check pings the database
+func (s *PostgresStorage) Check(ctx context.Context) error {
+	return s.DB.GetDB().PingContext(ctx)
+}
+
+// MigrationsHealth checks that the schema is at the version of the latest known migration
+type MigrationsHealth struct {
+	db  *db.PgDatabase
+	dir string
+}
+
+func NewMigrationsHealth(db *db.PgDatabase, dir string) MigrationsHealth {
+	return MigrationsHealth{db: db, dir: dir}
+}
+
+func (h *MigrationsHealth) Check(ctx context.Context) error {
+	migrations, err := goose.CollectMigrations(h.dir, 0, goose.MaxVersion)
+	if err != nil {
+		return err
+	}
+	latest, err := migrations.Last()
+	if err != nil {
+		return err
+	}
+	current, err := goose.GetDBVersionContext(ctx, h.db.GetDB().DB)
+	if err != nil {
+		return err
+	}
+	if current < latest.Version {
+		return fmt.Errorf("schema is at version %d, latest migration is %d", current, latest.Version)
+	}
+	return nil
+}
+
+// RedisHealth pings Redis
+type RedisHealth struct {
+	rdb *redis.Client
+}
+
+func NewRedisHealth(rdb *redis.Client) RedisHealth {
+	return RedisHealth{rdb: rdb}
+}
+
+func (h *RedisHealth) Check(ctx context.Context) error {
+	return h.rdb.Ping(ctx).Err()
+}
+
+// BlobPinger checks that blobs can be written and removed
+type BlobPinger interface {
+	Ping() error
+}
+
+// BlobHealth checks the blob storage. File systems can't be interrupted, so a hung one is reported when ctx is done
+type BlobHealth struct {
+	bp BlobPinger
+}
+
+func NewBlobHealth(bp BlobPinger) BlobHealth {
+	return BlobHealth{bp: bp}
+}
+
+func (h *BlobHealth) Check(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- h.bp.Ping()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"io"
+
+	"github.com/Kapeland/task-Astral/internal/utils/metrics"
+)
+
+// countingReader counts bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// downloadCounter adds bytes read from a document to the download metric
+type downloadCounter struct {
+	io.ReadSeekCloser
+}
+
+func (d downloadCounter) Read(p []byte) (int, error) {
+	n, err := d.ReadSeekCloser.Read(p)
+	if n > 0 {
+		metrics.StorageBytes.Add(float64(n), metrics.DirectionDownload)
+	}
+	return n, err
+}
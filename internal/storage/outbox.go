@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/outbox"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+type OutboxRepo interface {
+	ClaimDueEvents(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]outbox.Row, error)
+	MarkPublished(ctx context.Context, id int64, now time.Time) error
+	MarkFailed(ctx context.Context, id int64, lastErr string, nextAttempt time.Time) error
+	DeletePublishedBefore(ctx context.Context, before time.Time) (int, error)
+}
+
+// OutboxStorage gives events of the outbox to the relay. Events are retried until every sink accepts them,
+// MaxAttempts of the policy isn't used
+type OutboxStorage struct {
+	or     OutboxRepo
+	policy RetryPolicy
+}
+
+func NewOutboxStorage(or OutboxRepo, policy RetryPolicy) OutboxStorage {
+	return OutboxStorage{or: or, policy: policy}
+}
+
+// ClaimEvents returns events to relay now. They are leased, so other instances skip them
+func (s *OutboxStorage) ClaimEvents(ctx context.Context, limit int) ([]structs.OutboxEntry, error) {
+	now := time.Now()
+	rows, err := s.or.ClaimDueEvents(ctx, now, now.Add(s.policy.Lease), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]structs.OutboxEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = structs.OutboxEntry{ID: row.ID, Attempts: row.Attempts}
+		if err := jsoniter.UnmarshalFromString(row.Payload, &entries[i].Event); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func (s *OutboxStorage) MarkPublished(ctx context.Context, id int64) error {
+	return s.or.MarkPublished(ctx, id, time.Now())
+}
+
+// MarkFailed schedules the next attempt with exponential backoff
+func (s *OutboxStorage) MarkFailed(ctx context.Context, entry structs.OutboxEntry, relayErr error) error {
+	next := time.Now().Add(s.policy.Delay(entry.Attempts + 1))
+	return s.or.MarkFailed(ctx, entry.ID, relayErr.Error(), next)
+}
+
+// PurgePublished deletes events published before the time. Returns the number of deleted ones
+func (s *OutboxStorage) PurgePublished(ctx context.Context, before time.Time) (int, error) {
+	return s.or.DeletePublishedBefore(ctx, before)
+}
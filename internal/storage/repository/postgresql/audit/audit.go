@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage/db"
+	"time"
+)
+
+type Repo struct {
+	db db.DBops
+}
+
+func New(db db.DBops) *Repo {
+	return &Repo{db: db}
+}
+
+// AddEvent appends event to the audit log
+func (r *Repo) AddEvent(ctx context.Context, event *structs.AuditEvent) error {
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO audit_events(created_at, actor, action, target, ip, user_agent, result, details)
+				VALUES($1,$2,$3,$4,$5,$6,$7,$8);`,
+		event.CreatedAt, event.Actor, event.Action, event.Target, event.IP, event.UserAgent, event.Result, event.Details)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetEvents returns events matching filter, the newest first
+func (r *Repo) GetEvents(ctx context.Context, filter structs.AuditFilter) ([]structs.AuditEvent, error) {
+	var events []structs.AuditEvent
+
+	err := r.db.Select(ctx, &events,
+		`SELECT id, created_at, actor, action, target, ip, user_agent, result, details FROM audit_events
+				WHERE ($1 = '' or actor = $1)
+				  and ($2 = '' or action = $2 or (right($2, 1) = '.' and starts_with(action, $2)))
+				  and ($3 = '' or target = $3) and ($4 = '' or result = $4)
+				  and ($5::timestamp IS NULL or created_at >= $5) and ($6::timestamp IS NULL or created_at < $6)
+				  and ($7 = 0 or id < $7)
+				ORDER BY id DESC limit $8;`,
+		filter.Actor, filter.Action, filter.Target, filter.Result,
+		nullTime(filter.Since), nullTime(filter.Until), filter.BeforeID, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -6,11 +6,14 @@ import (
 	"github.com/Kapeland/task-Astral/internal/models/structs"
 	"github.com/Kapeland/task-Astral/internal/storage/db"
 	"github.com/Kapeland/task-Astral/internal/storage/repository"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/outbox"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
 	"log/slog"
 	"math"
+	"strings"
 	"time"
 )
 
@@ -22,6 +25,38 @@ func New(db db.DBops) *Repo {
 	return &Repo{db: db}
 }
 
+// grantsQuery selects logins granted access to the doc $1 directly or through the folders it's inside of
+const grantsQuery = `WITH RECURSIVE ancestors AS (
+			SELECT f.id, f.parent_id FROM folders f JOIN documents d ON d.folder_id = f.id WHERE d.id = $1
+			UNION
+			SELECT f.id, f.parent_id FROM folders f JOIN ancestors a ON f.id = a.parent_id
+		)
+		SELECT login
+		FROM documentaccess
+		WHERE document_id = $1
+		UNION
+		SELECT fa.login
+		FROM folder_access fa JOIN ancestors a ON fa.folder_id = a.id;`
+
+// AddDocEvent writes the event of the doc to the outbox within tx. Logins of the event are users granted access to the doc.
+// Other repositories changing documents use it too
+func AddDocEvent(ctx context.Context, tx *db.Tx, event structs.DocEvent) error {
+	if err := tx.SelectContext(ctx, &event.Logins, grantsQuery, event.DocID); err != nil {
+		return err
+	}
+	return outbox.Add(ctx, tx, event)
+}
+
+// docColumns are the columns of structs.DocEntry
+const docColumns = `id, title, mime, is_public, created_at, COALESCE(sha256, '') AS sha256, COALESCE(md5, '') AS md5,
+		COALESCE(folder_id::text, '') AS folder_id, array_to_json(tags)::text AS tags, metadata::text AS metadata`
+
+// liveDocsInFolder selects docs of the owner $1 which aren't in the trash.
+// $3 is the folder of the docs: empty means any folder, "root" means the root
+const liveDocsInFolder = `FROM documents
+		WHERE owner=$1 and deleted_at IS NULL
+		  and ($3 = '' or ($3 = 'root' and folder_id IS NULL) or folder_id = NULLIF(NULLIF($3, 'root'), '')::uuid)`
+
 // GetAllDocsByOwner returns all docs belonging to an owner from postgres
 func (m *Repo) GetAllDocsByOwner(ctx context.Context, listInfo structs.ListInfo, ownerLogin string, own bool) ([]structs.DocEntry, error) {
 	filter := listInfo.Value
@@ -44,81 +79,71 @@ func (m *Repo) GetAllDocsByOwner(ctx context.Context, listInfo structs.ListInfo,
 		switch listInfo.Key {
 		case "":
 			err = tx.SelectContext(ctx, &docs,
-				`SELECT id, title, mime, is_public, created_at
-		FROM documents
-		WHERE owner=$1 ORDER BY title, created_at limit $2;`, ownerLogin, lmt)
+				`SELECT `+docColumns+` `+liveDocsInFolder+` ORDER BY title, created_at limit $2;`, ownerLogin, lmt, listInfo.Folder)
 		case "id":
 			err = tx.SelectContext(ctx, &docs,
-				`SELECT id, title, mime, is_public, created_at
-		FROM documents
-		WHERE owner=$1 and id=$2 ORDER BY title, created_at limit $3;`, ownerLogin, filter, lmt)
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and id=$2 ORDER BY title, created_at limit $4;`, ownerLogin, filter, listInfo.Folder, lmt)
 		case "name":
 			err = tx.SelectContext(ctx, &docs,
-				`SELECT id, title, mime, is_public, created_at
-		FROM documents
-		WHERE owner=$1 and title=$2 ORDER BY title, created_at limit $3;`, ownerLogin, filter, lmt)
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and title=$2 ORDER BY title, created_at limit $4;`, ownerLogin, filter, listInfo.Folder, lmt)
 		case "mime":
 			err = tx.SelectContext(ctx, &docs,
-				`SELECT id, title, mime, is_public, created_at
-		FROM documents
-		WHERE owner=$1 and mime=$2 ORDER BY title, created_at limit $3;`, ownerLogin, filter, lmt)
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and mime=$2 ORDER BY title, created_at limit $4;`, ownerLogin, filter, listInfo.Folder, lmt)
 		case "file":
 			err = tx.SelectContext(ctx, &docs,
-				`SELECT id, title, mime, is_public, created_at
-		FROM documents
-		WHERE owner=$1 and file=$2 ORDER BY title, created_at limit $3;`, ownerLogin, filter, lmt)
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and file=$2 ORDER BY title, created_at limit $4;`, ownerLogin, filter, listInfo.Folder, lmt)
 		case "public":
 			err = tx.SelectContext(ctx, &docs,
-				`SELECT id, title, mime, is_public, created_at
-		FROM documents
-		WHERE owner=$1 and is_public=$2 ORDER BY title, created_at limit $3;`, ownerLogin, filter, lmt)
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and is_public=$2 ORDER BY title, created_at limit $4;`, ownerLogin, filter, listInfo.Folder, lmt)
 		case "created":
 			err = tx.SelectContext(ctx, &docs,
-				`SELECT id, title, mime, is_public, created_at
-		FROM documents
-		WHERE owner=$1 and created_at=$2 ORDER BY title, created_at limit $3;`, ownerLogin, filter, lmt)
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and created_at=$2 ORDER BY title, created_at limit $4;`, ownerLogin, filter, listInfo.Folder, lmt)
+		case "tag":
+			err = tx.SelectContext(ctx, &docs,
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and tags @> ARRAY[$2] ORDER BY title, created_at limit $4;`, ownerLogin, filter, listInfo.Folder, lmt)
 		default:
-			err = repository.ErrObjectNotFound
+			metaKey, ok := strings.CutPrefix(listInfo.Key, structs.MetadataKeyPrefix)
+			if !ok {
+				err = repository.ErrObjectNotFound
+				break
+			}
+			err = tx.SelectContext(ctx, &docs,
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and metadata @> jsonb_build_object($5::text, $2::text) ORDER BY title, created_at limit $4;`, ownerLogin, filter, listInfo.Folder, lmt, metaKey)
 		}
 	} else {
 		switch listInfo.Key {
 		case "":
 			err = tx.SelectContext(ctx, &docs,
-				`SELECT id, title, mime, is_public, created_at
-		FROM documents
-		WHERE owner=$1 and is_public=true ORDER BY title, created_at limit $2;`, ownerLogin, lmt)
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and is_public=true ORDER BY title, created_at limit $2;`, ownerLogin, lmt, listInfo.Folder)
 		case "id":
 			err = tx.SelectContext(ctx, &docs,
-				`SELECT id, title, mime, is_public, created_at
-		FROM documents
-		WHERE owner=$1 and id=$2 and is_public=true ORDER BY title, created_at limit $3;`, ownerLogin, filter, lmt)
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and id=$2 and is_public=true ORDER BY title, created_at limit $4;`, ownerLogin, filter, listInfo.Folder, lmt)
 		case "name":
 			err = tx.SelectContext(ctx, &docs,
-				`SELECT id, title, mime, is_public, created_at
-		FROM documents
-		WHERE owner=$1 and title=$2 and is_public=true ORDER BY title, created_at limit $3;`, ownerLogin, filter, lmt)
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and title=$2 and is_public=true ORDER BY title, created_at limit $4;`, ownerLogin, filter, listInfo.Folder, lmt)
 		case "mime":
 			err = tx.SelectContext(ctx, &docs,
-				`SELECT id, title, mime, is_public, created_at
-		FROM documents
-		WHERE owner=$1 and mime=$2 and is_public=true ORDER BY title, created_at limit $3;`, ownerLogin, filter, lmt)
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and mime=$2 and is_public=true ORDER BY title, created_at limit $4;`, ownerLogin, filter, listInfo.Folder, lmt)
 		case "file":
 			err = tx.SelectContext(ctx, &docs,
-				`SELECT id, title, mime, is_public, created_at
-		FROM documents
-		WHERE owner=$1 and file=$2 and is_public=true ORDER BY title, created_at limit $3;`, ownerLogin, filter, lmt)
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and file=$2 and is_public=true ORDER BY title, created_at limit $4;`, ownerLogin, filter, listInfo.Folder, lmt)
 		case "public":
 			err = tx.SelectContext(ctx, &docs,
-				`SELECT id, title, mime, is_public, created_at
-		FROM documents
-		WHERE owner=$1 and is_public=true ORDER BY title, created_at limit $3;`, ownerLogin, filter, lmt)
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and is_public=true ORDER BY title, created_at limit $2;`, ownerLogin, lmt, listInfo.Folder)
 		case "created":
 			err = tx.SelectContext(ctx, &docs,
-				`SELECT id, title, mime, is_public, created_at
-		FROM documents
-		WHERE owner=$1 and created_at=$2 and is_public=true ORDER BY title, created_at limit $3;`, ownerLogin, filter, lmt)
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and created_at=$2 and is_public=true ORDER BY title, created_at limit $4;`, ownerLogin, filter, listInfo.Folder, lmt)
+		case "tag":
+			err = tx.SelectContext(ctx, &docs,
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and tags @> ARRAY[$2] and is_public=true ORDER BY title, created_at limit $4;`, ownerLogin, filter, listInfo.Folder, lmt)
 		default:
-			err = repository.ErrObjectNotFound
+			metaKey, ok := strings.CutPrefix(listInfo.Key, structs.MetadataKeyPrefix)
+			if !ok {
+				err = repository.ErrObjectNotFound
+				break
+			}
+			err = tx.SelectContext(ctx, &docs,
+				`SELECT `+docColumns+` `+liveDocsInFolder+` and metadata @> jsonb_build_object($5::text, $2::text) and is_public=true ORDER BY title, created_at limit $4;`, ownerLogin, filter, listInfo.Folder, lmt, metaKey)
 		}
 	}
 
@@ -139,9 +164,10 @@ func (m *Repo) GetAllDocsByOwner(ctx context.Context, listInfo structs.ListInfo,
 	return docsOut, nil
 }
 
-// DelDoc  deletes doc in postgres
+// DelDoc  deletes doc in postgres permanently.
+// Trashed docs have had their event already, others get doc.deleted in the outbox
 func (m *Repo) DelDoc(ctx context.Context, docID string, userLogin string) (structs.RmDoc, error) {
-	tmpID, tmpTitle := "", ""
+	tmpID, tmpTitle, live := "", "", false
 
 	tx, err := m.db.(*db.PgDatabase).BeginX(ctx, nil)
 	if err != nil {
@@ -149,8 +175,15 @@ func (m *Repo) DelDoc(ctx context.Context, docID string, userLogin string) (stru
 	}
 	defer tx.Rollback()
 
+	// Grants are deleted with the doc, so they are read first
+	event := structs.DocEvent{Type: structs.EventDocDeleted, DocID: docID, Owner: userLogin}
+	if err := tx.SelectContext(ctx, &event.Logins, grantsQuery, docID); err != nil {
+		return structs.RmDoc{}, err
+	}
+
 	err = tx.QueryRowContext(ctx,
-		`DELETE FROM documents WHERE id = $1 and owner = $2 returning id, title;`, docID, userLogin).Scan(&tmpID, &tmpTitle)
+		`DELETE FROM documents WHERE id = $1 and owner = $2 returning id, title, deleted_at IS NULL;`,
+		docID, userLogin).Scan(&tmpID, &tmpTitle, &live)
 
 	switch {
 	case err != nil && (errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows)):
@@ -158,6 +191,12 @@ func (m *Repo) DelDoc(ctx context.Context, docID string, userLogin string) (stru
 	case err != nil:
 		return structs.RmDoc{}, err
 	default:
+		if live {
+			event.Name = tmpTitle
+			if err := outbox.Add(ctx, tx, event); err != nil {
+				return structs.RmDoc{}, err
+			}
+		}
 		if err := tx.Commit(); err != nil {
 			slog.Info("Looks like the context has been closed")
 			slog.Error(err.Error())
@@ -173,27 +212,36 @@ func (m *Repo) DelDoc(ctx context.Context, docID string, userLogin string) (stru
 }
 
 // PostNewDoc add doc info to postgres
-func (m *Repo) PostNewDoc(ctx context.Context, file *structs.File, owner string) error {
+// Returns the id of the new doc
+func (m *Repo) PostNewDoc(ctx context.Context, file *structs.File, owner string) (string, error) {
 	//TODO: По сути тут не учитывается есть ли такой документ. Хотя дальше это предполагается.
 	docID := ""
+	tags, metadata, err := marshalLabels(file.Meta.Tags, file.Meta.Metadata)
+	if err != nil {
+		return "", err
+	}
 
 	tx, err := m.db.(*db.PgDatabase).BeginX(ctx, nil)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer tx.Rollback()
 
 	err = tx.QueryRowContext(ctx,
-		`INSERT INTO documents(title, content, mime, owner, is_public, created_at, file)
-				VALUES($1,$2,$3,$4,$5,$6,$7) returning id;`, file.Meta.Name, string(file.Json), file.Meta.Mime, owner, file.Meta.Public, time.Now(), file.Meta.File).Scan(&docID)
+		`INSERT INTO documents(id, title, content, mime, owner, is_public, created_at, file, sha256, md5, key_id, wrapped_key, folder_id,
+				tags, metadata)
+				VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,NULLIF($10, ''),NULLIF($11, ''),$12,NULLIF($13, '')::uuid,
+				ARRAY(SELECT jsonb_array_elements_text($14::jsonb)),$15::jsonb) returning id;`,
+		file.ID, file.Meta.Name, string(file.Json), file.Meta.Mime, owner, file.Meta.Public, time.Now(), file.Meta.File, file.SHA256,
+		file.MD5, file.KeyID, file.WrappedKey, file.Meta.Folder, tags, metadata).Scan(&docID)
 
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		var pgErr *pgconn.PgError
 		errors.As(err, &pgErr)
 		if pgErr.Code == "23505" {
-			return repository.ErrDuplicateKey
+			return "", repository.ErrDuplicateKey
 		}
-		return err
+		return "", err
 	}
 	for _, login := range file.Meta.Grant {
 		_, err = tx.ExecContext(ctx,
@@ -205,24 +253,44 @@ func (m *Repo) PostNewDoc(ctx context.Context, file *structs.File, owner string)
 			errors.As(err, &pgErr)
 			switch pgErr.Code {
 			case "23503":
-				return repository.ErrAddGrantToLogin
+				return "", repository.ErrAddGrantToLogin
 			case "23505":
-				return repository.ErrDuplicateKey
+				return "", repository.ErrDuplicateKey
 			default:
-				return err
+				return "", err
 			}
 		}
 	}
 
+	if err := AddDocEvent(ctx, tx, structs.DocEvent{
+		Type:  structs.EventDocCreated,
+		DocID: docID,
+		Owner: owner,
+		Name:  file.Meta.Name,
+	}); err != nil {
+		return "", err
+	}
+	if len(file.Meta.Grant) != 0 {
+		if err := AddDocEvent(ctx, tx, structs.DocEvent{
+			Type:  structs.EventDocShared,
+			DocID: docID,
+			Owner: owner,
+			Name:  file.Meta.Name,
+		}); err != nil {
+			return "", err
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		slog.Info("Looks like the context has been closed")
 		slog.Error(err.Error())
-		return err
+		return "", err
 	}
 
-	return nil
+	return docID, nil
 }
 
+// GetGrantsByDocID returns logins granted access to the doc directly or through the folders it's inside of
 func (m *Repo) GetGrantsByDocID(ctx context.Context, docID string) ([]string, error) {
 	var logins []*string
 
@@ -232,10 +300,7 @@ func (m *Repo) GetGrantsByDocID(ctx context.Context, docID string) ([]string, er
 	}
 	defer tx.Rollback()
 
-	err = tx.SelectContext(ctx, &logins,
-		`SELECT login
-		FROM documentaccess
-		WHERE document_id=$1;`, docID)
+	err = tx.SelectContext(ctx, &logins, grantsQuery, docID)
 
 	if err != nil {
 		return nil, err
@@ -264,8 +329,8 @@ func (m *Repo) GetDoc(ctx context.Context, docID string) (*structs.GetDoc, error
 	defer tx.Rollback()
 
 	err = tx.GetContext(ctx, &doc,
-		`SELECT mime, title, owner, is_public, file FROM documents
-				WHERE id=$1;`, docID)
+		`SELECT id, mime, title, owner, is_public, file, scan_status, COALESCE(sha256, '') AS sha256, COALESCE(md5, '') AS md5 FROM documents
+				WHERE id=$1 and deleted_at IS NULL;`, docID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
 			return nil, repository.ErrObjectNotFound
@@ -281,3 +346,324 @@ func (m *Repo) GetDoc(ctx context.Context, docID string) (*structs.GetDoc, error
 
 	return &doc, nil
 }
+
+// IsDocStored checks whether there is a doc with such id. Malformed ids are reported as not stored.
+func (m *Repo) IsDocStored(ctx context.Context, docID string) (bool, error) {
+	stored := false
+
+	err := m.db.Get(ctx, &stored,
+		`SELECT EXISTS(SELECT 1 FROM documents WHERE id=$1);`, docID)
+	if err != nil {
+		return false, err
+	}
+
+	return stored, nil
+}
+
+// GetAllDocBlobs returns blob related info of all docs
+func (m *Repo) GetAllDocBlobs(ctx context.Context) ([]structs.DocBlob, error) {
+	var docs []structs.DocBlob
+
+	err := m.db.Select(ctx, &docs,
+		`SELECT id, title, sha256, scan_status FROM documents;`)
+	if err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// TrashDoc marks doc as deleted. Such doc is hidden until it's restored or purged.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) TrashDoc(ctx context.Context, docID string, userLogin string) (structs.RmDoc, error) {
+	doc := structs.RmDoc{}
+
+	tx, err := m.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return structs.RmDoc{}, err
+	}
+	defer tx.Rollback()
+
+	err = tx.GetContext(ctx, &doc,
+		`UPDATE documents SET deleted_at = $1
+				WHERE id = $2 and owner = $3 and deleted_at IS NULL returning id, title;`, time.Now(), docID, userLogin)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return structs.RmDoc{}, repository.ErrObjectNotFound
+		}
+		return structs.RmDoc{}, err
+	}
+	if err := AddDocEvent(ctx, tx, structs.DocEvent{
+		Type:  structs.EventDocDeleted,
+		DocID: doc.ID,
+		Owner: userLogin,
+		Name:  doc.Name,
+	}); err != nil {
+		return structs.RmDoc{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return structs.RmDoc{}, err
+	}
+
+	return doc, nil
+}
+
+// RestoreDoc brings doc back from the trash and writes doc.updated to the outbox, as doc.deleted has been written on trashing.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) RestoreDoc(ctx context.Context, docID string, userLogin string) (structs.RmDoc, error) {
+	doc := structs.RmDoc{}
+
+	tx, err := m.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return structs.RmDoc{}, err
+	}
+	defer tx.Rollback()
+
+	err = tx.GetContext(ctx, &doc,
+		`UPDATE documents SET deleted_at = NULL
+				WHERE id = $1 and owner = $2 and deleted_at IS NOT NULL returning id, title;`, docID, userLogin)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return structs.RmDoc{}, repository.ErrObjectNotFound
+		}
+		return structs.RmDoc{}, err
+	}
+	if err := AddDocEvent(ctx, tx, structs.DocEvent{
+		Type:  structs.EventDocUpdated,
+		DocID: doc.ID,
+		Owner: userLogin,
+		Name:  doc.Name,
+	}); err != nil {
+		return structs.RmDoc{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return structs.RmDoc{}, err
+	}
+
+	return doc, nil
+}
+
+// GetTrashByOwner returns all trashed docs of the owner, the most recently deleted first
+func (m *Repo) GetTrashByOwner(ctx context.Context, ownerLogin string) ([]structs.DocEntry, error) {
+	var docs []structs.DocEntry
+
+	err := m.db.Select(ctx, &docs,
+		`SELECT `+docColumns+`, deleted_at
+		FROM documents
+		WHERE owner=$1 and deleted_at IS NOT NULL ORDER BY deleted_at DESC;`, ownerLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// GetTrashedBefore returns docs which have been in the trash since before the given moment
+func (m *Repo) GetTrashedBefore(ctx context.Context, before time.Time) ([]structs.TrashedDoc, error) {
+	var docs []structs.TrashedDoc
+
+	err := m.db.Select(ctx, &docs,
+		`SELECT id, owner FROM documents
+				WHERE deleted_at IS NOT NULL and deleted_at < $1;`, before)
+	if err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// GetPendingDocs returns up to limit docs waiting for a malware scan, the oldest first
+func (m *Repo) GetPendingDocs(ctx context.Context, limit int) ([]structs.PendingDoc, error) {
+	var docs []structs.PendingDoc
+
+	err := m.db.Select(ctx, &docs,
+		`SELECT id, owner FROM documents
+				WHERE scan_status = 'pending' ORDER BY created_at limit $1;`, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// SetScanStatus sets malware scan status of the doc.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) SetScanStatus(ctx context.Context, docID string, status string) error {
+	res, err := m.db.Exec(ctx,
+		`UPDATE documents SET scan_status = $1 WHERE id = $2;`, status, docID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+// GetDocKey returns the wrapped data key of the doc. Trashed docs are included.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) GetDocKey(ctx context.Context, docID string) (*structs.DocKey, error) {
+	key := structs.DocKey{}
+
+	err := m.db.Get(ctx, &key,
+		`SELECT id, key_id, wrapped_key FROM documents WHERE id=$1;`, docID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return nil, repository.ErrObjectNotFound
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// GetDocKeysNotWrappedBy returns up to limit data keys wrapped by master keys other than keyID
+func (m *Repo) GetDocKeysNotWrappedBy(ctx context.Context, keyID string, limit int) ([]structs.DocKey, error) {
+	var keys []structs.DocKey
+
+	err := m.db.Select(ctx, &keys,
+		`SELECT id, key_id, wrapped_key FROM documents
+				WHERE key_id IS NOT NULL and key_id != $1 limit $2;`, keyID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// UpdateDocKey replaces the wrapped data key if it's still wrapped by oldKeyID.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) UpdateDocKey(ctx context.Context, docID string, oldKeyID string, keyID string, wrapped []byte) error {
+	res, err := m.db.Exec(ctx,
+		`UPDATE documents SET key_id = $1, wrapped_key = $2
+				WHERE id = $3 and key_id = $4;`, keyID, wrapped, docID, oldKeyID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+// MoveDoc puts the doc of owner into the folder, which must belong to owner too. Empty folderID means the root.
+// Grants of the folder apply to the doc then, so doc.updated carries the new logins.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) MoveDoc(ctx context.Context, docID string, owner string, folderID string) error {
+	return m.updateDoc(ctx, docID, owner,
+		`UPDATE documents SET folder_id = NULLIF($1, '')::uuid
+				WHERE id = $2 and owner = $3 and deleted_at IS NULL
+				  and ($1 = '' or EXISTS(SELECT 1 FROM folders WHERE id = NULLIF($1, '')::uuid and owner = $3))
+				returning title;`, folderID, docID, owner)
+}
+
+// updateDoc runs query updating the doc of owner and returning its title, then writes doc.updated to the outbox.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) updateDoc(ctx context.Context, docID string, owner string, query string, args ...interface{}) error {
+	title := ""
+
+	tx, err := m.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.GetContext(ctx, &title, query, args...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return repository.ErrObjectNotFound
+		}
+		return err
+	}
+	if err := AddDocEvent(ctx, tx, structs.DocEvent{
+		Type:  structs.EventDocUpdated,
+		DocID: docID,
+		Owner: owner,
+		Name:  title,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateDoc changes title, visibility and mime of the doc. Nil fields are kept.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) UpdateDoc(ctx context.Context, docID string, owner string, upd structs.DocUpdate) error {
+	return m.updateDoc(ctx, docID, owner,
+		`UPDATE documents SET title = COALESCE($1, title), is_public = COALESCE($2, is_public), mime = COALESCE($3, mime)
+				WHERE id = $4 and owner = $5 and deleted_at IS NULL returning title;`, upd.Name, upd.Public, upd.Mime, docID, owner)
+}
+
+// marshalLabels turns tags and metadata into json accepted by the queries. nil becomes empty
+func marshalLabels(tags []string, metadata map[string]string) (string, string, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	tagsJSON, err := jsoniter.MarshalToString(tags)
+	if err != nil {
+		return "", "", err
+	}
+	metadataJSON, err := jsoniter.MarshalToString(metadata)
+	if err != nil {
+		return "", "", err
+	}
+	return tagsJSON, metadataJSON, nil
+}
+
+// SetDocTags replaces tags of the doc of owner.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) SetDocTags(ctx context.Context, docID string, owner string, tags []string) error {
+	tagsJSON, _, err := marshalLabels(tags, nil)
+	if err != nil {
+		return err
+	}
+
+	return m.updateDoc(ctx, docID, owner,
+		`UPDATE documents SET tags = ARRAY(SELECT jsonb_array_elements_text($1::jsonb))
+				WHERE id = $2 and owner = $3 and deleted_at IS NULL returning title;`, tagsJSON, docID, owner)
+}
+
+// SetDocMetadata replaces metadata of the doc of owner.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) SetDocMetadata(ctx context.Context, docID string, owner string, metadata map[string]string) error {
+	_, metadataJSON, err := marshalLabels(nil, metadata)
+	if err != nil {
+		return err
+	}
+
+	return m.updateDoc(ctx, docID, owner,
+		`UPDATE documents SET metadata = $1::jsonb
+				WHERE id = $2 and owner = $3 and deleted_at IS NULL returning title;`, metadataJSON, docID, owner)
+}
+
+// GetTagsByOwner returns up to limit tags of owner starting with prefix, the most used first
+func (m *Repo) GetTagsByOwner(ctx context.Context, owner string, prefix string, limit int) ([]structs.TagCount, error) {
+	var tags []structs.TagCount
+
+	err := m.db.Select(ctx, &tags,
+		`SELECT tag, count(*) AS count
+				FROM documents, unnest(tags) AS tag
+				WHERE owner = $1 and deleted_at IS NULL and starts_with(lower(tag), lower($2))
+				GROUP BY tag ORDER BY count DESC, tag limit $3;`, owner, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,316 @@
+package folders
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage/db"
+	"github.com/Kapeland/task-Astral/internal/storage/repository"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/files"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"log/slog"
+	"time"
+)
+
+type Repo struct {
+	db db.DBops
+}
+
+func New(db db.DBops) *Repo {
+	return &Repo{db: db}
+}
+
+const folderColumns = `id, owner, COALESCE(parent_id::text, '') AS parent_id, name, created_at`
+
+// subtreeCTE selects the folder $1 and all folders inside it
+const subtreeCTE = `WITH RECURSIVE subtree AS (
+		SELECT id FROM folders WHERE id = $1
+		UNION
+		SELECT f.id FROM folders f JOIN subtree s ON f.parent_id = s.id
+	)`
+
+// ancestorsCTE selects the folder $1 and all folders it's inside of. depth is 0 for the folder itself
+const ancestorsCTE = `WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id, 0 AS depth FROM folders WHERE id = $1
+		UNION
+		SELECT f.id, f.parent_id, a.depth + 1 FROM folders f JOIN ancestors a ON f.id = a.parent_id
+	)`
+
+// subtreeDoc is a live document inside the subtree of a folder, the subject of an event
+type subtreeDoc struct {
+	ID    string `db:"id"`
+	Title string `db:"title"`
+	Owner string `db:"owner"`
+}
+
+// addSubtreeEvents writes an event of eventType for each of docs within tx
+func addSubtreeEvents(ctx context.Context, tx *db.Tx, eventType string, docs []subtreeDoc) error {
+	for _, doc := range docs {
+		if err := files.AddDocEvent(ctx, tx, structs.DocEvent{
+			Type:  eventType,
+			DocID: doc.ID,
+			Owner: doc.Owner,
+			Name:  doc.Title,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isPgErr(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
+
+// CreateFolder saves a new folder. The parent must belong to the same owner.
+// Returns repository.ErrObjectNotFound or repository.ErrDuplicateKey or err
+func (m *Repo) CreateFolder(ctx context.Context, folder *structs.Folder) error {
+	res, err := m.db.Exec(ctx,
+		`INSERT INTO folders(id, owner, parent_id, name, created_at)
+				SELECT $1, $2, NULLIF($3, '')::uuid, $4, $5
+				WHERE $3 = '' OR EXISTS(SELECT 1 FROM folders WHERE id = NULLIF($3, '')::uuid and owner = $2);`,
+		folder.ID, folder.Owner, folder.ParentID, folder.Name, folder.CreatedAt)
+	if err != nil {
+		if isPgErr(err, "23505") {
+			return repository.ErrDuplicateKey
+		}
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+// GetFolder returns the folder regardless of its owner.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) GetFolder(ctx context.Context, folderID string) (*structs.Folder, error) {
+	folder := structs.Folder{}
+
+	err := m.db.Get(ctx, &folder,
+		`SELECT `+folderColumns+` FROM folders WHERE id = $1;`, folderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return nil, repository.ErrObjectNotFound
+		}
+		return nil, err
+	}
+
+	return &folder, nil
+}
+
+// GetSubfolders returns folders of owner directly inside the parent. Empty parentID means the root.
+func (m *Repo) GetSubfolders(ctx context.Context, parentID string, owner string) ([]structs.Folder, error) {
+	var folders []structs.Folder
+
+	err := m.db.Select(ctx, &folders,
+		`SELECT `+folderColumns+` FROM folders
+				WHERE owner = $2 and (($1 = '' and parent_id IS NULL) or parent_id = NULLIF($1, '')::uuid) ORDER BY name;`, parentID, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return folders, nil
+}
+
+// GetBreadcrumbs returns the path to the folder starting from the root folder
+func (m *Repo) GetBreadcrumbs(ctx context.Context, folderID string) ([]structs.Folder, error) {
+	var folders []structs.Folder
+
+	err := m.db.Select(ctx, &folders,
+		ancestorsCTE+`
+		SELECT f.id, f.owner, COALESCE(f.parent_id::text, '') AS parent_id, f.name, f.created_at
+				FROM folders f JOIN ancestors a ON f.id = a.id ORDER BY a.depth DESC;`, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return folders, nil
+}
+
+// GetFolderGrants returns logins granted access to the folder directly or through the folders it's inside of
+func (m *Repo) GetFolderGrants(ctx context.Context, folderID string) ([]string, error) {
+	var logins []string
+
+	err := m.db.Select(ctx, &logins,
+		ancestorsCTE+`
+		SELECT DISTINCT fa.login FROM folder_access fa JOIN ancestors a ON fa.folder_id = a.id;`, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return logins, nil
+}
+
+// UpdateFolder renames the folder of owner and/or puts it inside parentID in one transaction. Nil fields are kept.
+// The new parent must belong to owner too, empty parentID means the root.
+// The folder can't be moved inside itself or its subfolders.
+// Returns repository.ErrObjectNotFound or repository.ErrDuplicateKey or err
+func (m *Repo) UpdateFolder(ctx context.Context, folderID string, owner string, name *string, parentID *string) error {
+	tx, err := m.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if name != nil {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE folders SET name = $1 WHERE id = $2 and owner = $3;`, *name, folderID, owner)
+		if err := checkUpdated(res, err); err != nil {
+			return err
+		}
+	}
+	if parentID != nil {
+		res, err := tx.ExecContext(ctx,
+			subtreeCTE+`
+		UPDATE folders SET parent_id = NULLIF($2, '')::uuid
+				WHERE id = $1 and owner = $3
+				  and ($2 = '' or EXISTS(SELECT 1 FROM folders WHERE id = NULLIF($2, '')::uuid and owner = $3))
+				  and NOT EXISTS(SELECT 1 FROM subtree WHERE id = NULLIF($2, '')::uuid);`, folderID, *parentID, owner)
+		if err := checkUpdated(res, err); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.Info("Looks like the context has been closed")
+		slog.Error(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// checkUpdated maps the result of an UPDATE of one folder.
+// Returns repository.ErrObjectNotFound if no row is updated, repository.ErrDuplicateKey or err
+func checkUpdated(res sql.Result, err error) error {
+	if err != nil {
+		if isPgErr(err, "23505") {
+			return repository.ErrDuplicateKey
+		}
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+// DeleteFolder deletes the folder of owner with all its subfolders. Documents inside are moved to the trash,
+// each gets doc.deleted in the outbox.
+// Returns the number of trashed documents, repository.ErrObjectNotFound or err
+func (m *Repo) DeleteFolder(ctx context.Context, folderID string, owner string) (int, error) {
+	tx, err := m.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	exists := false
+	err = tx.GetContext(ctx, &exists,
+		`SELECT EXISTS(SELECT 1 FROM folders WHERE id = $1 and owner = $2);`, folderID, owner)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, repository.ErrObjectNotFound
+	}
+
+	var trashed []subtreeDoc
+	err = tx.SelectContext(ctx, &trashed,
+		subtreeCTE+`
+		UPDATE documents SET deleted_at = $2
+				WHERE folder_id IN (SELECT id FROM subtree) and deleted_at IS NULL
+				RETURNING id, title, owner;`, folderID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	// Grants of the folders are read by the events, so they are written before the folders are deleted
+	if err := addSubtreeEvents(ctx, tx, structs.EventDocDeleted, trashed); err != nil {
+		return 0, err
+	}
+
+	// Subfolders go with the folder, documents fall out to the root
+	_, err = tx.ExecContext(ctx, `DELETE FROM folders WHERE id = $1;`, folderID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.Info("Looks like the context has been closed")
+		slog.Error(err.Error())
+		return 0, err
+	}
+
+	return len(trashed), nil
+}
+
+// SetFolderGrants replaces logins granted access to the folder of owner. If there are any,
+// every document inside the folder and its subfolders gets doc.shared in the outbox.
+// Returns repository.ErrObjectNotFound or repository.ErrAddGrantToLogin or err
+func (m *Repo) SetFolderGrants(ctx context.Context, folderID string, owner string, logins []string) error {
+	tx, err := m.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exists := false
+	err = tx.GetContext(ctx, &exists,
+		`SELECT EXISTS(SELECT 1 FROM folders WHERE id = $1 and owner = $2);`, folderID, owner)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return repository.ErrObjectNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM folder_access WHERE folder_id = $1;`, folderID)
+	if err != nil {
+		return err
+	}
+	for _, login := range logins {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO folder_access(folder_id, login) VALUES($1, $2) ON CONFLICT DO NOTHING;`, folderID, login)
+		if err != nil {
+			if isPgErr(err, "23503") {
+				return repository.ErrAddGrantToLogin
+			}
+			return err
+		}
+	}
+
+	if len(logins) != 0 {
+		var shared []subtreeDoc
+		err = tx.SelectContext(ctx, &shared,
+			subtreeCTE+`
+		SELECT id, title, owner FROM documents WHERE folder_id IN (SELECT id FROM subtree) and deleted_at IS NULL;`, folderID)
+		if err != nil {
+			return err
+		}
+		if err := addSubtreeEvents(ctx, tx, structs.EventDocShared, shared); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.Info("Looks like the context has been closed")
+		slog.Error(err.Error())
+		return err
+	}
+
+	return nil
+}
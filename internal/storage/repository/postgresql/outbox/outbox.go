@@ -0,0 +1,101 @@
+package outbox
+
+import (
+	"context"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage/db"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	jsoniter "github.com/json-iterator/go"
+	"time"
+)
+
+type Repo struct {
+	db db.DBops
+}
+
+func New(db db.DBops) *Repo {
+	return &Repo{db: db}
+}
+
+// Row is an event stored in the outbox
+type Row struct {
+	ID       int64  `db:"id"`
+	Attempts int    `db:"attempts"`
+	Payload  string `db:"payload"`
+}
+
+// Add writes the event within tx, so it's relayed only if the change it describes is committed.
+// ID and Created of the event are set here
+func Add(ctx context.Context, tx sqlx.ExecerContext, event structs.DocEvent) error {
+	event.ID = uuid.NewString()
+	event.Created = time.Now()
+	payload, err := jsoniter.MarshalToString(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO outbox(event_id, event, payload, next_attempt_at, created_at) VALUES($1, $2, $3, $4, $4);`,
+		event.ID, event.Type, payload, event.Created)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ClaimDueEvents returns up to limit unpublished events due at now, the oldest first,
+// and postpones them till leaseUntil, so other instances don't relay them at the same time.
+func (m *Repo) ClaimDueEvents(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]Row, error) {
+	var rows []Row
+
+	err := m.db.Select(ctx, &rows,
+		`UPDATE outbox SET next_attempt_at = $2
+				WHERE id IN (
+					SELECT id FROM outbox WHERE published_at IS NULL and next_attempt_at <= $1
+					ORDER BY id limit $3 FOR UPDATE SKIP LOCKED)
+				RETURNING id, attempts, payload;`, now, leaseUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// MarkPublished records that the event reached all sinks
+func (m *Repo) MarkPublished(ctx context.Context, id int64, now time.Time) error {
+	_, err := m.db.Exec(ctx,
+		`UPDATE outbox SET published_at = $2, attempts = attempts + 1, last_error = '' WHERE id = $1;`, id, now)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// MarkFailed records a failed attempt and schedules the next one
+func (m *Repo) MarkFailed(ctx context.Context, id int64, lastErr string, nextAttempt time.Time) error {
+	_, err := m.db.Exec(ctx,
+		`UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1;`,
+		id, lastErr, nextAttempt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeletePublishedBefore deletes events published before the time. Returns the number of deleted ones
+func (m *Repo) DeletePublishedBefore(ctx context.Context, before time.Time) (int, error) {
+	res, err := m.db.Exec(ctx, `DELETE FROM outbox WHERE published_at < $1;`, before)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rows), nil
+}
@@ -0,0 +1,144 @@
+package shares
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage/db"
+	"github.com/Kapeland/task-Astral/internal/storage/repository"
+	"github.com/Kapeland/task-Astral/internal/storage/repository/postgresql/outbox"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type Repo struct {
+	db db.DBops
+}
+
+func New(db db.DBops) *Repo {
+	return &Repo{db: db}
+}
+
+const shareColumns = `slug, document_id, owner, password_hash IS NOT NULL AS has_password, expires_at, max_downloads, downloads, created_at`
+
+func isPgErr(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
+
+// CreateShareLink saves a new link and writes doc.shared to the outbox.
+// The document must belong to the owner of the link and not be in the trash.
+// Returns repository.ErrObjectNotFound or repository.ErrDuplicateKey or err
+func (m *Repo) CreateShareLink(ctx context.Context, link *structs.ShareLink) error {
+	tx, err := m.db.(*db.PgDatabase).BeginX(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO share_links(slug, document_id, owner, password_hash, expires_at, max_downloads, created_at)
+				SELECT $1, id, owner, CASE WHEN $3 = '' THEN NULL ELSE crypt($3, gen_salt('bf')) END, $4, $5, $6
+				FROM documents WHERE id = $2 and owner = $7 and deleted_at IS NULL;`,
+		link.Slug, link.DocID, link.Password, link.ExpiresAt, link.MaxDownloads, link.CreatedAt, link.Owner)
+	if err != nil {
+		if isPgErr(err, "23505") {
+			return repository.ErrDuplicateKey
+		}
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrObjectNotFound
+	}
+	if err := outbox.Add(ctx, tx, structs.DocEvent{
+		Type:  structs.EventDocShared,
+		DocID: link.DocID,
+		Owner: link.Owner,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetShareLinksByDoc returns links of the doc made by owner, the newest first
+func (m *Repo) GetShareLinksByDoc(ctx context.Context, docID string, owner string) ([]structs.ShareLink, error) {
+	var links []structs.ShareLink
+
+	err := m.db.Select(ctx, &links,
+		`SELECT `+shareColumns+` FROM share_links
+				WHERE document_id = $1 and owner = $2 ORDER BY created_at DESC;`, docID, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// GetShareLink returns the link and whether password matches it. Links without password match any.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) GetShareLink(ctx context.Context, slug string, password string) (*structs.ShareLink, bool, error) {
+	link := struct {
+		structs.ShareLink
+		PasswordOK bool `db:"password_ok"`
+	}{}
+
+	err := m.db.Get(ctx, &link,
+		`SELECT `+shareColumns+`, (password_hash IS NULL OR password_hash = crypt($2, password_hash)) AS password_ok
+				FROM share_links WHERE slug = $1;`, slug, password)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, repository.ErrObjectNotFound
+		}
+		return nil, false, err
+	}
+
+	return &link.ShareLink, link.PasswordOK, nil
+}
+
+// CountShareDownload counts a download if the link is still usable at now.
+// Concurrent downloads can't exceed the limit.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) CountShareDownload(ctx context.Context, slug string, now time.Time) error {
+	res, err := m.db.Exec(ctx,
+		`UPDATE share_links SET downloads = downloads + 1
+				WHERE slug = $1 and (expires_at IS NULL or expires_at > $2)
+				  and (max_downloads IS NULL or downloads < max_downloads);`, slug, now)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+// DeleteShareLink revokes the link of the doc.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) DeleteShareLink(ctx context.Context, slug string, docID string, owner string) error {
+	res, err := m.db.Exec(ctx,
+		`DELETE FROM share_links WHERE slug = $1 and document_id = $2 and owner = $3;`, slug, docID, owner)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package uploads
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage/db"
+	"github.com/Kapeland/task-Astral/internal/storage/repository"
+	"github.com/jackc/pgx/v5"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type Repo struct {
+	db db.DBops
+}
+
+func New(db db.DBops) *Repo {
+	return &Repo{db: db}
+}
+
+// uploadRow keeps the doc meta as json
+type uploadRow struct {
+	structs.Upload
+	Meta string `db:"meta"`
+}
+
+func (r uploadRow) toUpload() (structs.Upload, error) {
+	up := r.Upload
+	if err := jsoniter.Unmarshal([]byte(r.Meta), &up.Meta); err != nil {
+		return structs.Upload{}, err
+	}
+	return up, nil
+}
+
+// CreateUpload saves a new upload
+func (m *Repo) CreateUpload(ctx context.Context, up *structs.Upload) error {
+	meta, err := jsoniter.Marshal(up.Meta)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.Exec(ctx,
+		`INSERT INTO uploads(id, owner, meta, content, upload_length, upload_offset, created_at, expires_at)
+				VALUES($1,$2,$3,$4,$5,$6,$7,$8);`,
+		up.ID, up.Owner, string(meta), string(up.Json), up.Length, up.Offset, up.CreatedAt, up.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetUpload returns the upload belonging to owner.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) GetUpload(ctx context.Context, uploadID string, owner string) (*structs.Upload, error) {
+	row := uploadRow{}
+
+	err := m.db.Get(ctx, &row,
+		`SELECT id, owner, meta, content, upload_length, upload_offset, created_at, expires_at
+				FROM uploads WHERE id=$1 and owner=$2;`, uploadID, owner)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return nil, repository.ErrObjectNotFound
+		}
+		return nil, err
+	}
+
+	up, err := row.toUpload()
+	if err != nil {
+		return nil, err
+	}
+	return &up, nil
+}
+
+// SetUploadOffset saves the number of received bytes and extends the expiry of the upload.
+// It's saved only if from is still the offset of the upload and the upload isn't being finished.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) SetUploadOffset(ctx context.Context, uploadID string, from int64, to int64, expiresAt time.Time) error {
+	res, err := m.db.Exec(ctx,
+		`UPDATE uploads SET upload_offset = $1, expires_at = $2
+				WHERE id = $3 AND upload_offset = $4 AND finishing_at IS NULL;`, to, expiresAt, uploadID, from)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+// StartFinishing marks the complete upload belonging to owner as being finished.
+// Returns repository.ErrObjectNotFound if there is no such complete upload or it's being finished already, or err
+func (m *Repo) StartFinishing(ctx context.Context, uploadID string, owner string) error {
+	res, err := m.db.Exec(ctx,
+		`UPDATE uploads SET finishing_at = NOW()
+				WHERE id = $1 AND owner = $2 AND upload_offset = upload_length AND finishing_at IS NULL;`,
+		uploadID, owner)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+// StopFinishing lets the upload be finished again
+func (m *Repo) StopFinishing(ctx context.Context, uploadID string, owner string) error {
+	_, err := m.db.Exec(ctx,
+		`UPDATE uploads SET finishing_at = NULL WHERE id = $1 AND owner = $2;`, uploadID, owner)
+	return err
+}
+
+// DelUpload deletes the upload belonging to owner.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) DelUpload(ctx context.Context, uploadID string, owner string) error {
+	res, err := m.db.Exec(ctx,
+		`DELETE FROM uploads WHERE id = $1 and owner = $2;`, uploadID, owner)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+// GetExpiredUploads returns uploads abandoned before the given moment
+func (m *Repo) GetExpiredUploads(ctx context.Context, before time.Time) ([]structs.Upload, error) {
+	var rows []uploadRow
+
+	err := m.db.Select(ctx, &rows,
+		`SELECT id, owner, meta, content, upload_length, upload_offset, created_at, expires_at
+				FROM uploads WHERE expires_at < $1;`, before)
+	if err != nil {
+		return nil, err
+	}
+
+	ups := make([]structs.Upload, 0, len(rows))
+	for _, row := range rows {
+		up, err := row.toUpload()
+		if err != nil {
+			return nil, err
+		}
+		ups = append(ups, up)
+	}
+	return ups, nil
+}
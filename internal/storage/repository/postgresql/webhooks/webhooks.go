@@ -0,0 +1,173 @@
+package webhooks
+
+import (
+	"context"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage/db"
+	"github.com/Kapeland/task-Astral/internal/storage/repository"
+	jsoniter "github.com/json-iterator/go"
+	"time"
+)
+
+type Repo struct {
+	db db.DBops
+}
+
+func New(db db.DBops) *Repo {
+	return &Repo{db: db}
+}
+
+const webhookColumns = `id, owner, url, secret, array_to_json(events)::text AS events, created_at`
+
+const deliveryColumns = `d.id, d.webhook_id, d.event_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+		d.response_code, d.last_error, d.created_at, d.delivered_at`
+
+// CreateWebhook saves a new webhook
+func (m *Repo) CreateWebhook(ctx context.Context, webhook *structs.Webhook) error {
+	events, err := jsoniter.MarshalToString(append([]string{}, webhook.Events...))
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.Exec(ctx,
+		`INSERT INTO webhooks(id, owner, url, secret, events, created_at)
+				VALUES($1, $2, $3, $4, ARRAY(SELECT jsonb_array_elements_text($5::jsonb)), $6);`,
+		webhook.ID, webhook.Owner, webhook.URL, webhook.Secret, events, webhook.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Repo) GetWebhooksByOwner(ctx context.Context, owner string) ([]structs.Webhook, error) {
+	var webhooks []structs.Webhook
+
+	err := m.db.Select(ctx, &webhooks,
+		`SELECT `+webhookColumns+` FROM webhooks WHERE owner = $1 ORDER BY created_at;`, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes the webhook with its deliveries.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) DeleteWebhook(ctx context.Context, webhookID string, owner string) error {
+	res, err := m.db.Exec(ctx,
+		`DELETE FROM webhooks WHERE id = $1 and owner = $2;`, webhookID, owner)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
+
+// EnqueueEvent adds a delivery of the event to every webhook of owner subscribed to it.
+// An event enqueued again doesn't make new deliveries. Returns the number of new deliveries
+func (m *Repo) EnqueueEvent(ctx context.Context, owner string, event string, eventID string, payload string, now time.Time) (int, error) {
+	res, err := m.db.Exec(ctx,
+		`INSERT INTO webhook_deliveries(webhook_id, event_id, event, payload, next_attempt_at, created_at)
+				SELECT id, $3, $2, $4, $5, $5 FROM webhooks
+				WHERE owner = $1 and (cardinality(events) = 0 or $2 = ANY(events))
+				ON CONFLICT (webhook_id, event_id) DO NOTHING;`,
+		owner, event, eventID, payload, now)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rows), nil
+}
+
+// ClaimDueDeliveries returns up to limit pending deliveries due at now and postpones them till leaseUntil,
+// so other instances don't send them at the same time.
+func (m *Repo) ClaimDueDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]structs.DueDelivery, error) {
+	var deliveries []structs.DueDelivery
+
+	err := m.db.Select(ctx, &deliveries,
+		`UPDATE webhook_deliveries d SET next_attempt_at = $2
+				FROM webhooks w
+				WHERE w.id = d.webhook_id and d.id IN (
+					SELECT id FROM webhook_deliveries WHERE status = 'pending' and next_attempt_at <= $1
+					ORDER BY next_attempt_at limit $3 FOR UPDATE SKIP LOCKED)
+				RETURNING `+deliveryColumns+`, w.url, w.secret;`, now, leaseUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// MarkDelivered records a successful attempt
+func (m *Repo) MarkDelivered(ctx context.Context, deliveryID int64, code int, now time.Time) error {
+	_, err := m.db.Exec(ctx,
+		`UPDATE webhook_deliveries SET status = 'delivered', attempts = attempts + 1, response_code = $2,
+				last_error = '', delivered_at = $3 WHERE id = $1;`, deliveryID, code, now)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// MarkFailed records a failed attempt. The delivery becomes dead if nextAttempt is nil
+func (m *Repo) MarkFailed(ctx context.Context, deliveryID int64, code int, lastErr string, nextAttempt *time.Time) error {
+	_, err := m.db.Exec(ctx,
+		`UPDATE webhook_deliveries SET attempts = attempts + 1, response_code = $2, last_error = $3,
+				status = CASE WHEN $4::timestamp IS NULL THEN 'dead' ELSE 'pending' END,
+				next_attempt_at = COALESCE($4, next_attempt_at) WHERE id = $1;`, deliveryID, code, lastErr, nextAttempt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetDeliveries returns deliveries of the webhook of owner, the newest first
+func (m *Repo) GetDeliveries(ctx context.Context, webhookID string, owner string, filter structs.DeliveryFilter) ([]structs.WebhookDelivery, error) {
+	var deliveries []structs.WebhookDelivery
+
+	err := m.db.Select(ctx, &deliveries,
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+				WHERE w.id = $1 and w.owner = $2 and ($3 = '' or d.status = $3) and ($4 = 0 or d.id < $4)
+				ORDER BY d.id DESC limit $5;`, webhookID, owner, filter.Status, filter.BeforeID, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// RetryDelivery brings the dead delivery back with fresh attempts.
+// Returns repository.ErrObjectNotFound or err
+func (m *Repo) RetryDelivery(ctx context.Context, deliveryID int64, webhookID string, owner string, now time.Time) error {
+	res, err := m.db.Exec(ctx,
+		`UPDATE webhook_deliveries d SET status = 'pending', attempts = 0, next_attempt_at = $4
+				FROM webhooks w
+				WHERE w.id = d.webhook_id and d.id = $1 and w.id = $2 and w.owner = $3 and d.status = 'dead';`,
+		deliveryID, webhookID, owner, now)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrObjectNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ScanActionReport     = "report"
+	ScanActionQuarantine = "quarantine"
+	ScanActionDelete     = "delete"
+)
+
+var ErrUnknownScanAction = errors.New("unknown scan action")
+
+// Scan compares stored blobs with document rows.
+// It reports blobs without rows, rows without blobs and, if asked, blobs which hash differs from the stored one.
+// Orphaned blobs are quarantined or deleted according to opts.
+func (m *FileStorage) Scan(ctx context.Context, opts structs.ScanOptions) (structs.ScanReport, error) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	switch opts.Action {
+	case ScanActionReport, ScanActionQuarantine, ScanActionDelete:
+	default:
+		return structs.ScanReport{}, ErrUnknownScanAction
+	}
+
+	// Blobs must be listed before rows: a blob is put in place only after its row is committed,
+	// so a concurrent upload can't be mistaken for an orphan.
+	keys, err := m.fp.GetAllKeys()
+	if err != nil {
+		return structs.ScanReport{}, err
+	}
+	docs, err := m.fr.GetAllDocBlobs(ctx)
+	if err != nil {
+		return structs.ScanReport{}, err
+	}
+
+	stored := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		stored[key] = struct{}{}
+	}
+	referenced := make(map[string]struct{}, len(docs))
+
+	report := structs.ScanReport{}
+	for _, doc := range docs {
+		key := doc.ID
+		referenced[key] = struct{}{}
+
+		if _, ok := stored[key]; !ok {
+			if doc.Scan == structs.ScanInfected { // Blobs of infected docs are quarantined
+				continue
+			}
+			report.MissingFiles = append(report.MissingFiles, doc)
+			continue
+		}
+		if !opts.VerifyHash || !doc.SHA256.Valid { // Docs uploaded before hashing was introduced can't be verified
+			continue
+		}
+		hash, err := m.hashDoc(ctx, doc.ID)
+		if err != nil {
+			if errors.Is(err, models.ErrNotFound) {
+				report.MissingFiles = append(report.MissingFiles, doc)
+				continue
+			}
+			return report, err
+		}
+		if hash != doc.SHA256.String {
+			report.HashMismatches = append(report.HashMismatches, doc)
+		}
+	}
+
+	for _, key := range keys {
+		if _, ok := referenced[key]; !ok {
+			report.OrphanFiles = append(report.OrphanFiles, key)
+		}
+	}
+
+	if opts.DryRun || opts.Action == ScanActionReport {
+		return report, nil
+	}
+
+	for _, key := range report.OrphanFiles {
+		switch opts.Action {
+		case ScanActionQuarantine:
+			err = m.fp.QuarantineFile(key)
+		case ScanActionDelete:
+			err = m.fp.RemoveFile(key)
+		}
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) { // Deleted in the meantime
+				continue
+			}
+			return report, err
+		}
+		report.Handled++
+		lgr.Info(fmt.Sprintf("%s orphaned blob %s", opts.Action, key), "FileStorage", "Scan", opts.Action)
+	}
+
+	return report, nil
+}
+
+// hashDoc returns hex encoded SHA-256 of the document content
+func (m *FileStorage) hashDoc(ctx context.Context, docID string) (string, error) {
+	data, err := m.OpenDoc(ctx, docID)
+	if err != nil {
+		return "", err
+	}
+	defer data.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"slices"
+	"testing"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/pkg/errors"
+)
+
+func sha256Hex(data string) sql.NullString {
+	sum := sha256.Sum256([]byte(data))
+	return sql.NullString{String: hex.EncodeToString(sum[:]), Valid: true}
+}
+
+// newScanFixture returns storage with a consistent doc, a doc with a damaged blob, a doc without a blob,
+// an infected doc which blob is quarantined, a doc stored before hashing and an orphaned blob
+func newScanFixture() (*FileStorage, *fakeProvider) {
+	repo := &fakeRepo{docs: []structs.DocBlob{
+		{ID: "ok", SHA256: sha256Hex("ok")},
+		{ID: "damaged", SHA256: sha256Hex("original")},
+		{ID: "missing", SHA256: sha256Hex("missing")},
+		{ID: "infected", Scan: structs.ScanInfected},
+		{ID: "legacy"},
+	}}
+	fp := newFakeProvider(map[string][]byte{
+		"ok":      []byte("ok"),
+		"damaged": []byte("changed"),
+		"legacy":  []byte("legacy"),
+		"orphan":  []byte("orphan"),
+	})
+	store := NewFileStorage(repo, fp, nil)
+	return &store, fp
+}
+
+func blobIDs(docs []structs.DocBlob) []string {
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	return ids
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name           string
+		opts           structs.ScanOptions
+		wantMismatches []string
+		wantHandled    int
+		wantBlobs      int
+		wantQuarantine int
+	}{
+		{"report", structs.ScanOptions{Action: ScanActionReport}, nil, 0, 4, 0},
+		{"verify hash", structs.ScanOptions{Action: ScanActionReport, VerifyHash: true}, []string{"damaged"}, 0, 4, 0},
+		{"quarantine", structs.ScanOptions{Action: ScanActionQuarantine}, nil, 1, 3, 1},
+		{"delete", structs.ScanOptions{Action: ScanActionDelete}, nil, 1, 3, 0},
+		{"dry run", structs.ScanOptions{Action: ScanActionDelete, DryRun: true}, nil, 0, 4, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, fp := newScanFixture()
+
+			report, err := store.Scan(context.Background(), tt.opts)
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+
+			if !slices.Equal(report.OrphanFiles, []string{"orphan"}) {
+				t.Errorf("OrphanFiles = %v; want [orphan]", report.OrphanFiles)
+			}
+			if got := blobIDs(report.MissingFiles); !slices.Equal(got, []string{"missing"}) {
+				t.Errorf("MissingFiles = %v; want [missing]", got)
+			}
+			if got := blobIDs(report.HashMismatches); !slices.Equal(got, tt.wantMismatches) {
+				t.Errorf("HashMismatches = %v; want %v", got, tt.wantMismatches)
+			}
+			if report.Handled != tt.wantHandled {
+				t.Errorf("Handled = %d; want %d", report.Handled, tt.wantHandled)
+			}
+			if len(fp.blobs) != tt.wantBlobs {
+				t.Errorf("%d blobs left; want %d", len(fp.blobs), tt.wantBlobs)
+			}
+			if len(fp.quarantined) != tt.wantQuarantine {
+				t.Errorf("%d blobs quarantined; want %d", len(fp.quarantined), tt.wantQuarantine)
+			}
+		})
+	}
+}
+
+func TestScanUnknownAction(t *testing.T) {
+	store, fp := newScanFixture()
+
+	_, err := store.Scan(context.Background(), structs.ScanOptions{Action: "shred"})
+	if !errors.Is(err, ErrUnknownScanAction) {
+		t.Fatalf("Scan error = %v; want ErrUnknownScanAction", err)
+	}
+	if len(fp.blobs) != 4 {
+		t.Errorf("%d blobs left; want 4", len(fp.blobs))
+	}
+}
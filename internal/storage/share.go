@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage/repository"
+
+	"github.com/pkg/errors"
+)
+
+type ShareRepo interface {
+	CreateShareLink(ctx context.Context, link *structs.ShareLink) error
+	GetShareLinksByDoc(ctx context.Context, docID string, owner string) ([]structs.ShareLink, error)
+	GetShareLink(ctx context.Context, slug string, password string) (*structs.ShareLink, bool, error)
+	CountShareDownload(ctx context.Context, slug string, now time.Time) error
+	DeleteShareLink(ctx context.Context, slug string, docID string, owner string) error
+}
+
+type ShareStorage struct {
+	sr ShareRepo
+}
+
+func NewShareStorage(sr ShareRepo) ShareStorage {
+	return ShareStorage{sr: sr}
+}
+
+// slugSize is the number of random bytes in a slug. It's long enough not to be guessed
+const slugSize = 16
+
+// mapShareErr maps repository errors to model ones
+func mapShareErr(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrObjectNotFound):
+		return models.ErrNotFound
+	case errors.Is(err, repository.ErrDuplicateKey):
+		return models.ErrConflict
+	default:
+		return err
+	}
+}
+
+// CreateShareLink makes a link with a random slug to the doc of the owner.
+// Returns models.ErrNotFound or err
+func (s *ShareStorage) CreateShareLink(ctx context.Context, link structs.ShareLink) (structs.ShareLink, error) {
+	b := make([]byte, slugSize)
+	if _, err := rand.Read(b); err != nil {
+		return structs.ShareLink{}, err
+	}
+	link.Slug = base64.RawURLEncoding.EncodeToString(b)
+	link.CreatedAt = time.Now()
+	link.HasPassword = link.Password != ""
+
+	if err := s.sr.CreateShareLink(ctx, &link); err != nil {
+		return structs.ShareLink{}, mapShareErr(err)
+	}
+	link.Password = ""
+	return link, nil
+}
+
+func (s *ShareStorage) GetShareLinks(ctx context.Context, docID string, owner string) ([]structs.ShareLink, error) {
+	return s.sr.GetShareLinksByDoc(ctx, docID, owner)
+}
+
+// GetShareLink returns the link and whether password matches it.
+// Returns models.ErrNotFound or err
+func (s *ShareStorage) GetShareLink(ctx context.Context, slug string, password string) (structs.ShareLink, bool, error) {
+	link, ok, err := s.sr.GetShareLink(ctx, slug, password)
+	if err != nil {
+		return structs.ShareLink{}, false, mapShareErr(err)
+	}
+	return *link, ok, nil
+}
+
+// CountShareDownload fails with models.ErrNotFound if the link has expired or has no downloads left.
+// Returns models.ErrNotFound or err
+func (s *ShareStorage) CountShareDownload(ctx context.Context, slug string) error {
+	return mapShareErr(s.sr.CountShareDownload(ctx, slug, time.Now()))
+}
+
+// Returns models.ErrNotFound or err
+func (s *ShareStorage) DeleteShareLink(ctx context.Context, slug string, docID string, owner string) error {
+	return mapShareErr(s.sr.DeleteShareLink(ctx, slug, docID, owner))
+}
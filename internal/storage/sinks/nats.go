@@ -0,0 +1,131 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var ErrNATSRejected = errors.New("nats server rejected the message")
+
+// NATS publishes events to subject.<event type> speaking the core NATS text protocol,
+// so a local nats-server or any compatible stand-in works without a client library.
+// Every publish is confirmed by PING/PONG, so the server has got the message when Publish returns.
+type NATS struct {
+	address string
+	subject string
+	timeout time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func NewNATS(address string, subject string, timeout time.Duration) *NATS {
+	return &NATS{address: address, subject: subject, timeout: timeout}
+}
+
+// connect dials the server and introduces the client. Must be called with mu held
+func (n *NATS) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: n.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", n.address)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(n.timeout))
+	r := bufio.NewReader(conn)
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("unexpected nats greeting: %q", strings.TrimSpace(line))
+	}
+	if _, err := conn.Write([]byte("CONNECT {\"verbose\":false,\"pedantic\":false,\"name\":\"task-astral\"}\r\n")); err != nil {
+		conn.Close()
+		return err
+	}
+
+	n.conn, n.r = conn, r
+	return nil
+}
+
+// Publish sends the event and waits till the server confirms it. The connection is dropped on any error
+func (n *NATS) Publish(ctx context.Context, event structs.DocEvent) error {
+	payload, err := jsoniter.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn == nil {
+		if err := n.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if err := n.publish(ctx, n.subject+"."+event.Type, payload); err != nil {
+		n.conn.Close()
+		n.conn, n.r = nil, nil
+		return err
+	}
+	return nil
+}
+
+func (n *NATS) publish(ctx context.Context, subject string, payload []byte) error {
+	deadline := time.Now().Add(n.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = n.conn.SetDeadline(deadline)
+
+	msg := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", subject, len(payload), payload)
+	if _, err := n.conn.Write([]byte(msg)); err != nil {
+		return err
+	}
+
+	for {
+		line, err := n.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING": // Server checks the client is alive
+			if _, err := n.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("%w: %s", ErrNATSRejected, line)
+		}
+		// +OK and INFO updates are skipped
+	}
+}
+
+// Close closes the connection to the server
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn == nil {
+		return nil
+	}
+	err := n.conn.Close()
+	n.conn, n.r = nil, nil
+	return err
+}
@@ -0,0 +1,215 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// natsMsg is a message published to the fake server
+type natsMsg struct {
+	subject string
+	payload []byte
+}
+
+// fakeNATS speaks the core NATS text protocol like nats-server. It rejects subjects in reject with -ERR,
+// pings the client before confirming a message if ping is set and drops the connection
+// after confirming a message if drop is set.
+type fakeNATS struct {
+	reject    string
+	ping      bool
+	drop      bool
+	connects  atomic.Int32
+	published chan natsMsg
+}
+
+func startFakeNATS(t *testing.T, s *fakeNATS) *NATS {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s.published = make(chan natsMsg, 10)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	n := NewNATS(l.Addr().String(), "docs", time.Second)
+	t.Cleanup(func() { n.Close() })
+	return n
+}
+
+func (s *fakeNATS) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	if _, err := io.WriteString(conn, "INFO {\"server_id\":\"fake\",\"max_payload\":1048576}\r\n"); err != nil {
+		return
+	}
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "CONNECT {") {
+		return
+	}
+	s.connects.Add(1)
+
+	var msg natsMsg
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 3 && fields[0] == "PUB":
+			size, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return
+			}
+			payload := make([]byte, size+2) // With \r\n
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			msg = natsMsg{subject: fields[1], payload: payload[:size]}
+		case len(fields) == 1 && fields[0] == "PING":
+			if msg.subject == s.reject {
+				io.WriteString(conn, "-ERR 'Permissions Violation for Publish to "+msg.subject+"'\r\n")
+				return
+			}
+			if s.ping {
+				io.WriteString(conn, "PING\r\n")
+				if pong, err := r.ReadString('\n'); err != nil || pong != "PONG\r\n" {
+					return
+				}
+			}
+			s.published <- msg
+			io.WriteString(conn, "PONG\r\n")
+			if s.drop {
+				return
+			}
+		default:
+			io.WriteString(conn, "-ERR 'Unknown Protocol Operation'\r\n")
+			return
+		}
+	}
+}
+
+func TestNATSPublish(t *testing.T) {
+	s := &fakeNATS{ping: true}
+	n := startFakeNATS(t, s)
+	event := structs.DocEvent{ID: "e1", Type: structs.EventDocCreated, DocID: "d1", Owner: "alice"}
+
+	for range 2 {
+		if err := n.Publish(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+		msg := <-s.published
+		if msg.subject != "docs."+structs.EventDocCreated {
+			t.Errorf("subject = %q; want docs.%s", msg.subject, structs.EventDocCreated)
+		}
+		var got structs.DocEvent
+		if err := jsoniter.Unmarshal(msg.payload, &got); err != nil || got.ID != event.ID || got.DocID != event.DocID {
+			t.Errorf("payload = %s, %v; want the event", msg.payload, err)
+		}
+	}
+	if connects := s.connects.Load(); connects != 1 {
+		t.Errorf("connects = %d; want the connection reused", connects)
+	}
+}
+
+func TestNATSRejected(t *testing.T) {
+	s := &fakeNATS{reject: "docs." + structs.EventDocDeleted}
+	n := startFakeNATS(t, s)
+
+	err := n.Publish(context.Background(), structs.DocEvent{ID: "e1", Type: structs.EventDocDeleted})
+	if !errors.Is(err, ErrNATSRejected) {
+		t.Fatalf("Publish error = %v; want ErrNATSRejected", err)
+	}
+
+	// The connection is dropped after an error, so the next event comes with a new one
+	if err := n.Publish(context.Background(), structs.DocEvent{ID: "e2", Type: structs.EventDocCreated}); err != nil {
+		t.Fatal(err)
+	}
+	<-s.published
+	if connects := s.connects.Load(); connects != 2 {
+		t.Errorf("connects = %d; want 2", connects)
+	}
+}
+
+func TestNATSReconnect(t *testing.T) {
+	s := &fakeNATS{drop: true}
+	n := startFakeNATS(t, s)
+	event := structs.DocEvent{ID: "e1", Type: structs.EventDocCreated}
+
+	if err := n.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	<-s.published
+
+	// The server has dropped the connection, which the client finds out publishing on it
+	if err := n.Publish(context.Background(), event); err == nil {
+		t.Fatalf("Publish to a dropped connection succeeded")
+	}
+	if err := n.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish after reconnect: %v", err)
+	}
+	<-s.published
+	if connects := s.connects.Load(); connects != 2 {
+		t.Errorf("connects = %d; want 2", connects)
+	}
+}
+
+func TestNATSUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	n := NewNATS(address, "docs", time.Second)
+	if err := n.Publish(context.Background(), structs.DocEvent{ID: "e1", Type: structs.EventDocCreated}); err == nil {
+		t.Fatalf("Publish to a closed port succeeded")
+	}
+}
+
+func TestNATSBadGreeting(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "HTTP/1.1 400 Bad Request\r\n")
+	}()
+
+	n := NewNATS(l.Addr().String(), "docs", time.Second)
+	err = n.Publish(context.Background(), structs.DocEvent{ID: "e1", Type: structs.EventDocCreated})
+	if err == nil || !strings.Contains(err.Error(), "greeting") {
+		t.Fatalf("Publish error = %v; want an unexpected greeting", err)
+	}
+}
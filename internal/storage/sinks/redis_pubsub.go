@@ -0,0 +1,30 @@
+package sinks
+
+import (
+	"context"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+
+	"github.com/go-redis/redis/v8"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// RedisPubSub publishes events to a Redis channel. Every server instance listens to it and passes events
+// on to its connected clients. Nothing is kept for instances which aren't listening at the moment
+type RedisPubSub struct {
+	client  *redis.Client
+	channel string
+}
+
+func NewRedisPubSub(client *redis.Client, channel string) *RedisPubSub {
+	return &RedisPubSub{client: client, channel: channel}
+}
+
+func (s *RedisPubSub) Publish(ctx context.Context, event structs.DocEvent) error {
+	payload, err := jsoniter.MarshalToString(event)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Publish(ctx, s.channel, payload).Err()
+}
@@ -0,0 +1,41 @@
+package sinks
+
+import (
+	"context"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+
+	"github.com/go-redis/redis/v8"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// RedisStream appends events to a Redis stream. Consumers read it with XREAD or consumer groups
+type RedisStream struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+// NewRedisStream creates the sink. The stream is trimmed to about maxLen entries, 0 keeps all of them
+func NewRedisStream(client *redis.Client, stream string, maxLen int64) *RedisStream {
+	return &RedisStream{client: client, stream: stream, maxLen: maxLen}
+}
+
+// Publish adds the event to the stream. An event relayed again is added again, consumers tell them by id
+func (s *RedisStream) Publish(ctx context.Context, event structs.DocEvent) error {
+	payload, err := jsoniter.MarshalToString(event)
+	if err != nil {
+		return err
+	}
+
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.stream,
+		MaxLen: s.maxLen,
+		Approx: s.maxLen > 0,
+		Values: map[string]interface{}{
+			"id":      event.ID,
+			"type":    event.Type,
+			"payload": payload,
+		},
+	}).Err()
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+
+	"github.com/Kapeland/task-Astral/internal/utils/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceBlob starts a span of the blob operation, e.g. "blob.stage". The returned function ends it.
+// Missing blobs are an answer, not a failure
+func traceBlob(ctx context.Context, op string, key string) func(error) {
+	_, span := tracing.Start(ctx, "blob."+op, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("blob.key", key)))
+	return func(err error) {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		tracing.End(span, err)
+	}
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage/repository"
+	"github.com/Kapeland/task-Astral/internal/utils/logger"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type UploadRepo interface {
+	CreateUpload(ctx context.Context, up *structs.Upload) error
+	GetUpload(ctx context.Context, uploadID string, owner string) (*structs.Upload, error)
+	SetUploadOffset(ctx context.Context, uploadID string, from int64, to int64, expiresAt time.Time) error
+	StartFinishing(ctx context.Context, uploadID string, owner string) error
+	StopFinishing(ctx context.Context, uploadID string, owner string) error
+	DelUpload(ctx context.Context, uploadID string, owner string) error
+	GetExpiredUploads(ctx context.Context, before time.Time) ([]structs.Upload, error)
+}
+
+type PartProvider interface {
+	AppendPart(uploadID string, offset int64, src io.Reader) (int64, error)
+	OpenPart(uploadID string) (io.ReadSeekCloser, error)
+	RemovePart(uploadID string) error
+}
+
+type UploadStorage struct {
+	ur     UploadRepo
+	pp     PartProvider
+	expiry time.Duration // Uploads without progress for that long are abandoned
+	busy   *sync.Map     // Uploads being written right now
+}
+
+func NewUploadStorage(ur UploadRepo, pp PartProvider, expiry time.Duration) UploadStorage {
+	return UploadStorage{ur: ur, pp: pp, expiry: expiry, busy: &sync.Map{}}
+}
+
+// CreateUpload starts a new upload. Nothing is written until the first chunk arrives.
+func (s *UploadStorage) CreateUpload(ctx context.Context, up structs.Upload) (structs.Upload, error) {
+	up.ID = uuid.NewString()
+	up.Offset = 0
+	up.CreatedAt = time.Now()
+	up.ExpiresAt = up.CreatedAt.Add(s.expiry)
+
+	if err := s.ur.CreateUpload(ctx, &up); err != nil {
+		return structs.Upload{}, err
+	}
+	return up, nil
+}
+
+// GetUpload returns the upload unless it has expired.
+// Returns models.ErrNotFound or err
+func (s *UploadStorage) GetUpload(ctx context.Context, uploadID string, owner string) (structs.Upload, error) {
+	up, err := s.ur.GetUpload(ctx, uploadID, owner)
+	if err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return structs.Upload{}, models.ErrNotFound
+		}
+		return structs.Upload{}, err
+	}
+	if time.Now().After(up.ExpiresAt) { // The purger just hasn't got to it yet
+		return structs.Upload{}, models.ErrNotFound
+	}
+	return *up, nil
+}
+
+// WriteChunk appends src to the upload at offset, which must be equal to the number of bytes received so far.
+// Bytes received before a failure are kept, so the client can resume from the new offset.
+// Requests of this instance are serialized by busy, those of other instances by the conditional offset update.
+// Returns models.ErrNotFound or models.ErrConflict or models.ErrOffsetMismatch or models.ErrTooLarge or err
+func (s *UploadStorage) WriteChunk(ctx context.Context, uploadID string, owner string, offset int64, src io.Reader) (structs.Upload, error) {
+	if _, busy := s.busy.LoadOrStore(uploadID, struct{}{}); busy {
+		return structs.Upload{}, models.ErrConflict
+	}
+	defer s.busy.Delete(uploadID)
+
+	up, err := s.GetUpload(ctx, uploadID, owner)
+	if err != nil {
+		return structs.Upload{}, err
+	}
+	if offset != up.Offset {
+		return up, models.ErrOffsetMismatch
+	}
+
+	n, writeErr := s.pp.AppendPart(uploadID, offset, io.LimitReader(src, up.Length-offset))
+	if n > 0 {
+		up.Offset += n
+		up.ExpiresAt = time.Now().Add(s.expiry)
+		// The client may be gone already, but the received bytes must be accounted for
+		if err := s.ur.SetUploadOffset(context.WithoutCancel(ctx), uploadID, offset, up.Offset, up.ExpiresAt); err != nil {
+			if errors.Is(err, repository.ErrObjectNotFound) { // Written by another request or being finished
+				return structs.Upload{}, models.ErrOffsetMismatch
+			}
+			return structs.Upload{}, err
+		}
+	}
+	if writeErr != nil {
+		return up, writeErr
+	}
+
+	if up.IsComplete() {
+		var extra [1]byte
+		if k, _ := io.ReadFull(src, extra[:]); k > 0 {
+			return up, models.ErrTooLarge
+		}
+	}
+	return up, nil
+}
+
+// OpenUpload opens the received bytes of a complete upload.
+// Returns models.ErrNotFound or models.ErrUploadIncomplete or err
+func (s *UploadStorage) OpenUpload(ctx context.Context, uploadID string, owner string) (structs.Upload, io.ReadSeekCloser, error) {
+	up, err := s.GetUpload(ctx, uploadID, owner)
+	if err != nil {
+		return structs.Upload{}, nil, err
+	}
+	if !up.IsComplete() {
+		return structs.Upload{}, nil, models.ErrUploadIncomplete
+	}
+
+	data, err := s.pp.OpenPart(uploadID)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && up.Length == 0 { // Empty uploads never get a part
+			_, err = s.pp.AppendPart(uploadID, 0, strings.NewReader(""))
+			if err == nil {
+				data, err = s.pp.OpenPart(uploadID)
+			}
+		}
+		if err != nil {
+			return structs.Upload{}, nil, err
+		}
+	}
+	return up, data, nil
+}
+
+// StartFinishing marks the complete upload as being turned into a document, so that it's done once.
+// Returns models.ErrUploadFinishing or err
+func (s *UploadStorage) StartFinishing(ctx context.Context, uploadID string, owner string) error {
+	if err := s.ur.StartFinishing(ctx, uploadID, owner); err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrUploadFinishing
+		}
+		return err
+	}
+	return nil
+}
+
+// StopFinishing lets the upload be finished again after a failure
+func (s *UploadStorage) StopFinishing(ctx context.Context, uploadID string, owner string) error {
+	return s.ur.StopFinishing(ctx, uploadID, owner)
+}
+
+// DeleteUpload removes the upload and its received bytes.
+// Returns models.ErrNotFound or err
+func (s *UploadStorage) DeleteUpload(ctx context.Context, uploadID string, owner string) error {
+	if err := s.ur.DelUpload(ctx, uploadID, owner); err != nil {
+		if errors.Is(err, repository.ErrObjectNotFound) {
+			return models.ErrNotFound
+		}
+		return err
+	}
+	if err := s.pp.RemovePart(uploadID); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// PurgeExpiredUploads removes uploads abandoned before the given moment.
+// Returns the number of purged uploads.
+func (s *UploadStorage) PurgeExpiredUploads(ctx context.Context, before time.Time) (int, error) {
+	lgr := logger.GetLogger().WithContext(ctx)
+
+	ups, err := s.ur.GetExpiredUploads(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, up := range ups {
+		if _, busy := s.busy.Load(up.ID); busy {
+			continue
+		}
+		if err := s.DeleteUpload(ctx, up.ID, up.Owner); err != nil {
+			if errors.Is(err, models.ErrNotFound) { // Finished or cancelled in the meantime
+				continue
+			}
+			lgr.Error(err.Error(), "UploadStorage", "PurgeExpiredUploads", "DeleteUpload")
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage/repository"
+
+	"github.com/pkg/errors"
+)
+
+// fakeUploadRepo keeps uploads in memory and applies the same conditions as the SQL repo
+type fakeUploadRepo struct {
+	mu        sync.Mutex
+	ups       map[string]structs.Upload
+	finishing map[string]bool
+	// beforeUpdate runs before the offset is updated, e.g. to emulate a request of another instance
+	beforeUpdate func()
+}
+
+func newFakeUploadRepo(ups ...structs.Upload) *fakeUploadRepo {
+	r := &fakeUploadRepo{ups: make(map[string]structs.Upload), finishing: make(map[string]bool)}
+	for _, up := range ups {
+		r.ups[up.ID] = up
+	}
+	return r
+}
+
+func (r *fakeUploadRepo) CreateUpload(ctx context.Context, up *structs.Upload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ups[up.ID] = *up
+	return nil
+}
+
+func (r *fakeUploadRepo) GetUpload(ctx context.Context, uploadID string, owner string) (*structs.Upload, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	up, ok := r.ups[uploadID]
+	if !ok || up.Owner != owner {
+		return nil, repository.ErrObjectNotFound
+	}
+	return &up, nil
+}
+
+func (r *fakeUploadRepo) SetUploadOffset(ctx context.Context, uploadID string, from int64, to int64, expiresAt time.Time) error {
+	if r.beforeUpdate != nil {
+		r.beforeUpdate()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	up, ok := r.ups[uploadID]
+	if !ok || up.Offset != from || r.finishing[uploadID] {
+		return repository.ErrObjectNotFound
+	}
+	up.Offset, up.ExpiresAt = to, expiresAt
+	r.ups[uploadID] = up
+	return nil
+}
+
+func (r *fakeUploadRepo) StartFinishing(ctx context.Context, uploadID string, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	up, ok := r.ups[uploadID]
+	if !ok || up.Owner != owner || !up.IsComplete() || r.finishing[uploadID] {
+		return repository.ErrObjectNotFound
+	}
+	r.finishing[uploadID] = true
+	return nil
+}
+
+func (r *fakeUploadRepo) StopFinishing(ctx context.Context, uploadID string, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.finishing, uploadID)
+	return nil
+}
+
+func (r *fakeUploadRepo) DelUpload(ctx context.Context, uploadID string, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.ups[uploadID]; !ok {
+		return repository.ErrObjectNotFound
+	}
+	delete(r.ups, uploadID)
+	return nil
+}
+
+func (r *fakeUploadRepo) GetExpiredUploads(ctx context.Context, before time.Time) ([]structs.Upload, error) {
+	return nil, nil
+}
+
+// fakeParts keeps parts of uploads in memory
+type fakeParts struct {
+	mu    sync.Mutex
+	parts map[string]string
+}
+
+func (p *fakeParts) AppendPart(uploadID string, offset int64, src io.Reader) (int64, error) {
+	data, err := io.ReadAll(src)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	part := p.parts[uploadID]
+	if int64(len(part)) > offset {
+		part = part[:offset]
+	}
+	p.parts[uploadID] = part + string(data)
+	return int64(len(data)), err
+}
+
+func (p *fakeParts) OpenPart(uploadID string) (io.ReadSeekCloser, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	part, ok := p.parts[uploadID]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return nopCloser{strings.NewReader(part)}, nil
+}
+
+func (p *fakeParts) RemovePart(uploadID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.parts, uploadID)
+	return nil
+}
+
+func newTestUpload(length int64) structs.Upload {
+	return structs.Upload{ID: "up", Owner: "owner", Length: length, ExpiresAt: time.Now().Add(time.Hour)}
+}
+
+func TestWriteChunk(t *testing.T) {
+	ur := newFakeUploadRepo(newTestUpload(6))
+	s := NewUploadStorage(ur, &fakeParts{parts: make(map[string]string)}, time.Hour)
+	ctx := context.Background()
+
+	up, err := s.WriteChunk(ctx, "up", "owner", 0, strings.NewReader("abc"))
+	if err != nil || up.Offset != 3 {
+		t.Fatalf("WriteChunk = %d, %v; want offset 3", up.Offset, err)
+	}
+	if _, err := s.WriteChunk(ctx, "up", "owner", 0, strings.NewReader("abc")); !errors.Is(err, models.ErrOffsetMismatch) {
+		t.Fatalf("retried WriteChunk error = %v; want ErrOffsetMismatch", err)
+	}
+	if _, err := s.WriteChunk(ctx, "up", "owner", 3, strings.NewReader("defg")); !errors.Is(err, models.ErrTooLarge) {
+		t.Fatalf("oversized WriteChunk error = %v; want ErrTooLarge", err)
+	}
+	if up, _ := s.GetUpload(ctx, "up", "owner"); !up.IsComplete() {
+		t.Errorf("offset = %d; want the upload complete", up.Offset)
+	}
+}
+
+func TestWriteChunkRace(t *testing.T) {
+	ur := newFakeUploadRepo(newTestUpload(6))
+	s := NewUploadStorage(ur, &fakeParts{parts: make(map[string]string)}, time.Hour)
+	ctx := context.Background()
+
+	// Another instance writes the same chunk after this one has read the offset
+	ur.beforeUpdate = func() {
+		ur.beforeUpdate = nil
+		if err := ur.SetUploadOffset(ctx, "up", 0, 3, time.Now().Add(time.Hour)); err != nil {
+			t.Errorf("SetUploadOffset of the other instance: %v", err)
+		}
+	}
+
+	if _, err := s.WriteChunk(ctx, "up", "owner", 0, strings.NewReader("abc")); !errors.Is(err, models.ErrOffsetMismatch) {
+		t.Fatalf("WriteChunk error = %v; want ErrOffsetMismatch", err)
+	}
+	if up, _ := s.GetUpload(ctx, "up", "owner"); up.Offset != 3 {
+		t.Errorf("offset = %d; want 3", up.Offset)
+	}
+}
+
+func TestStartFinishing(t *testing.T) {
+	ur := newFakeUploadRepo(newTestUpload(3))
+	s := NewUploadStorage(ur, &fakeParts{parts: make(map[string]string)}, time.Hour)
+	ctx := context.Background()
+
+	if err := s.StartFinishing(ctx, "up", "owner"); !errors.Is(err, models.ErrUploadFinishing) {
+		t.Fatalf("StartFinishing of an incomplete upload error = %v; want ErrUploadFinishing", err)
+	}
+	if _, err := s.WriteChunk(ctx, "up", "owner", 0, strings.NewReader("abc")); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	started := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started <- s.StartFinishing(ctx, "up", "owner")
+		}()
+	}
+	wg.Wait()
+	close(started)
+	ok := 0
+	for err := range started {
+		switch {
+		case err == nil:
+			ok++
+		case !errors.Is(err, models.ErrUploadFinishing):
+			t.Fatalf("StartFinishing error = %v; want ErrUploadFinishing", err)
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("%d requests started finishing; want 1", ok)
+	}
+
+	if _, err := s.WriteChunk(ctx, "up", "owner", 3, strings.NewReader("")); err != nil {
+		t.Fatalf("empty WriteChunk while finishing: %v", err)
+	}
+
+	if err := s.StopFinishing(ctx, "up", "owner"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StartFinishing(ctx, "up", "owner"); err != nil {
+		t.Fatalf("StartFinishing after StopFinishing: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models"
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+	"github.com/Kapeland/task-Astral/internal/storage/repository"
+
+	"github.com/google/uuid"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+type WebhookRepo interface {
+	CreateWebhook(ctx context.Context, webhook *structs.Webhook) error
+	GetWebhooksByOwner(ctx context.Context, owner string) ([]structs.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID string, owner string) error
+	EnqueueEvent(ctx context.Context, owner string, event string, eventID string, payload string, now time.Time) (int, error)
+	ClaimDueDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]structs.DueDelivery, error)
+	MarkDelivered(ctx context.Context, deliveryID int64, code int, now time.Time) error
+	MarkFailed(ctx context.Context, deliveryID int64, code int, lastErr string, nextAttempt *time.Time) error
+	GetDeliveries(ctx context.Context, webhookID string, owner string, filter structs.DeliveryFilter) ([]structs.WebhookDelivery, error)
+	RetryDelivery(ctx context.Context, deliveryID int64, webhookID string, owner string, now time.Time) error
+}
+
+// RetryPolicy - how failed webhook deliveries are retried
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration // Delay after the first failure, doubled after every next one
+	MaxBackoff  time.Duration
+	Lease       time.Duration // Claimed deliveries aren't given to others for that long
+}
+
+// Delay returns the wait before the next attempt after attempts failed ones
+func (p RetryPolicy) Delay(attempts int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempts && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxBackoff)
+}
+
+type WebhookStorage struct {
+	wr     WebhookRepo
+	policy RetryPolicy
+}
+
+func NewWebhookStorage(wr WebhookRepo, policy RetryPolicy) WebhookStorage {
+	return WebhookStorage{wr: wr, policy: policy}
+}
+
+// webhookSecretSize is the number of random bytes in a signing secret
+const webhookSecretSize = 32
+
+// CreateWebhook registers the endpoint of owner with a new signing secret
+func (s *WebhookStorage) CreateWebhook(ctx context.Context, owner string, url string, events []string) (structs.Webhook, error) {
+	secret := make([]byte, webhookSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return structs.Webhook{}, err
+	}
+
+	webhook := structs.Webhook{
+		ID:        uuid.NewString(),
+		Owner:     owner,
+		URL:       url,
+		Secret:    hex.EncodeToString(secret),
+		Events:    events,
+		CreatedAt: time.Now(),
+	}
+	if err := s.wr.CreateWebhook(ctx, &webhook); err != nil {
+		return structs.Webhook{}, err
+	}
+	return webhook, nil
+}
+
+func (s *WebhookStorage) GetWebhooks(ctx context.Context, owner string) ([]structs.Webhook, error) {
+	return s.wr.GetWebhooksByOwner(ctx, owner)
+}
+
+// Returns models.ErrNotFound or err
+func (s *WebhookStorage) DeleteWebhook(ctx context.Context, webhookID string, owner string) error {
+	err := s.wr.DeleteWebhook(ctx, webhookID, owner)
+	if errors.Is(err, repository.ErrObjectNotFound) {
+		return models.ErrNotFound
+	}
+	return err
+}
+
+// Publish queues deliveries of the event to webhooks of the document owner
+func (s *WebhookStorage) Publish(ctx context.Context, event structs.DocEvent) error {
+	payload, err := jsoniter.MarshalToString(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.wr.EnqueueEvent(ctx, event.Owner, event.Type, event.ID, payload, time.Now())
+	return err
+}
+
+// ClaimDueDeliveries returns deliveries to send now. They are leased, so other instances skip them
+func (s *WebhookStorage) ClaimDueDeliveries(ctx context.Context, limit int) ([]structs.DueDelivery, error) {
+	now := time.Now()
+	return s.wr.ClaimDueDeliveries(ctx, now, now.Add(s.policy.Lease), limit)
+}
+
+func (s *WebhookStorage) MarkDelivered(ctx context.Context, deliveryID int64, code int) error {
+	return s.wr.MarkDelivered(ctx, deliveryID, code, time.Now())
+}
+
+// MarkFailed schedules the next attempt with exponential backoff or makes the delivery dead
+func (s *WebhookStorage) MarkFailed(ctx context.Context, delivery structs.DueDelivery, code int, sendErr error) error {
+	attempts := delivery.Attempts + 1
+	if attempts >= s.policy.MaxAttempts {
+		return s.wr.MarkFailed(ctx, delivery.ID, code, sendErr.Error(), nil)
+	}
+
+	next := time.Now().Add(s.policy.Delay(attempts))
+	return s.wr.MarkFailed(ctx, delivery.ID, code, sendErr.Error(), &next)
+}
+
+func (s *WebhookStorage) GetDeliveries(ctx context.Context, webhookID string, owner string, filter structs.DeliveryFilter) ([]structs.WebhookDelivery, error) {
+	return s.wr.GetDeliveries(ctx, webhookID, owner, filter)
+}
+
+// RetryDelivery brings the dead delivery back.
+// Returns models.ErrNotFound or err
+func (s *WebhookStorage) RetryDelivery(ctx context.Context, deliveryID int64, webhookID string, owner string) error {
+	err := s.wr.RetryDelivery(ctx, deliveryID, webhookID, owner, time.Now())
+	if errors.Is(err, repository.ErrObjectNotFound) {
+		return models.ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+)
+
+func TestSign(t *testing.T) {
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(`1700000000.{"id":"e1"}`))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := Sign("secret", "1700000000", []byte(`{"id":"e1"}`)); got != want {
+		t.Errorf("Sign = %s; want %s", got, want)
+	}
+	if Sign("secret", "1700000001", []byte(`{"id":"e1"}`)) == want {
+		t.Errorf("signature doesn't depend on the timestamp")
+	}
+	if Sign("other", "1700000000", []byte(`{"id":"e1"}`)) == want {
+		t.Errorf("signature doesn't depend on the secret")
+	}
+}
+
+func TestDenyPrivate(t *testing.T) {
+	tests := []struct {
+		address string
+		denied  bool
+	}{
+		{"127.0.0.1:80", true},
+		{"[::1]:443", true},
+		{"10.1.2.3:80", true},
+		{"172.16.0.1:80", true},
+		{"192.168.1.1:80", true},
+		{"[fd00::1]:80", true},
+		{"169.254.169.254:80", true},
+		{"0.0.0.0:80", true},
+		{"224.0.0.1:80", true},
+		{"93.184.216.34:443", false},
+		{"[2606:4700::1111]:443", false},
+	}
+	for _, tt := range tests {
+		err := denyPrivate("tcp", tt.address, nil)
+		if denied := errors.Is(err, ErrPrivateAddress); denied != tt.denied {
+			t.Errorf("denyPrivate(%s) = %v; want denied %v", tt.address, err, tt.denied)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	delivery := structs.DueDelivery{
+		WebhookDelivery: structs.WebhookDelivery{ID: 7, Event: structs.EventDocCreated, Payload: `{"id":"e1"}`},
+		URL:             srv.URL,
+		Secret:          "secret",
+	}
+
+	code, err := New(time.Second, true).Send(context.Background(), delivery)
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("Send = %d, %v; want 204", code, err)
+	}
+	if string(body) != delivery.Payload {
+		t.Errorf("body = %s; want the payload", body)
+	}
+	if got.Header.Get(HeaderEvent) != structs.EventDocCreated || got.Header.Get(HeaderDelivery) != "7" {
+		t.Errorf("headers = %v", got.Header)
+	}
+	if sign := Sign("secret", got.Header.Get(HeaderTimestamp), body); got.Header.Get(HeaderSignature) != sign {
+		t.Errorf("signature = %s; want %s", got.Header.Get(HeaderSignature), sign)
+	}
+}
+
+func TestSendPrivate(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	t.Cleanup(srv.Close)
+
+	code, err := New(time.Second, false).Send(context.Background(), structs.DueDelivery{URL: srv.URL})
+	if !errors.Is(err, ErrPrivateAddress) || code != 0 {
+		t.Fatalf("Send = %d, %v; want ErrPrivateAddress", code, err)
+	}
+	if called {
+		t.Errorf("loopback webhook has been called")
+	}
+}
+
+func TestSendFailedStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://10.0.0.1/", http.StatusFound)
+	}))
+	t.Cleanup(srv.Close)
+
+	code, err := New(time.Second, true).Send(context.Background(), structs.DueDelivery{URL: srv.URL})
+	if err == nil || code != http.StatusFound || !strings.Contains(err.Error(), "302") {
+		t.Fatalf("Send = %d, %v; want the redirect not followed and failed", code, err)
+	}
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kapeland/task-Astral/internal/models/structs"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: time.Second, MaxBackoff: 10 * time.Second}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.Delay(tt.attempts); got != tt.want {
+			t.Errorf("Delay(%d) = %s; want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// fakeWebhookRepo records the last failure. Methods not used by tests panic.
+type fakeWebhookRepo struct {
+	WebhookRepo
+	lastErr     string
+	nextAttempt *time.Time
+}
+
+func (r *fakeWebhookRepo) MarkFailed(ctx context.Context, deliveryID int64, code int, lastErr string, nextAttempt *time.Time) error {
+	r.lastErr, r.nextAttempt = lastErr, nextAttempt
+	return nil
+}
+
+func TestWebhookMarkFailed(t *testing.T) {
+	repo := &fakeWebhookRepo{}
+	s := NewWebhookStorage(repo, RetryPolicy{MaxAttempts: 3, Backoff: time.Minute, MaxBackoff: time.Hour})
+	delivery := structs.DueDelivery{WebhookDelivery: structs.WebhookDelivery{ID: 1, Attempts: 1}}
+
+	before := time.Now()
+	if err := s.MarkFailed(context.Background(), delivery, 500, errors.New("webhook responded with 500")); err != nil {
+		t.Fatal(err)
+	}
+	if repo.nextAttempt == nil {
+		t.Fatalf("second failure made the delivery dead; want it retried")
+	}
+	if delay := repo.nextAttempt.Sub(before); delay < 2*time.Minute || delay > 2*time.Minute+time.Second {
+		t.Errorf("next attempt in %s; want 2m after the second failure", delay)
+	}
+	if repo.lastErr != "webhook responded with 500" {
+		t.Errorf("last error = %q", repo.lastErr)
+	}
+
+	delivery.Attempts = 2
+	if err := s.MarkFailed(context.Background(), delivery, 0, errors.New("timeout")); err != nil {
+		t.Fatal(err)
+	}
+	if repo.nextAttempt != nil {
+		t.Errorf("next attempt at %s after MaxAttempts; want the delivery dead", repo.nextAttempt)
+	}
+}
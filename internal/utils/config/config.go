@@ -5,6 +5,7 @@ import (
 	"gopkg.in/yaml.v3"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // configPathEnv is set in Dockerfile
@@ -22,8 +23,12 @@ func GetConfig() Config {
 
 // Rest - contains parameter rest JSON connection.
 type Rest struct {
-	Host string `yaml:"host"`
-	Port int    `yaml:"port"`
+	Host              string        `yaml:"host"`
+	Port              int           `yaml:"port"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`    // Requests in progress are given that long to finish on shutdown
+	TrustedProxies    []string      `yaml:"trusted_proxies"`     // Addresses or CIDRs whose X-Forwarded-For is used, none by default
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"` // Clients must send request headers in time
+	ReadTimeout       time.Duration `yaml:"read_timeout"`        // Clients must send the whole request, with the body, in time
 }
 
 // Database - contains all parameters database connection.
@@ -60,13 +65,170 @@ type Logger struct {
 	LogRate float64 `yaml:"rate"`
 }
 
+// Storage - contains parameters of the blob storage.
+type Storage struct {
+	Root string `yaml:"root"` // Directory of blobs, file-storage in the working directory by default
+}
+
+// Scanner - contains parameters of the periodic file-storage scan.
+type Scanner struct {
+	Interval   time.Duration `yaml:"interval"` // 0 disables the scan
+	Action     string        `yaml:"action"`
+	VerifyHash bool          `yaml:"verify_hash"`
+}
+
+// Trash - contains parameters of the deleted documents purge.
+type Trash struct {
+	Retention     time.Duration `yaml:"retention"`
+	PurgeInterval time.Duration `yaml:"purge_interval"` // 0 disables the purge
+}
+
+// Upload - contains restrictions of uploaded documents.
+// Mime lists contain types like "application/pdf" or groups like "image/*".
+type Upload struct {
+	AllowedMime []string `yaml:"allowed_mime"` // Empty list allows everything that isn't denied
+	DeniedMime  []string `yaml:"denied_mime"`
+	MD5         bool     `yaml:"md5"` // Also compute MD5 of uploads for legacy clients
+}
+
+// Antivirus - contains parameters of the uploaded documents scanning.
+type Antivirus struct {
+	Backend  string        `yaml:"backend"` // none or clamav
+	Network  string        `yaml:"network"` // tcp or unix
+	Address  string        `yaml:"address"`
+	Timeout  time.Duration `yaml:"timeout"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// EncryptionKey - contains a master key: base64 encoded 32 bytes either inline or in a file.
+type EncryptionKey struct {
+	ID      string `yaml:"id"`
+	Key     string `yaml:"key"`
+	KeyFile string `yaml:"key_file"`
+}
+
+// Encryption - contains parameters of the stored documents encryption.
+type Encryption struct {
+	Enabled    bool            `yaml:"enabled"`
+	CurrentKey string          `yaml:"current_key"`
+	Keys       []EncryptionKey `yaml:"keys"`
+}
+
+// Resumable - contains parameters of resumable uploads.
+type Resumable struct {
+	MaxSize       int64         `yaml:"max_size"` // 0 means no limit
+	Expiry        time.Duration `yaml:"expiry"`   // Uploads without progress for that long are removed
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+}
+
+// Anonymous - contains parameters of reading public documents without a token.
+type Anonymous struct {
+	Enabled bool    `yaml:"enabled"`
+	Rate    float64 `yaml:"rate"`  // Requests per second from one client IP, 0 means no limit
+	Burst   int     `yaml:"burst"` // Requests allowed at once before the rate applies
+}
+
+// Shares - contains parameters of opening share links.
+type Shares struct {
+	Rate  float64 `yaml:"rate"`  // Requests per second from one client IP, 0 means no limit
+	Burst int     `yaml:"burst"` // Requests allowed at once before the rate applies
+}
+
+// Webhooks - contains parameters of webhook deliveries.
+type Webhooks struct {
+	Interval     time.Duration `yaml:"interval"`
+	Timeout      time.Duration `yaml:"timeout"`
+	MaxAttempts  int           `yaml:"max_attempts"` // After that many failures a delivery is dead
+	Backoff      time.Duration `yaml:"backoff"`      // Delay after the first failure, doubled after every next one
+	MaxBackoff   time.Duration `yaml:"max_backoff"`
+	AllowPrivate bool          `yaml:"allow_private"` // Allow endpoints in private networks, e.g. for local testing
+}
+
+// Outbox - contains parameters of the relay of document events.
+type Outbox struct {
+	Interval    time.Duration `yaml:"interval"`
+	BatchSize   int           `yaml:"batch_size"`
+	Backoff     time.Duration `yaml:"backoff"` // Delay after the first failure, doubled after every next one
+	MaxBackoff  time.Duration `yaml:"max_backoff"`
+	Retention   time.Duration `yaml:"retention"` // Published events are kept that long
+	Sinks       []string      `yaml:"sinks"`     // webhook, redis_stream, nats
+	RedisStream RedisStream   `yaml:"redis_stream"`
+	NATS        NATS          `yaml:"nats"`
+}
+
+// RedisStream - contains parameters of the Redis stream sink.
+type RedisStream struct {
+	Stream string `yaml:"stream"`
+	MaxLen int64  `yaml:"max_len"` // The stream is trimmed to about that many entries, 0 keeps all
+}
+
+// NATS - contains parameters of the NATS sink.
+type NATS struct {
+	Address string        `yaml:"address"`
+	Subject string        `yaml:"subject"` // Events go to <subject>.<event type>
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Feed - contains parameters of the change feed of documents.
+type Feed struct {
+	Enabled        bool          `yaml:"enabled"`
+	Channel        string        `yaml:"channel"`         // Redis channel events are fanned out through
+	Heartbeat      time.Duration `yaml:"heartbeat"`       // Keeps idle connections open through proxies
+	AllowedOrigins []string      `yaml:"allowed_origins"` // Pages of other sites which may open the websocket
+}
+
+// Metrics - contains parameters of the Prometheus endpoint.
+type Metrics struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	Token   string `yaml:"token"` // If set, scrapes must send it as a bearer token
+}
+
+// Tracing - contains parameters of the OpenTelemetry traces export.
+type Tracing struct {
+	Enabled     bool    `yaml:"enabled"`
+	Endpoint    string  `yaml:"endpoint"` // host:port of the OTLP/HTTP collector
+	Insecure    bool    `yaml:"insecure"`
+	SampleRatio float64 `yaml:"sample_ratio"` // Share of new traces to record, 0 records all
+	ServiceName string  `yaml:"service_name"`
+}
+
+// Health - contains parameters of the readiness probe.
+type Health struct {
+	Timeout time.Duration `yaml:"timeout"` // Every dependency must answer in time to be ready
+}
+
+// Cache - contains parameters of the response cache.
+type Cache struct {
+	Timeout          time.Duration `yaml:"timeout"`           // Redis must answer in time, otherwise the call has failed
+	FailureThreshold int           `yaml:"failure_threshold"` // Consecutive failures which move the cache to memory
+	Cooldown         time.Duration `yaml:"cooldown"`          // Redis is probed again after that long
+	MemoryTTL        time.Duration `yaml:"memory_ttl"`        // Responses are kept in memory that long at most
+}
+
 type Config struct {
-	Project  Project  `yaml:"project"`
-	Rest     Rest     `yaml:"rest"`
-	Database Database `yaml:"database"`
-	Redis    Redis    `yaml:"redis"`
-	Admin    Admin    `yaml:"admin"`
-	Logger   Logger   `yaml:"logger"`
+	Project    Project    `yaml:"project"`
+	Rest       Rest       `yaml:"rest"`
+	Database   Database   `yaml:"database"`
+	Redis      Redis      `yaml:"redis"`
+	Admin      Admin      `yaml:"admin"`
+	Logger     Logger     `yaml:"logger"`
+	Storage    Storage    `yaml:"storage"`
+	Scanner    Scanner    `yaml:"scanner"`
+	Trash      Trash      `yaml:"trash"`
+	Upload     Upload     `yaml:"upload"`
+	Antivirus  Antivirus  `yaml:"antivirus"`
+	Encryption Encryption `yaml:"encryption"`
+	Resumable  Resumable  `yaml:"resumable"`
+	Anonymous  Anonymous  `yaml:"anonymous"`
+	Shares     Shares     `yaml:"shares"`
+	Webhooks   Webhooks   `yaml:"webhooks"`
+	Outbox     Outbox     `yaml:"outbox"`
+	Feed       Feed       `yaml:"feed"`
+	Metrics    Metrics    `yaml:"metrics"`
+	Tracing    Tracing    `yaml:"tracing"`
+	Health     Health     `yaml:"health"`
+	Cache      Cache      `yaml:"cache"`
 }
 
 func ReadConfigYAML() error {
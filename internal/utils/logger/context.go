@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestKey struct{}
+
+// request holds what is known about the request being served. The login is learned only after the token is checked,
+// deeper than the middleware that created the context, so it's set in place
+type request struct {
+	id    string
+	route string
+
+	mu    sync.Mutex
+	login string
+}
+
+// WithRequest puts the request id and the route pattern into the context. Log lines written with it carry both
+func WithRequest(ctx context.Context, requestID string, route string) context.Context {
+	return context.WithValue(ctx, requestKey{}, &request{id: requestID, route: route})
+}
+
+// RequestID returns the id of the request the context belongs to or ""
+func RequestID(ctx context.Context) string {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		return req.id
+	}
+	return ""
+}
+
+// SetLogin attaches the login of the authenticated user to the request of the context. Does nothing outside requests
+func SetLogin(ctx context.Context, login string) {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		req.mu.Lock()
+		req.login = login
+		req.mu.Unlock()
+	}
+}
+
+// WithContext returns the logger which adds request id, route, login and trace id from ctx to every line
+func (lgr Logger) WithContext(ctx context.Context) Logger {
+	lgr.ctx = ctx
+	return lgr
+}
+
+func (lgr Logger) context() context.Context {
+	if lgr.ctx == nil {
+		return context.Background()
+	}
+	return lgr.ctx
+}
+
+// contextHandler adds the attributes of the request and the trace found in the context of the record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		req.mu.Lock()
+		login := req.login
+		req.mu.Unlock()
+
+		r.AddAttrs(slog.String("request_id", req.id), slog.String("route", req.route))
+		if login != "" {
+			r.AddAttrs(slog.String("login", login))
+		}
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"context"
 	"github.com/Kapeland/task-Astral/internal/utils/config"
 	slogmulti "github.com/samber/slog-multi"
 	slogsampling "github.com/samber/slog-sampling"
@@ -22,25 +23,26 @@ func GetLogger() Logger {
 type Logger struct {
 	Logger   *slog.Logger
 	logLevel *slog.LevelVar
+	ctx      context.Context // Set by WithContext
 }
 
 func (lgr Logger) Info(msg, tp, method, after string) {
-	lgr.Logger.Info(msg, slog.String("type", tp), slog.String("method", method), slog.String("after", after))
+	lgr.Logger.InfoContext(lgr.context(), msg, slog.String("type", tp), slog.String("method", method), slog.String("after", after))
 	return
 }
 
 func (lgr Logger) Debug(msg, tp, method, after string) {
-	lgr.Logger.Debug(msg, slog.String("type", tp), slog.String("method", method), slog.String("after", after))
+	lgr.Logger.DebugContext(lgr.context(), msg, slog.String("type", tp), slog.String("method", method), slog.String("after", after))
 	return
 }
 
 func (lgr Logger) Warn(msg, tp, method, after string) {
-	lgr.Logger.Warn(msg, slog.String("type", tp), slog.String("method", method), slog.String("after", after))
+	lgr.Logger.WarnContext(lgr.context(), msg, slog.String("type", tp), slog.String("method", method), slog.String("after", after))
 	return
 }
 
 func (lgr Logger) Error(msg, tp, method, after string) {
-	lgr.Logger.Error(msg, slog.String("type", tp), slog.String("method", method), slog.String("after", after))
+	lgr.Logger.ErrorContext(lgr.context(), msg, slog.String("type", tp), slog.String("method", method), slog.String("after", after))
 	return
 }
 
@@ -75,7 +77,7 @@ func CreateLogger(cfg *config.Config) Logger {
 		Logger: slog.New(
 			slogmulti.
 				Pipe(samplingOption.NewMiddleware()).
-				Handler(handler),
+				Handler(contextHandler{handler}),
 		),
 		logLevel: logLevel,
 	}
@@ -0,0 +1,31 @@
+package metrics
+
+// Metrics of the service. Label values must stay few, ids and logins never go to labels
+var (
+	HTTPRequestDuration = NewHistogramVec("http_request_duration_seconds",
+		"Latency of HTTP requests by route and status.", DefBuckets, "method", "route", "status")
+
+	DBQueryDuration = NewHistogramVec("db_query_duration_seconds",
+		"Latency of Postgres queries by repository method.", DefBuckets, "repository", "method", "result")
+
+	CacheRequests = NewCounterVec("cache_requests_total",
+		"Lookups of the response cache by route and result (hit or miss).", "route", "result")
+
+	StorageBytes = NewCounterVec("storage_bytes_total",
+		"Bytes of documents uploaded to and downloaded from the blob storage.", "direction")
+
+	StorageErrors = NewCounterVec("storage_errors_total",
+		"Failed operations of the blob storage backend.", "backend", "operation")
+)
+
+// Values of labels
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+
+	DirectionUpload   = "upload"
+	DirectionDownload = "download"
+
+	ResultOK    = "ok"
+	ResultError = "error"
+)